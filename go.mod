@@ -5,6 +5,7 @@ go 1.19
 require (
 	github.com/chuxorg/chux-datastore v1.2.16
 	go.mongodb.org/mongo-driver v1.11.4
+	golang.org/x/net v0.17.0
 )
 
 require (
@@ -16,7 +17,7 @@ require (
 	github.com/xdg-go/scram v1.1.1 // indirect
 	github.com/xdg-go/stringprep v1.0.3 // indirect
 	github.com/youmark/pkcs8 v0.0.0-20181117223130-1be2e3e5546d // indirect
-	golang.org/x/crypto v0.0.0-20220622213112-05595931fe9d // indirect
+	golang.org/x/crypto v0.14.0 // indirect
 	golang.org/x/sync v0.1.0 // indirect
-	golang.org/x/text v0.5.0 // indirect
+	golang.org/x/text v0.13.0 // indirect
 )
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/chuxorg/chux-datastore/db"
 	dbl "github.com/chuxorg/chux-datastore/logging"
@@ -25,23 +26,52 @@ type Article struct {
 	DateCreated      CustomTime         `bson:"dateCreated"`
 	DateModified     CustomTime         `bson:"dateModified"`
 	DateModifiedRaw  string             `bson:"dateModifiedRaw"`
-	Author           string             `bson:"author"`
-	AuthorsList      []string           `bson:"authorsList"`
-	InLanguage       string             `bson:"inLanguage"`
-	Breadcrumbs      []Breadcrumb       `bson:"breadcrumbs"`
-	MainImage        string             `bson:"mainImage"`
-	Images           []string           `bson:"images"`
-	Description      string             `bson:"description"`
-	ArticleBody      string             `bson:"articleBody"`
-	ArticleBodyHTML  string             `bson:"articleBodyHtml"`
-	CanonicalURL     string             `bson:"canonicalUrl"`
-	isNew            bool               `bson:"isNew"`
-	isDeleted        bool               `bson:"isDeleted"`
-	isDirty          bool               `bson:"isDirty"`
-	FilesProcessed   bool               `bson:"filesProcessed" json:"filesProcessed"`
-	ImagesProcessed  bool               `bson:"imagesProcessed" json:"imagesProcessed"`
-	originalState    *Article           `bson:"-"`
-	Logger           *logging.Logger    `bson:"-"`
+	// The formats DatePublished and DateModified were parsed from when they were read from the raw strings
+	DatePublishedFormat string               `bson:"datePublishedFormat,omitempty"`
+	DateModifiedFormat  string               `bson:"dateModifiedFormat,omitempty"`
+	Author              string               `bson:"author"`
+	AuthorsList         []string             `bson:"authorsList"`
+	Byline              string               `bson:"byline,omitempty" json:"byline,omitempty"`
+	AuthorIDs           []primitive.ObjectID `bson:"authorIds,omitempty" json:"authorIds,omitempty"`
+	InLanguage          string               `bson:"inLanguage"`
+	LanguageConfidence  float64              `bson:"languageConfidence,omitempty" json:"languageConfidence,omitempty"`
+	Breadcrumbs         []Breadcrumb         `bson:"breadcrumbs"`
+	MainImage           string               `bson:"mainImage"`
+	Images              []string             `bson:"images"`
+	Description         string               `bson:"description"`
+	// An extractive summary of the ArticleBody, made again each time the Article is saved
+	Summary         string `bson:"summary,omitempty" json:"summary,omitempty"`
+	ArticleBody     string `bson:"articleBody"`
+	ArticleBodyHTML string `bson:"articleBodyHtml"`
+	// The ArticleBodyHTML as Markdown, only stored when the Article is created with NewArticleWithMarkdown
+	ArticleBodyMarkdown string              `bson:"articleBodyMarkdown,omitempty" json:"articleBodyMarkdown,omitempty"`
+	CanonicalURL        string              `bson:"canonicalUrl"`
+	Reading             *ReadingStats       `bson:"reading,omitempty" json:"reading,omitempty"`
+	Fingerprint         *ContentFingerprint `bson:"fingerprint,omitempty" json:"fingerprint,omitempty"`
+	// Not omitted when empty, so that saving an Article that is no longer a duplicate clears it
+	DuplicateOf primitive.ObjectID `bson:"duplicateOf" json:"duplicateOf,omitempty"`
+	// Stored even when empty, an Article that lost its Tags must not stay linked to them
+	Keywords           []Keyword         `bson:"keywords" json:"keywords,omitempty"`
+	Tags               []string          `bson:"tags" json:"tags,omitempty"`
+	Mentions           []EntityMention   `bson:"mentions,omitempty" json:"mentions,omitempty"`
+	isNew              bool              `bson:"isNew"`
+	isDeleted          bool              `bson:"isDeleted"`
+	isDirty            bool              `bson:"isDirty"`
+	FilesProcessed     bool              `bson:"filesProcessed" json:"filesProcessed"`
+	ImagesProcessed    bool              `bson:"imagesProcessed" json:"imagesProcessed"`
+	originalState      *Article          `bson:"-"`
+	quarantineGate     *QuarantineGate   `bson:"-"`
+	deadLetters        bool              `bson:"-"`
+	dateParser         *DateParser       `bson:"-"`
+	markdown           bool              `bson:"-"`
+	summarizer         *Summarizer       `bson:"-"`
+	languageDetector   *LanguageDetector `bson:"-"`
+	duplicateThreshold float64           `bson:"-"`
+	authors            bool              `bson:"-"`
+	keywordExtractor   *KeywordExtractor `bson:"-"`
+	entityLinker       *EntityLinker     `bson:"-"`
+	timeConfig         *CustomTimeConfig `bson:"-"`
+	Logger             *logging.Logger   `bson:"-"`
 }
 
 func NewArticle(options ...func(*Article)) *Article {
@@ -72,6 +102,90 @@ func NewArticleWithLogger(logger logging.Logger) func(*Article) {
 	}
 }
 
+// Sets how the Article's CustomTimes are written to JSON and stored in Mongo,
+// without it they use the DefaultCustomTimeConfig
+func NewArticleWithTimeConfig(config CustomTimeConfig) func(*Article) {
+	return func(a *Article) {
+		a.timeConfig = &config
+	}
+}
+
+// New Articles that do not pass the QuarantineGate are
+// quarantined by Save() instead of being saved
+func NewArticleWithQuarantineGate(gate *QuarantineGate) func(*Article) {
+	return func(a *Article) {
+		a.quarantineGate = gate
+	}
+}
+
+// Sets the DateParser used to read DatePublishedRaw and DateModifiedRaw
+// on Save(), e.g. to read day first dates. Relative dates are anchored to
+// the time the Article was scraped unless the DateParser has a Reference.
+func NewArticleWithDateParser(parser *DateParser) func(*Article) {
+	return func(a *Article) {
+		a.dateParser = parser
+	}
+}
+
+// Stores the ArticleBodyHTML as Markdown in ArticleBodyMarkdown on Save()
+func NewArticleWithMarkdown(enabled bool) func(*Article) {
+	return func(a *Article) {
+		a.markdown = enabled
+	}
+}
+
+// Sets the Summarizer used on Save() to fill in the Summary from the ArticleBody
+func NewArticleWithSummarizer(summarizer *Summarizer) func(*Article) {
+	return func(a *Article) {
+		a.summarizer = summarizer
+	}
+}
+
+// Sets the LanguageDetector used on Save() to fill in or correct InLanguage
+func NewArticleWithLanguageDetector(detector *LanguageDetector) func(*Article) {
+	return func(a *Article) {
+		a.languageDetector = detector
+	}
+}
+
+// New Articles whose ArticleBody is at least threshold alike to a
+// stored Article are grouped under it on Save() with DuplicateOf
+func NewArticleWithDuplicateDetection(threshold float64) func(*Article) {
+	return func(a *Article) {
+		a.duplicateThreshold = threshold
+	}
+}
+
+// Links new and changed Articles to the Authors of their AuthorsList
+// on Save(), creating the Authors that are not stored yet
+func NewArticleWithAuthors(enabled bool) func(*Article) {
+	return func(a *Article) {
+		a.authors = enabled
+	}
+}
+
+// Sets the KeywordExtractor used on Save() to set the Article's Keywords and Tags
+func NewArticleWithKeywordExtractor(extractor *KeywordExtractor) func(*Article) {
+	return func(a *Article) {
+		a.keywordExtractor = extractor
+	}
+}
+
+// Sets the EntityLinker used on Save() to find the Brands, Products
+// and companies the Article mentions
+func NewArticleWithEntityLinker(linker *EntityLinker) func(*Article) {
+	return func(a *Article) {
+		a.entityLinker = linker
+	}
+}
+
+// Records failed Parse() and Save() calls as DeadLetters
+func NewArticleWithDeadLetters(enabled bool) func(*Article) {
+	return func(a *Article) {
+		a.deadLetters = enabled
+	}
+}
+
 func (a *Article) GetCollectionName() string {
 	a.Logger.Debug("Article.GetCollectionName() called")
 	return "articles"
@@ -140,10 +254,32 @@ func (a *Article) SetID(id primitive.ObjectID) {
 	a.ID = id
 }
 
-// Saves the Model to a Data Store
+// Saves the Model to a Data Store. When dead letters are enabled
+// a failed Save() is recorded as a DeadLetter.
 func (a *Article) Save() error {
+	err := a.save()
+	if err != nil && a.deadLetters && !isQuarantined(err) {
+		payload, _ := a.Serialize()
+		recordDeadLetter(a.Logger, DeadLetterModelArticle, DeadLetterOperationSave, payload, err)
+	}
+	return err
+}
+
+func (a *Article) save() error {
 	logging := a.Logger
 	a.Logger.Debug("Article.Save() called")
+	applyTimeConfig(a.timeConfig, a)
+	if !a.isDeleted {
+		a.normalize()
+	}
+	if a.isNew && a.quarantineGate != nil {
+		if reasons := a.quarantineGate.Reasons(a.Probability, a.Validate()); len(reasons) > 0 {
+			return a.quarantine(reasons)
+		}
+	}
+	var revisionErr error
+	// -- Set when the Article was stored but the counts of its Tags were not
+	var tagErr error
 	if a.isNew {
 		a.Logger.Debug("Article.Save() is new")
 		//--Create a new document
@@ -153,15 +289,56 @@ func (a *Article) Save() error {
 			logging.Error("Article.Save() error extracting company name", err)
 			return errors.NewChuxModelsError("Article.Save() error extracting company name", err)
 		}
+		// -- The time the Article was scraped, the DateCreated it was parsed with or else now
+		scraped := a.DateCreated.Time
 		// Set the DateCreated to the current time
 		a.DateCreated.Now()
+		if scraped.IsZero() {
+			scraped = a.DateCreated.Time
+		}
+		// Fill in the dates that were only scraped as text, relative
+		// dates are anchored to the time the Article was scraped
+		parser := NewDateParser(NewDateParserWithReference(scraped))
+		if a.dateParser != nil {
+			parser = a.dateParser
+			if parser.Reference.IsZero() {
+				// -- A copy, the DateParser may be shared by Articles scraped at other times
+				anchored := *a.dateParser
+				anchored.Reference = scraped
+				parser = &anchored
+			}
+		}
+		a.ParseDates(parser)
+		if a.duplicateThreshold > 0 {
+			if err := a.markDuplicate(a.duplicateThreshold); err != nil {
+				return err
+			}
+		}
+		if a.authors {
+			if err := a.linkAuthors(); err != nil {
+				logging.Error("Article.Save() error linking Authors: %s", err.Error())
+				return errors.NewChuxModelsError("Article.Save() error linking Authors", err)
+			}
+		}
+		// The Article may have been scraped and tagged before
+		var previousTags []string
+		if len(a.Tags) > 0 || a.keywordExtractor != nil {
+			previousTags, err = a.storedTags()
+			if err != nil {
+				logging.Error("Article.Save() error reading stored Tags: %s", err.Error())
+				return errors.NewChuxModelsError("Article.Save() error reading stored Tags", err)
+			}
+		}
 		a.FilesProcessed = true
 		err = mongoDB.Upsert(a, "canonicalUrl")
 		if err != nil {
-			errors.NewChuxModelsError("Article.Save() error creating Article", err)
+			logging.Error("Article.Save() error creating Article: %s", err.Error())
+			return errors.NewChuxModelsError("Article.Save() error creating Article", err)
 		}
 
 		logging.Info("Article.Save() Successfully created new Article")
+		revisionErr = a.recordRevision()
+		tagErr = a.countTags(previousTags, a.Tags)
 
 	} else if a.IsDirty() && !a.isDeleted {
 		logging.Info("Article.Save() is dirty and not isDeleted")
@@ -174,6 +351,12 @@ func (a *Article) Save() error {
 		}
 		// Set the DateModified to the current time
 		a.DateModified.Now()
+		if a.authors {
+			if err := a.linkAuthors(); err != nil {
+				logging.Error("Article.Save() error linking Authors: %s", err.Error())
+				return errors.NewChuxModelsError("Article.Save() error linking Authors", err)
+			}
+		}
 		//--update this document
 		err = mongoDB.Update(a, a.ID.Hex())
 		if err != nil {
@@ -181,6 +364,8 @@ func (a *Article) Save() error {
 			return errors.NewChuxModelsError("Article.Save() error updating Article", err)
 		}
 		logging.Info("Article.Save() Successfully updated Article")
+		revisionErr = a.recordRevision()
+		tagErr = a.countTags(a.originalState.Tags, a.Tags)
 	} else if a.isDeleted && !a.isNew {
 		logging.Info("Article.Save() isDeleted and not isNew")
 		//--delete the document
@@ -190,6 +375,7 @@ func (a *Article) Save() error {
 			return errors.NewChuxModelsError("Article.Save() error deleting Article", err)
 		}
 		logging.Info("Article.Save() Successfully deleted Article")
+		tagErr = a.countTags(a.Tags, nil)
 	}
 
 	// If the Article has been deleted, then this is a new Article
@@ -222,9 +408,66 @@ func (a *Article) Save() error {
 		}
 	}
 
+	if revisionErr != nil {
+		logging.Error("Article.Save() error recording ArticleRevision: %s", revisionErr.Error())
+		return errors.NewChuxModelsError("Article.Save() Article was saved but its ArticleRevision was not", revisionErr)
+	}
+	if tagErr != nil {
+		logging.Error("Article.Save() error counting Tags: %s", tagErr.Error())
+		return errors.NewChuxModelsError("Article.Save() Article was saved but the counts of its Tags were not", tagErr)
+	}
+
 	return nil
 }
 
+// Cleans up the Article's body and derives the fields
+// that are computed from it before the Article is saved
+func (a *Article) normalize() {
+	a.SanitizeHTML()
+	a.NormalizeAuthors()
+	if a.markdown {
+		a.ArticleBodyMarkdown = a.Markdown()
+	}
+	stats := a.ReadingStats()
+	a.Reading = &stats
+	detector := a.languageDetector
+	if detector == nil {
+		detector = NewLanguageDetector()
+	}
+	a.DetectLanguage(detector)
+	a.ComputeFingerprint()
+	if a.keywordExtractor != nil {
+		a.ExtractTags(a.keywordExtractor)
+	}
+	if a.entityLinker != nil {
+		a.LinkEntities(a.entityLinker)
+	}
+	// -- The Summary is kept apart from the scraped Description and
+	// made again so that it follows changes to the ArticleBody
+	summarizer := a.summarizer
+	if summarizer == nil {
+		summarizer = NewSummarizer()
+	}
+	a.Summary = a.Summarize(summarizer)
+}
+
+// Stores the Article as a QuarantinedItem instead of saving it
+func (a *Article) quarantine(reasons []string) error {
+	logging := a.Logger
+	logging.Info("Article.Save() Article %s is quarantined: %s", a.CanonicalURL, strings.Join(reasons, "; "))
+	serialized, err := a.Serialize()
+	if err != nil {
+		return err
+	}
+	companyName, _ := ExtractCompanyName(a.CanonicalURL)
+	err = quarantine(a.Logger, QuarantineKindArticle, a.CanonicalURL, companyName, a.Probability, reasons, serialized)
+	if err != nil {
+		logging.Error("Article.Save() Error quarantining Article: %s", err.Error())
+		return errors.NewChuxModelsError("Article.Save() Error quarantining Article", err)
+	}
+	return errors.NewChuxModelsError("Article.Save() Article was quarantined: "+strings.Join(reasons, "; "), ErrQuarantined)
+}
+
 // Loads a Model from MongoDB by id
 func (a *Article) Load(id string) (interface{}, error) {
 	logging := a.Logger
@@ -287,9 +530,31 @@ func (a *Article) SetState(json string) error {
 	return a.Deserialize([]byte(json))
 }
 
+// Sets the state of an Article that was read from the Data Store
+// so that it is not new and changes to it are tracked
+func (a *Article) markLoaded() error {
+	serialized, err := a.Serialize()
+	if err != nil {
+		return errors.NewChuxModelsError("Article.markLoaded() Error serializing Article", err)
+	}
+	a.SetState(serialized)
+	a.isNew = false
+	a.isDirty = false
+	a.isDeleted = false
+	return nil
+}
+
 // Sets the internal state of the model of a new Product
 // from a JSON String.
 func (a *Article) Parse(json string) error {
+	err := a.parse(json)
+	if err != nil && a.deadLetters {
+		recordDeadLetter(a.Logger, DeadLetterModelArticle, DeadLetterOperationParse, json, err)
+	}
+	return err
+}
+
+func (a *Article) parse(json string) error {
 	logging := a.Logger
 
 	logging.Debug("Article.Parse() called")
@@ -310,6 +575,7 @@ func (a *Article) Search(args ...interface{}) ([]interface{}, error) {
 func (a *Article) Serialize() (string, error) {
 	logging := a.Logger
 	logging.Debug("Article.Serialize() called")
+	applyTimeConfig(a.timeConfig, a)
 	bytes, err := json.Marshal(a)
 	if err != nil {
 		logging.Error("Article.Serialize() unable to serialize Article", err)
@@ -321,6 +587,7 @@ func (a *Article) Serialize() (string, error) {
 func (a *Article) Deserialize(jsonData []byte) error {
 	logging := a.Logger
 	logging.Debug("Article.Deserialize() called")
+	applyTimeConfig(a.timeConfig, a)
 	err := json.Unmarshal(jsonData, a)
 	if err != nil {
 		logging.Error("Article.Deserialize() unable to deserialize Article", err)
@@ -0,0 +1,460 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/chuxorg/chux-datastore/db"
+	dbl "github.com/chuxorg/chux-datastore/logging"
+	"github.com/chuxorg/chux-models/errors"
+	"github.com/chuxorg/chux-models/logging"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The Article fields a revision is recorded for when they change
+var revisionFields = []string{"headline", "description", "author", "articleBody"}
+
+// ArticleRevision is a version of an Article's content. Revisions are
+// append only, one is stored each time an Article is saved with content
+// that differs from its last revision. Each revision holds the whole
+// content of its version and the word level diffs of the Headline and
+// ArticleBody from the version before it. Revisions are keyed by
+// CanonicalURL since that is what Articles are upserted by.
+type ArticleRevision struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	ArticleID    primitive.ObjectID `bson:"articleId,omitempty" json:"articleId,omitempty"`
+	CanonicalURL string             `bson:"canonicalUrl" json:"canonicalUrl"`
+	// Revisions of an Article are numbered from 1
+	Revision  int        `bson:"revision" json:"revision"`
+	RevisedAt CustomTime `bson:"revisedAt" json:"revisedAt"`
+	// The fields that differ from the revision before, all of them for the first revision
+	Changed      []string          `bson:"changed" json:"changed"`
+	Headline     string            `bson:"headline" json:"headline"`
+	Description  string            `bson:"description" json:"description"`
+	Author       string            `bson:"author" json:"author"`
+	ArticleBody  string            `bson:"articleBody" json:"articleBody"`
+	HeadlineDiff []DiffOp          `bson:"headlineDiff,omitempty" json:"headlineDiff,omitempty"`
+	BodyDiff     []DiffOp          `bson:"bodyDiff,omitempty" json:"bodyDiff,omitempty"`
+	isNew        bool              `bson:"-" json:"-"`
+	timeConfig   *CustomTimeConfig `bson:"-" json:"-"`
+	Logger       *logging.Logger   `bson:"-" json:"-"`
+}
+
+func NewArticleRevision(options ...func(*ArticleRevision)) *ArticleRevision {
+
+	r := &ArticleRevision{}
+
+	for _, option := range options {
+		option(r)
+	}
+	dbLogger := dbl.NewLogger(dbl.LogLevelDebug)
+	mongoDB = db.New(
+		db.WithURI(r.GetURI()),
+		db.WithDatabaseName(r.GetDatabaseName()),
+		db.WithCollectionName(r.GetCollectionName()),
+		db.WithTimeout(30),
+		db.WithLogger(*dbLogger),
+	)
+
+	r.isNew = true
+	return r
+}
+
+func NewArticleRevisionWithLogger(logger logging.Logger) func(*ArticleRevision) {
+	return func(r *ArticleRevision) {
+		r.Logger = &logger
+	}
+}
+
+// Sets how the ArticleRevision's CustomTimes are written to JSON and stored in Mongo,
+// without it they use the DefaultCustomTimeConfig
+func NewArticleRevisionWithTimeConfig(config CustomTimeConfig) func(*ArticleRevision) {
+	return func(r *ArticleRevision) {
+		r.timeConfig = &config
+	}
+}
+
+func (r *ArticleRevision) GetCollectionName() string {
+	logging := r.Logger
+	logging.Debug("ArticleRevision.GetCollectionName() was called")
+	return "articleRevisions"
+}
+
+func (r *ArticleRevision) GetDatabaseName() string {
+	logging := r.Logger
+	logging.Debug("ArticleRevision.GetDatabaseName() was called")
+	return os.Getenv("MONGO_DATABASE")
+}
+
+func (r *ArticleRevision) GetURI() string {
+	logging := r.Logger
+	logging.Debug("ArticleRevision.GetURI() was called")
+	username := os.Getenv("MONGO_USER_NAME")
+	password := os.Getenv("MONGO_PASSWORD")
+
+	uri := os.Getenv("MONGO_URI")
+	mongoURI := fmt.Sprintf(uri, username, password)
+	masked := fmt.Sprintf(uri, "********", "********")
+	logging.Info("Mongo URI: %s", masked)
+	return mongoURI
+}
+
+func (r *ArticleRevision) GetID() primitive.ObjectID {
+	logging := r.Logger
+	logging.Debug("ArticleRevision.GetID() was called")
+	return r.ID
+}
+
+func (r *ArticleRevision) SetID(id primitive.ObjectID) {
+	logging := r.Logger
+	logging.Debug("ArticleRevision.SetID() was called")
+	r.ID = id
+}
+
+// Revisions are never changed once stored
+func (r *ArticleRevision) IsDirty() bool {
+	return false
+}
+
+// When the Model is first created,
+// the model is considered New. After the model is
+// Saved or Loaded it is no longer New
+func (r *ArticleRevision) IsNew() bool {
+	logging := r.Logger
+	logging.Debug("ArticleRevision.IsNew() was called")
+	return r.isNew
+}
+
+// Saves a new ArticleRevision to the Data Store. Stored
+// revisions are immutable and saving them again is an error.
+func (r *ArticleRevision) Save() error {
+	logging := r.Logger
+	logging.Debug("ArticleRevision.Save() was called")
+	applyTimeConfig(r.timeConfig, r)
+	if !r.isNew {
+		logging.Error("ArticleRevision.Save() ArticleRevision %s is already stored", r.ID.Hex())
+		return errors.NewChuxModelsError("ArticleRevision.Save() ArticleRevisions are append only", nil)
+	}
+	if r.RevisedAt.IsZero() {
+		r.RevisedAt.Now()
+	}
+	// -- Revisions are only ever inserted, so give it an ID up front
+	if r.ID.IsZero() {
+		r.ID = primitive.NewObjectID()
+	}
+	// -- Two saves of an Article at the same time must not both store the same revision
+	collection, ctx, cancel, err := collectionOf(r)
+	if err != nil {
+		logging.Error("ArticleRevision.Save() Error connecting to MongoDB: %s", err.Error())
+		return errors.NewChuxModelsError("ArticleRevision.Save() Error connecting to MongoDB", err)
+	}
+	defer cancel()
+	if err := ensureUniqueIndex(ctx, collection, "canonicalUrl", "revision"); err != nil {
+		logging.Error("ArticleRevision.Save() Error creating the revision index: %s", err.Error())
+		return errors.NewChuxModelsError("ArticleRevision.Save() Error creating the revision index", err)
+	}
+	err = mongoDB.Upsert(r)
+	if err != nil {
+		logging.Error("ArticleRevision.Save() Error creating ArticleRevision in MongoDB: %s", err.Error())
+		return errors.NewChuxModelsError("ArticleRevision.Save() Error creating ArticleRevision in MongoDB", err)
+	}
+	r.isNew = false
+	logging.Info("ArticleRevision.Save() ArticleRevision saved successfully")
+	return nil
+}
+
+// Loads a Model from MongoDB by id
+func (r *ArticleRevision) Load(id string) (interface{}, error) {
+	logging := r.Logger
+	logging.Debug("ArticleRevision.Load() was called")
+
+	retVal, err := mongoDB.GetByID(r, id)
+	if err != nil {
+		logging.Error("ArticleRevision.Load() Error loading ArticleRevision from MongoDB: %s", err.Error())
+		return nil, errors.NewChuxModelsError("ArticleRevision.Load() Error loading ArticleRevision from MongoDB", err)
+	}
+	revision, ok := retVal.(*ArticleRevision)
+	if !ok {
+		logging.Error("ArticleRevision.Load() unable to cast retVal to *ArticleRevision")
+		return nil, errors.NewChuxModelsError("ArticleRevision.Load() unable to cast retVal to *ArticleRevision", nil)
+	}
+	revision.isNew = false
+	r.isNew = false
+	logging.Info("ArticleRevision.Load() ArticleRevision loaded successfully")
+	return retVal, nil
+}
+
+func (r *ArticleRevision) Query(args ...interface{}) ([]db.IMongoDocument, error) {
+	logging := r.Logger
+	logging.Debug("ArticleRevision.Query() was called")
+
+	results, err := mongoDB.Query(r, args...)
+	if err != nil {
+		logging.Error("ArticleRevision.Query() Error occurred querying ArticleRevisions: %s", err.Error())
+		return nil, errors.NewChuxModelsError("ArticleRevision.Query() Error occurred querying ArticleRevisions", err)
+	}
+	logging.Info("ArticleRevision.Query() ArticleRevisions queried successfully")
+	return results, nil
+}
+
+// Revisions are append only and cannot be deleted
+func (r *ArticleRevision) Delete() error {
+	logging := r.Logger
+	logging.Debug("ArticleRevision.Delete() was called")
+	return errors.NewChuxModelsError("ArticleRevision.Delete() ArticleRevisions are append only", nil)
+}
+
+// Sets the internal state of the model of a new ArticleRevision
+// from a JSON String.
+func (r *ArticleRevision) Parse(json string) error {
+	logging := r.Logger
+	logging.Debug("ArticleRevision.Parse() was called")
+	err := r.Deserialize([]byte(json))
+	if err != nil {
+		logging.Error("ArticleRevision.Parse() error setting state")
+		return errors.NewChuxModelsError("ArticleRevision.Parse() Error setting state", err)
+	}
+	r.isNew = true // this is a new model
+	return nil
+}
+
+func (r *ArticleRevision) Search(args ...interface{}) ([]interface{}, error) {
+	logging := r.Logger
+	logging.Debug("ArticleRevision.Search() was called")
+	return nil, nil
+}
+
+func (r *ArticleRevision) Serialize() (string, error) {
+	logging := r.Logger
+	logging.Debug("ArticleRevision.Serialize() was called")
+	applyTimeConfig(r.timeConfig, r)
+	bytes, err := json.Marshal(r)
+	if err != nil {
+		logging.Error("ArticleRevision.Serialize() error occurred: %s", err.Error())
+		return "", errors.NewChuxModelsError("ArticleRevision.Serialize() error occurred", err)
+	}
+	return string(bytes), nil
+}
+
+func (r *ArticleRevision) Deserialize(jsonData []byte) error {
+	logging := r.Logger
+	logging.Debug("ArticleRevision.Deserialize() was called")
+	applyTimeConfig(r.timeConfig, r)
+	err := json.Unmarshal(jsonData, r)
+	if err != nil {
+		logging.Error("ArticleRevision.Deserialize() error occurred: %s", err.Error())
+		return errors.NewChuxModelsError("ArticleRevision.Deserialize() error occurred", err)
+	}
+	return nil
+}
+
+// Returns the value of one of the revisionFields
+func (r *ArticleRevision) field(name string) string {
+	switch name {
+	case "headline":
+		return r.Headline
+	case "description":
+		return r.Description
+	case "author":
+		return r.Author
+	case "articleBody":
+		return r.ArticleBody
+	}
+	return ""
+}
+
+// Article returns the Article as it was at this revision. Only the
+// fields revisions are recorded for are filled in besides its URL.
+func (r *ArticleRevision) Article() *Article {
+	return &Article{
+		ID:           r.ArticleID,
+		CanonicalURL: r.CanonicalURL,
+		Headline:     r.Headline,
+		Description:  r.Description,
+		Author:       r.Author,
+		ArticleBody:  r.ArticleBody,
+		Logger:       r.Logger,
+	}
+}
+
+// Revise returns a new ArticleRevision of the Article's current content
+// that follows previous, or nil when the content has not changed since.
+// The first revision of an Article has no previous revision.
+func (a *Article) Revise(previous *ArticleRevision) *ArticleRevision {
+	revision := &ArticleRevision{
+		ArticleID:    a.ID,
+		CanonicalURL: a.CanonicalURL,
+		Revision:     1,
+		Headline:     a.Headline,
+		Description:  a.Description,
+		Author:       a.Author,
+		ArticleBody:  a.ArticleBody,
+		Logger:       a.Logger,
+		isNew:        true,
+	}
+	revision.RevisedAt.Now()
+	if previous == nil {
+		revision.Changed = append(revision.Changed, revisionFields...)
+		return revision
+	}
+
+	for _, name := range revisionFields {
+		if revision.field(name) != previous.field(name) {
+			revision.Changed = append(revision.Changed, name)
+		}
+	}
+	if len(revision.Changed) == 0 {
+		return nil
+	}
+	revision.Revision = previous.Revision + 1
+	if revision.Headline != previous.Headline {
+		revision.HeadlineDiff = DiffWords(previous.Headline, revision.Headline)
+	}
+	if revision.ArticleBody != previous.ArticleBody {
+		revision.BodyDiff = DiffWords(previous.ArticleBody, revision.ArticleBody)
+	}
+	return revision
+}
+
+// Records an ArticleRevision when the Article's content differs from
+// its last revision. Called by Save() once the Article has been stored.
+func (a *Article) recordRevision() error {
+	logging := a.Logger
+	logging.Debug("Article.recordRevision() was called")
+	previous, err := latestArticleRevision(logging, a.CanonicalURL)
+	if err != nil {
+		return err
+	}
+	revision := a.Revise(previous)
+	if revision == nil {
+		return nil
+	}
+	return revision.Save()
+}
+
+// Returns the last revision of an Article, or nil when it has none
+func latestArticleRevision(logging *logging.Logger, canonicalURL string) (*ArticleRevision, error) {
+	collection, ctx, cancel, err := collectionOf(&ArticleRevision{Logger: logging})
+	if err != nil {
+		logging.Error("latestArticleRevision() Error connecting to MongoDB: %s", err.Error())
+		return nil, errors.NewChuxModelsError("latestArticleRevision() Error connecting to MongoDB", err)
+	}
+	defer cancel()
+	latest := &ArticleRevision{}
+	err = collection.FindOne(ctx, bson.M{"canonicalUrl": canonicalURL},
+		options.FindOne().SetSort(bson.D{{Key: "revision", Value: -1}})).Decode(latest)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		logging.Error("latestArticleRevision() Error querying the last revision of %s: %s", canonicalURL, err.Error())
+		return nil, errors.NewChuxModelsError("latestArticleRevision() Error querying the last revision", err)
+	}
+	latest.Logger = logging
+	return latest, nil
+}
+
+// Returns the revisions of an Article, first revision first
+func queryArticleRevisions(logging *logging.Logger, canonicalURL string) ([]*ArticleRevision, error) {
+	query := &ArticleRevision{Logger: logging}
+	docs, err := query.Query("canonicalUrl", canonicalURL)
+	if err != nil {
+		return nil, err
+	}
+	revisions := make([]*ArticleRevision, 0, len(docs))
+	for _, doc := range docs {
+		revision := doc.(*ArticleRevision)
+		revision.Logger = logging
+		revisions = append(revisions, revision)
+	}
+	sort.SliceStable(revisions, func(i, j int) bool {
+		return revisions[i].Revision < revisions[j].Revision
+	})
+	return revisions, nil
+}
+
+// ListArticleRevisions returns the revisions of the Article
+// with canonicalURL, first revision first
+func ListArticleRevisions(logging logging.Logger, canonicalURL string) ([]*ArticleRevision, error) {
+	NewArticleRevision(NewArticleRevisionWithLogger(logging))
+	revisions, err := queryArticleRevisions(&logging, canonicalURL)
+	if err != nil {
+		return nil, err
+	}
+	logging.Info("ListArticleRevisions() Found %d revisions of %s", len(revisions), canonicalURL)
+	return revisions, nil
+}
+
+// GetArticleRevision returns one revision of the Article with canonicalURL
+func GetArticleRevision(logging logging.Logger, canonicalURL string, revision int) (*ArticleRevision, error) {
+	query := NewArticleRevision(NewArticleRevisionWithLogger(logging))
+	docs, err := query.Query("canonicalUrl", canonicalURL, "revision", revision)
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		msg := fmt.Sprintf("GetArticleRevision() %s has no revision %d", canonicalURL, revision)
+		logging.Error(msg)
+		return nil, errors.NewChuxModelsError(msg, nil)
+	}
+	found := docs[0].(*ArticleRevision)
+	found.Logger = &logging
+	return found, nil
+}
+
+// Revisions returns the Article's revisions, first revision first
+func (a *Article) Revisions() ([]*ArticleRevision, error) {
+	logging := a.Logger
+	logging.Debug("Article.Revisions() was called")
+	return queryArticleRevisions(logging, a.CanonicalURL)
+}
+
+// RevisionDiff is how an Article's content changed between two revisions
+type RevisionDiff struct {
+	From     int      `json:"from"`
+	To       int      `json:"to"`
+	Changed  []string `json:"changed"`
+	Headline []DiffOp `json:"headline"`
+	Body     []DiffOp `json:"body"`
+}
+
+// DiffArticleRevisions compares any two revisions of an Article
+func DiffArticleRevisions(from, to *ArticleRevision) RevisionDiff {
+	diff := RevisionDiff{
+		From:     from.Revision,
+		To:       to.Revision,
+		Headline: DiffWords(from.Headline, to.Headline),
+		Body:     DiffWords(from.ArticleBody, to.ArticleBody),
+	}
+	for _, name := range revisionFields {
+		if from.field(name) != to.field(name) {
+			diff.Changed = append(diff.Changed, name)
+		}
+	}
+	return diff
+}
+
+// Render writes the diff as text, the headline and body changes in the
+// style of git's word diff, deleted text as [-text-] and inserted as {+text+}
+func (d RevisionDiff) Render() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "revision %d..%d\n", d.From, d.To)
+	if len(d.Changed) == 0 {
+		b.WriteString("no changes\n")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "changed: %s\n", strings.Join(d.Changed, ", "))
+	if DiffChanged(d.Headline) {
+		b.WriteString("\nheadline:\n" + RenderDiff(d.Headline) + "\n")
+	}
+	if DiffChanged(d.Body) {
+		b.WriteString("\narticleBody:\n" + RenderDiff(d.Body) + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,174 @@
+package models
+
+import (
+	"strings"
+
+	"github.com/chuxorg/chux-datastore/db"
+	"github.com/chuxorg/chux-models/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Common AdditionalProperty names, normalized, mapped to a canonical attribute key
+var attributeKeys = map[string]string{
+	"weight":                  "weight",
+	"item weight":             "weight",
+	"product weight":          "weight",
+	"net weight":              "weight",
+	"unit weight":             "weight",
+	"shipping weight":         "shipping_weight",
+	"package weight":          "shipping_weight",
+	"dimensions":              "dimensions",
+	"product dimensions":      "dimensions",
+	"item dimensions":         "dimensions",
+	"assembled dimensions":    "dimensions",
+	"size l x w x h":          "dimensions",
+	"package dimensions":      "package_dimensions",
+	"shipping dimensions":     "package_dimensions",
+	"height":                  "height",
+	"product height":          "height",
+	"item height":             "height",
+	"width":                   "width",
+	"product width":           "width",
+	"item width":              "width",
+	"depth":                   "depth",
+	"product depth":           "depth",
+	"item depth":              "depth",
+	"length":                  "length",
+	"product length":          "length",
+	"item length":             "length",
+	"screen size":             "screen_size",
+	"display size":            "screen_size",
+	"volume":                  "volume",
+	"capacity":                "capacity",
+	"liquid volume":           "volume",
+	"net content":             "volume",
+	"net contents":            "volume",
+	"battery capacity":        "battery_capacity",
+	"power":                   "power",
+	"wattage":                 "power",
+	"output power":            "power",
+	"rated power":             "power",
+	"storage":                 "storage",
+	"storage capacity":        "storage",
+	"hard drive capacity":     "storage",
+	"hard disk size":          "storage",
+	"internal storage":        "storage",
+	"memory":                  "memory",
+	"ram":                     "memory",
+	"memory size":             "memory",
+	"installed ram":           "memory",
+	"system memory ram":       "memory",
+	"count":                   "count",
+	"unit count":              "count",
+	"number of pieces":        "count",
+	"pack size":               "count",
+	"quantity":                "count",
+	"number of items":         "count",
+	"item package quantity":   "count",
+	"maximum weight capacity": "weight_capacity",
+	"weight capacity":         "weight_capacity",
+	"load capacity":           "weight_capacity",
+}
+
+// Attribute is an AdditionalProperty with a canonical key
+// and its value parsed into quantities.
+type Attribute struct {
+	Key        string     `bson:"key" json:"key"`
+	Name       string     `bson:"name" json:"name"`
+	Value      string     `bson:"value" json:"value"`
+	Quantities []Quantity `bson:"quantities,omitempty" json:"quantities,omitempty"`
+}
+
+// Quantity returns the first quantity of the Attribute
+func (a Attribute) Quantity() (Quantity, bool) {
+	if len(a.Quantities) == 0 {
+		return Quantity{}, false
+	}
+	return a.Quantities[0], true
+}
+
+// CanonicalAttributeKey maps a property name such as "Item Weight"
+// to its canonical key, "weight". Unknown names are returned in
+// snake case, e.g. "Frame Material" becomes "frame_material".
+func CanonicalAttributeKey(name string) string {
+	normalized := NormalizeText(name)
+	if key, ok := attributeKeys[normalized]; ok {
+		return key
+	}
+	return strings.ReplaceAll(normalized, " ", "_")
+}
+
+// NormalizeAttributes converts AdditionalProperties into Attributes.
+// A property without a name whose value reads "Weight: 2.5 lbs" is
+// split into its name and value. Count values without a unit, e.g.
+// "Pack Size: 3", are read as a count.
+func NormalizeAttributes(properties []AdditionalProperty) []Attribute {
+	attributes := make([]Attribute, 0, len(properties))
+	for _, property := range properties {
+		name := strings.TrimSpace(property.Name)
+		value := strings.TrimSpace(property.Value)
+		if name == "" {
+			if idx := strings.Index(value, ":"); idx > 0 {
+				name = strings.TrimSpace(value[:idx])
+				value = strings.TrimSpace(value[idx+1:])
+			}
+		}
+		if name == "" || value == "" {
+			continue
+		}
+
+		attribute := Attribute{
+			Key:   CanonicalAttributeKey(name),
+			Name:  name,
+			Value: value,
+		}
+		attribute.Quantities = ParseQuantities(value)
+		if len(attribute.Quantities) == 0 && attribute.Key == "count" {
+			if n, ok := leadingNumber(value); ok {
+				q, _ := NewQuantity(n, "ct")
+				attribute.Quantities = []Quantity{q}
+			}
+		}
+		attributes = append(attributes, attribute)
+	}
+	return attributes
+}
+
+// NormalizeAttributes sets the Product's Attributes from its AdditionalProperties
+func (p *Product) NormalizeAttributes() {
+	logging := p.Logger
+	logging.Debug("Product.NormalizeAttributes() was called")
+	p.Attributes = NormalizeAttributes(p.AdditionalProperties)
+}
+
+// Attribute returns the Product's Attribute with the canonical key
+func (p *Product) Attribute(key string) (Attribute, bool) {
+	for _, attribute := range p.Attributes {
+		if attribute.Key == key {
+			return attribute, true
+		}
+	}
+	return Attribute{}, false
+}
+
+// QueryByAttribute returns the Products with an Attribute whose value,
+// in SI units, is between min and max inclusive. For example products
+// weighing 1 to 2 kilograms: QueryByAttribute("weight", 1, 2)
+func (p *Product) QueryByAttribute(key string, min, max float64) ([]db.IMongoDocument, error) {
+	logging := p.Logger
+	logging.Debug("Product.QueryByAttribute() was called")
+	filter := bson.M{
+		"$elemMatch": bson.M{
+			"key": key,
+			"quantities": bson.M{
+				"$elemMatch": bson.M{"siValue": bson.M{"$gte": min, "$lte": max}},
+			},
+		},
+	}
+	results, err := mongoDB.Query(p, "attributes", filter)
+	if err != nil {
+		logging.Error("Product.QueryByAttribute() Error occurred querying Products: %s", err.Error())
+		return nil, errors.NewChuxModelsError("Product.QueryByAttribute() Error occurred querying Products", err)
+	}
+	return results, nil
+}
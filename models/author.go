@@ -0,0 +1,431 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/chuxorg/chux-datastore/db"
+	dbl "github.com/chuxorg/chux-datastore/logging"
+	"github.com/chuxorg/chux-models/errors"
+	"github.com/chuxorg/chux-models/logging"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Author is a writer of Articles, identified by the key of their
+// name, with the other spellings of the name bylines use and the
+// publications they have written for. Articles link to their
+// Authors with AuthorIDs. Authors with the same name on different
+// sites are taken to be the same person.
+type Author struct {
+	ID   primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	Name string             `bson:"name" json:"name"`
+	// The name normalized without initials, Authors are unique by it
+	NameKey       string            `bson:"nameKey" json:"nameKey"`
+	Variants      []string          `bson:"variants" json:"variants"`
+	Publications  []string          `bson:"publications" json:"publications"`
+	DateCreated   CustomTime        `bson:"dateCreated,omitempty" json:"dateCreated,omitempty"`
+	DateModified  CustomTime        `bson:"dateModified,omitempty" json:"dateModified,omitempty"`
+	isNew         bool              `bson:"-" json:"-"`
+	isDeleted     bool              `bson:"-" json:"-"`
+	isDirty       bool              `bson:"-" json:"-"`
+	originalState *Author           `bson:"-" json:"-"`
+	timeConfig    *CustomTimeConfig `bson:"-" json:"-"`
+	Logger        *logging.Logger   `bson:"-" json:"-"`
+}
+
+func NewAuthor(options ...func(*Author)) *Author {
+
+	au := &Author{}
+
+	for _, option := range options {
+		option(au)
+	}
+	dbLogger := dbl.NewLogger(dbl.LogLevelDebug)
+	mongoDB = db.New(
+		db.WithURI(au.GetURI()),
+		db.WithDatabaseName(au.GetDatabaseName()),
+		db.WithCollectionName(au.GetCollectionName()),
+		db.WithTimeout(30),
+		db.WithLogger(*dbLogger),
+	)
+
+	au.isNew = true
+	au.isDeleted = false
+	au.isDirty = false
+	return au
+}
+
+func NewAuthorWithLogger(logger logging.Logger) func(*Author) {
+	return func(au *Author) {
+		au.Logger = &logger
+	}
+}
+
+// Sets how the Author's CustomTimes are written to JSON and stored in Mongo,
+// without it they use the DefaultCustomTimeConfig
+func NewAuthorWithTimeConfig(config CustomTimeConfig) func(*Author) {
+	return func(au *Author) {
+		au.timeConfig = &config
+	}
+}
+
+func (au *Author) GetCollectionName() string {
+	logging := au.Logger
+	logging.Debug("Author.GetCollectionName() was called")
+	return "authors"
+}
+
+func (au *Author) GetDatabaseName() string {
+	logging := au.Logger
+	logging.Debug("Author.GetDatabaseName() was called")
+	return os.Getenv("MONGO_DATABASE")
+}
+
+func (au *Author) GetURI() string {
+	logging := au.Logger
+	logging.Debug("Author.GetURI() was called")
+	username := os.Getenv("MONGO_USER_NAME")
+	password := os.Getenv("MONGO_PASSWORD")
+
+	uri := os.Getenv("MONGO_URI")
+	mongoURI := fmt.Sprintf(uri, username, password)
+	masked := fmt.Sprintf(uri, "********", "********")
+	logging.Info("Mongo URI: %s", masked)
+	return mongoURI
+}
+
+func (au *Author) GetID() primitive.ObjectID {
+	logging := au.Logger
+	logging.Debug("Author.GetID() was called")
+	return au.ID
+}
+
+func (au *Author) SetID(id primitive.ObjectID) {
+	logging := au.Logger
+	logging.Debug("Author.SetID() was called")
+	au.ID = id
+}
+
+// If the Model has changes, will return true
+func (au *Author) IsDirty() bool {
+	logging := au.Logger
+	logging.Debug("Author.IsDirty() was called")
+	if au.originalState == nil {
+		return false
+	}
+
+	originalBytes, err := au.originalState.Serialize()
+	if err != nil {
+		return false
+	}
+
+	currentBytes, err := au.Serialize()
+	if err != nil {
+		return false
+	}
+
+	au.isDirty = string(originalBytes) != string(currentBytes)
+	logging.Info("Author.IsDirty() isDirty: %t", au.isDirty)
+	return au.isDirty
+}
+
+// When the Model is first created,
+// the model is considered New. After the model is
+// Saved or Loaded it is no longer New
+func (au *Author) IsNew() bool {
+	logging := au.Logger
+	logging.Debug("Author.IsNew() was called")
+	return au.isNew
+}
+
+// Saves the Model to a Data Store
+func (au *Author) Save() error {
+	logging := au.Logger
+	logging.Debug("Author.Save() was called")
+	applyTimeConfig(au.timeConfig, au)
+	if au.isNew {
+		logging.Debug("Author.Save() Author is new")
+		// -- Set the date created to now
+		au.DateCreated.Now()
+		//-- Upsert document, an Author is unique by the key of their name
+		if au.NameKey == "" {
+			au.NameKey = AuthorKey(au.Name)
+		}
+		err := mongoDB.Upsert(au, "nameKey")
+		if err != nil {
+			logging.Error("Author.Save() Error creating Author in MongoDB: %s", err.Error())
+			return errors.NewChuxModelsError("Author.Save() Error creating Author in MongoDB", err)
+		}
+	} else if au.IsDirty() && !au.isDeleted {
+		logging.Debug("Author.Save() Author is dirty")
+		// Ensure the ID is a valid hex string representation of an ObjectID
+		_, err := primitive.ObjectIDFromHex(au.ID.Hex())
+		if err != nil {
+			logging.Error("Author.Save() invalid ObjectID: %s", err.Error())
+			return errors.NewChuxModelsError("Author.Save() invalid ObjectID", err)
+		}
+		// -- Set the date modified to now
+		au.DateModified.Now()
+		//--update this document
+		err = mongoDB.Update(au, au.ID.Hex())
+		if err != nil {
+			logging.Error("Author.Save() Error updating Author in MongoDB: %s", err.Error())
+			return errors.NewChuxModelsError("Author.Save() Error updating Author in MongoDB", err)
+		}
+	} else if au.isDeleted && !au.isNew {
+		logging.Info("Author.Save() Author is deleted")
+		//--delete the document
+		err := mongoDB.Delete(au, au.ID.Hex())
+		if err != nil {
+			logging.Error("Author.Save() Error deleting Author in MongoDB: %s", err.Error())
+			return errors.NewChuxModelsError("Author.Save() Error deleting Author in MongoDB", err)
+		}
+	}
+
+	// If the Author has been deleted, then this is a new Author
+	au.isNew = au.isDeleted
+	au.isDirty = au.IsDirty()
+	au.isDeleted = false
+
+	if au.isNew {
+		au.originalState = nil
+	} else {
+		//--reset state
+		serialized, err := au.Serialize()
+		if err != nil {
+			logging.Error("Author.Save() Error serializing Author: %s", err.Error())
+			return errors.NewChuxModelsError("Author.Save() Error serializing Author.", err)
+		}
+		au.SetState(serialized)
+	}
+
+	logging.Info("Author.Save() Author saved successfully")
+	return nil
+}
+
+// Loads a Model from MongoDB by id
+func (au *Author) Load(id string) (interface{}, error) {
+	logging := au.Logger
+	logging.Debug("Author.Load() was called")
+
+	retVal, err := mongoDB.GetByID(au, id)
+	if err != nil {
+		logging.Error("Author.Load() Error loading Author from MongoDB: %s", err.Error())
+		return nil, errors.NewChuxModelsError("Author.Load() Error loading Author from MongoDB", err)
+	}
+	cluster, ok := retVal.(*Author)
+	if !ok {
+		logging.Error("Author.Load() unable to cast retVal to *Author")
+		return nil, errors.NewChuxModelsError("Author.Load() unable to cast retVal to *Author", nil)
+	}
+	err = cluster.markLoaded()
+	if err != nil {
+		logging.Error("Author.Load() Error setting state: %s", err.Error())
+		return nil, errors.NewChuxModelsError("Author.Load() Error setting state", err)
+	}
+	logging.Info("Author.Load() Author loaded successfully")
+	return retVal, nil
+}
+
+func (au *Author) Query(args ...interface{}) ([]db.IMongoDocument, error) {
+	logging := au.Logger
+	logging.Debug("Author.Query() was called")
+
+	results, err := mongoDB.Query(au, args...)
+	if err != nil {
+		logging.Error("Author.Query() Error occurred querying Authors: %s", err.Error())
+		return nil, errors.NewChuxModelsError("Author.Query() Error occurred querying Authors", err)
+	}
+	logging.Info("Author.Query() Authors queried successfully")
+	return results, nil
+}
+
+// Marks a Model for deletion from the Data Store
+// when Save() is called, the Model will be deleted
+func (au *Author) Delete() error {
+	logging := au.Logger
+	logging.Debug("Author.Delete() was called")
+	au.isDeleted = true
+	return nil
+}
+
+// Sets the internal state of the model.
+func (au *Author) SetState(json string) error {
+	logging := au.Logger
+	logging.Debug("Author.SetState() was called")
+	// Store the current state as the original state
+	original := &Author{}
+	*original = *au
+	au.originalState = original
+
+	// Deserialize the new state
+	return au.Deserialize([]byte(json))
+}
+
+// Marks an Author returned by Query() as loaded so that
+// changes made to it are persisted by Save()
+func (au *Author) markLoaded() error {
+	serialized, err := au.Serialize()
+	if err != nil {
+		return errors.NewChuxModelsError("Author.markLoaded() Error serializing Author", err)
+	}
+	au.SetState(serialized)
+	au.isNew = false
+	au.isDirty = false
+	au.isDeleted = false
+	return nil
+}
+
+// Sets the internal state of the model of a new Author
+// from a JSON String.
+func (au *Author) Parse(json string) error {
+	logging := au.Logger
+	logging.Debug("Author.Parse() was called")
+	err := au.SetState(json)
+	if err != nil {
+		logging.Error("Author.Parse() error setting state")
+		return errors.NewChuxModelsError("Author.Parse() Error setting state", err)
+	}
+	au.isNew = true // this is a new model
+	return nil
+}
+
+func (au *Author) Search(args ...interface{}) ([]interface{}, error) {
+	logging := au.Logger
+	logging.Debug("Author.Search() was called")
+	return nil, nil
+}
+
+func (au *Author) Serialize() (string, error) {
+	logging := au.Logger
+	logging.Debug("Author.Serialize() was called")
+	applyTimeConfig(au.timeConfig, au)
+	bytes, err := json.Marshal(au)
+	if err != nil {
+		logging.Error("Author.Serialize() error occurred: %s", err.Error())
+		return "", errors.NewChuxModelsError("Author.Serialize() error occurred", err)
+	}
+	return string(bytes), nil
+}
+
+func (au *Author) Deserialize(jsonData []byte) error {
+	logging := au.Logger
+	logging.Debug("Author.Deserialize() was called")
+	applyTimeConfig(au.timeConfig, au)
+	err := json.Unmarshal(jsonData, au)
+	if err != nil {
+		logging.Error("Author.Deserialize() error occurred: %s", err.Error())
+		return errors.NewChuxModelsError("Author.Deserialize() error occurred", err)
+	}
+	return nil
+}
+
+// AddVariant adds another spelling of the Author's name
+func (au *Author) AddVariant(name string) {
+	logging := au.Logger
+	logging.Debug("Author.AddVariant() was called")
+	name = strings.TrimSpace(name)
+	if name == "" || name == au.Name || containsString(au.Variants, name) {
+		return
+	}
+	au.Variants = append(au.Variants, name)
+}
+
+// AddPublication adds a company the Author has written for
+func (au *Author) AddPublication(companyName string) {
+	logging := au.Logger
+	logging.Debug("Author.AddPublication() was called")
+	companyName = strings.TrimSpace(companyName)
+	if companyName == "" || containsString(au.Publications, companyName) {
+		return
+	}
+	au.Publications = append(au.Publications, companyName)
+}
+
+// Articles returns the stored Articles that link to the Author
+func (au *Author) Articles() ([]*Article, error) {
+	logging := au.Logger
+	logging.Debug("Author.Articles() was called")
+	query := &Article{Logger: au.Logger}
+	docs, err := query.Query("authorIds", au.ID)
+	if err != nil {
+		return nil, err
+	}
+	articles := make([]*Article, 0, len(docs))
+	for _, doc := range docs {
+		found := doc.(*Article)
+		found.Logger = au.Logger
+		if err := found.markLoaded(); err != nil {
+			return nil, err
+		}
+		articles = append(articles, found)
+	}
+	logging.Info("Author.Articles() Found %d Articles by %s", len(articles), au.Name)
+	return articles, nil
+}
+
+// Returns the stored Author with the key of name, or nil when there is none
+func findAuthor(logging *logging.Logger, name string) (*Author, error) {
+	query := &Author{Logger: logging}
+	docs, err := query.Query("nameKey", AuthorKey(name))
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, nil
+	}
+	author := docs[0].(*Author)
+	author.Logger = logging
+	if err := author.markLoaded(); err != nil {
+		return nil, err
+	}
+	return author, nil
+}
+
+// FindAuthor returns the stored Author with a name, in any of its
+// spellings that have the same key, or nil when there is none
+func FindAuthor(logging logging.Logger, name string) (*Author, error) {
+	NewAuthor(NewAuthorWithLogger(logging))
+	return findAuthor(&logging, name)
+}
+
+// ArticlesByAuthor returns the stored Articles of the Author with
+// a name across all the sites they write for
+func ArticlesByAuthor(logging logging.Logger, name string) ([]*Article, error) {
+	NewAuthor(NewAuthorWithLogger(logging))
+	author, err := findAuthor(&logging, name)
+	if err != nil || author == nil {
+		return nil, err
+	}
+	return author.Articles()
+}
+
+// Finds or creates the Authors of the Article's AuthorsList, records the
+// name variant and publication on each and sets the Article's AuthorIDs.
+// Called by Save() before the Article is stored.
+func (a *Article) linkAuthors() error {
+	logging := a.Logger
+	logging.Debug("Article.linkAuthors() was called")
+	var ids []primitive.ObjectID
+	for _, name := range a.AuthorsList {
+		author, err := findAuthor(logging, name)
+		if err != nil {
+			return err
+		}
+		if author == nil {
+			author = &Author{Name: name, NameKey: AuthorKey(name), Logger: logging, isNew: true}
+		}
+		author.AddVariant(name)
+		author.AddPublication(a.CompanyName)
+		if author.isNew || author.IsDirty() {
+			if err := author.Save(); err != nil {
+				return err
+			}
+		}
+		ids = append(ids, author.ID)
+	}
+	a.AuthorIDs = ids
+	return nil
+}
@@ -0,0 +1,179 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Availability is the normalized stock status of an Offer
+type Availability string
+
+const (
+	AvailabilityInStock             Availability = "InStock"
+	AvailabilityOutOfStock          Availability = "OutOfStock"
+	AvailabilityPreOrder            Availability = "PreOrder"
+	AvailabilityBackOrder           Availability = "BackOrder"
+	AvailabilityLimitedAvailability Availability = "LimitedAvailability"
+	AvailabilityDiscontinued        Availability = "Discontinued"
+	AvailabilityUnknown             Availability = "Unknown"
+)
+
+// IsAvailable returns true when the Offer can be bought now
+func (a Availability) IsAvailable() bool {
+	return a == AvailabilityInStock || a == AvailabilityLimitedAvailability
+}
+
+// The schema.org ItemAvailability values, lower cased, mapped to an Availability
+var schemaOrgAvailability = map[string]Availability{
+	"instock":             AvailabilityInStock,
+	"instoreonly":         AvailabilityInStock,
+	"onlineonly":          AvailabilityInStock,
+	"outofstock":          AvailabilityOutOfStock,
+	"soldout":             AvailabilityOutOfStock,
+	"reserved":            AvailabilityOutOfStock,
+	"preorder":            AvailabilityPreOrder,
+	"presale":             AvailabilityPreOrder,
+	"backorder":           AvailabilityBackOrder,
+	"madetoorder":         AvailabilityBackOrder,
+	"limitedavailability": AvailabilityLimitedAvailability,
+	"discontinued":        AvailabilityDiscontinued,
+}
+
+// Phrases that describe an Availability in English, Spanish, French, German,
+// Italian, Portuguese and Dutch. The groups are checked in order, so
+// "not available" is found before "available" and "no longer available"
+// before "not available".
+var availabilityPhrases = []struct {
+	availability Availability
+	phrases      []string
+}{
+	{AvailabilityDiscontinued, []string{
+		"discontinued", "no longer available", "no longer sold", "end of life",
+		"descatalogado", "ya no esta disponible",
+		"plus fabrique", "n'est plus disponible", "fin de serie",
+		"nicht mehr verfügbar", "nicht mehr lieferbar", "auslaufartikel",
+		"fuori produzione", "non piu disponibile",
+		"descontinuado", "fora de linha",
+		"uit assortiment", "niet meer leverbaar",
+	}},
+	{AvailabilityOutOfStock, []string{
+		"out of stock", "sold out", "unavailable", "not available", "currently unavailable", "no stock",
+		"not in stock", "no longer in stock", "none in stock", "zero stock",
+		"notify me when available", "notify when available", "notify me when in stock",
+		"email when available", "email me when available", "email me when in stock", "alert me when available",
+		"agotado", "sin stock", "no disponible", "sin existencias",
+		"rupture de stock", "epuise", "indisponible", "en rupture",
+		"ausverkauft", "nicht verfügbar", "nicht auf lager", "nicht vorrätig",
+		"esaurito", "non disponibile",
+		"esgotado", "indisponivel", "fora de estoque",
+		"uitverkocht", "niet op voorraad", "niet beschikbaar",
+	}},
+	{AvailabilityPreOrder, []string{
+		"pre-order", "preorder", "pre order", "coming soon", "available for preorder",
+		"preventa", "pre-venta", "reserva ya", "proximamente",
+		"precommande", "pré-commande", "bientot disponible",
+		"vorbestellen", "vorbestellung", "demnächst",
+		"preordine", "prenota", "prossimamente",
+		"pre-venda", "pré-venda", "em breve",
+		"voorbestellen", "binnenkort",
+	}},
+	{AvailabilityBackOrder, []string{
+		"backorder", "back order", "back-order", "backordered", "available to order", "made to order",
+		"bajo pedido", "por encargo",
+		"sur commande",
+		"auf bestellung", "nachbestellt",
+		"su ordinazione", "su richiesta",
+		"sob encomenda",
+		"nabestelling", "op bestelling",
+	}},
+	{AvailabilityLimitedAvailability, []string{
+		"few left", "low stock", "limited stock", "limited availability", "limited quantities", "almost gone",
+		"ultimas unidades", "quedan pocas", "pocas unidades", "stock limitado",
+		"plus que", "stock limite", "derniers articles",
+		"nur noch", "wenige verfügbar", "begrenzt verfügbar",
+		"ultimi pezzi", "disponibilita limitata", "pochi pezzi",
+		"ultimas unidades", "estoque limitado",
+		"laatste stuks", "beperkte voorraad", "nog maar",
+	}},
+	{AvailabilityInStock, []string{
+		"in stock", "available", "add to cart", "ready to ship", "ships today", "in store",
+		"en stock", "disponible", "en existencia", "hay existencias",
+		"auf lager", "lieferbar", "verfügbar", "sofort lieferbar",
+		"disponibile", "in magazzino",
+		"em estoque", "disponivel",
+		"op voorraad", "leverbaar", "beschikbaar",
+	}},
+}
+
+// "Only 2 left", "3 left in stock", "Solo 2 disponibles", "Noch 3 Stück"
+var limitedCountRegex = regexp.MustCompile(`\b(only|solo|seulement|nur|noch|apenas|nog)\s+\d+\b|\b\d+\s+(left|restantes|restants|rimasti|over)\b`)
+
+// An explicit quantity of 0, "In stock: 0", "Qty 0", "0 left", "0 available"
+var zeroQuantityRegex = regexp.MustCompile(`\b(stock|qty|quantity|inventory|available|disponibles?|existencias|lager|vorrat|voorraad|estoque|magazzino)\s+0\b|\b0\s+(left|in stock|available|items?|units?|pieces?|pcs|disponibles?|restantes|auf lager|op voorraad|em estoque|in magazzino)\b`)
+
+// The phrases above normalized the same way as the text they are matched against
+var normalizedAvailabilityPhrases = func() [][]string {
+	normalized := make([][]string, len(availabilityPhrases))
+	for i, group := range availabilityPhrases {
+		for _, phrase := range group.phrases {
+			normalized[i] = append(normalized[i], " "+NormalizeText(phrase)+" ")
+		}
+	}
+	return normalized
+}()
+
+// ParseAvailability reads the availability an extractor emitted, either
+// a schema.org ItemAvailability value such as "https://schema.org/InStock"
+// or "InStock", or a phrase such as "Only 2 left!" or "Agotado".
+func ParseAvailability(raw string) Availability {
+	value := strings.TrimSpace(raw)
+	if value == "" {
+		return AvailabilityUnknown
+	}
+
+	// -- schema.org values, with or without the schema.org prefix
+	lower := strings.ToLower(value)
+	for _, prefix := range []string{"https://schema.org/", "http://schema.org/", "schema.org/", "schema:"} {
+		lower = strings.TrimPrefix(lower, prefix)
+	}
+	if availability, ok := schemaOrgAvailability[strings.Trim(lower, " /")]; ok {
+		return availability
+	}
+
+	text := " " + NormalizeText(value) + " "
+	if availability, ok := schemaOrgAvailability[strings.ReplaceAll(strings.TrimSpace(text), " ", "")]; ok {
+		return availability
+	}
+	if zeroQuantityRegex.MatchString(text) {
+		return AvailabilityOutOfStock
+	}
+	if limitedCountRegex.MatchString(text) && !strings.Contains(text, " 0 ") {
+		return AvailabilityLimitedAvailability
+	}
+	for i, group := range availabilityPhrases {
+		for _, phrase := range normalizedAvailabilityPhrases[i] {
+			if strings.Contains(text, phrase) {
+				return group.availability
+			}
+		}
+	}
+	return AvailabilityUnknown
+}
+
+// Status returns the normalized Availability of the Offer
+func (o *Offer) Status() Availability {
+	if o.AvailabilityStatus != "" {
+		return o.AvailabilityStatus
+	}
+	return ParseAvailability(o.Availability)
+}
+
+// NormalizeAvailability sets the AvailabilityStatus of every Offer of the
+// Product from its raw Availability, which is kept for auditing.
+func (p *Product) NormalizeAvailability() {
+	logging := p.Logger
+	logging.Debug("Product.NormalizeAvailability() was called")
+	for i := range p.Offers {
+		p.Offers[i].AvailabilityStatus = ParseAvailability(p.Offers[i].Availability)
+	}
+}
@@ -0,0 +1,42 @@
+package models
+
+import "testing"
+
+func TestParseAvailability(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want Availability
+	}{
+		{"https://schema.org/InStock", AvailabilityInStock},
+		{"OutOfStock", AvailabilityOutOfStock},
+		{"In stock", AvailabilityInStock},
+		{"Available", AvailabilityInStock},
+		{"Add to cart", AvailabilityInStock},
+		{"Out of stock", AvailabilityOutOfStock},
+		{"Not in stock", AvailabilityOutOfStock},
+		{"Currently not in stock", AvailabilityOutOfStock},
+		{"No longer in stock", AvailabilityOutOfStock},
+		{"Notify me when available", AvailabilityOutOfStock},
+		{"Email me when available", AvailabilityOutOfStock},
+		{"Not available", AvailabilityOutOfStock},
+		{"In stock: 0", AvailabilityOutOfStock},
+		{"Qty: 0", AvailabilityOutOfStock},
+		{"0 left", AvailabilityOutOfStock},
+		{"0 available", AvailabilityOutOfStock},
+		{"In stock: 10", AvailabilityInStock},
+		{"Only 2 left!", AvailabilityLimitedAvailability},
+		{"3 left in stock", AvailabilityLimitedAvailability},
+		{"Agotado", AvailabilityOutOfStock},
+		{"Nicht auf Lager", AvailabilityOutOfStock},
+		{"Auf Lager", AvailabilityInStock},
+		{"Discontinued", AvailabilityDiscontinued},
+		{"Pre-order now", AvailabilityPreOrder},
+		{"", AvailabilityUnknown},
+		{"Blue", AvailabilityUnknown},
+	}
+	for _, tt := range tests {
+		if got := ParseAvailability(tt.raw); got != tt.want {
+			t.Errorf("ParseAvailability(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/chuxorg/chux-datastore/db"
+	dbl "github.com/chuxorg/chux-datastore/logging"
+	"github.com/chuxorg/chux-models/errors"
+	"github.com/chuxorg/chux-models/logging"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Brand is the canonical name of a brand along with the other
+// spellings it is scraped as, e.g. "HP" with the aliases
+// "Hewlett-Packard" and "hp inc.".
+type Brand struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	CanonicalName string             `bson:"canonicalName" json:"canonicalName"`
+	Aliases       []string           `bson:"aliases" json:"aliases"`
+	ParentID      primitive.ObjectID `bson:"parentId,omitempty" json:"parentId,omitempty"`
+	DateCreated   CustomTime         `bson:"dateCreated,omitempty" json:"dateCreated,omitempty"`
+	DateModified  CustomTime         `bson:"dateModified,omitempty" json:"dateModified,omitempty"`
+	isNew         bool               `bson:"-" json:"-"`
+	isDeleted     bool               `bson:"-" json:"-"`
+	isDirty       bool               `bson:"-" json:"-"`
+	originalState *Brand             `bson:"-" json:"-"`
+	timeConfig    *CustomTimeConfig  `bson:"-" json:"-"`
+	Logger        *logging.Logger    `bson:"-" json:"-"`
+}
+
+func NewBrand(options ...func(*Brand)) *Brand {
+
+	b := &Brand{}
+
+	for _, option := range options {
+		option(b)
+	}
+	dbLogger := dbl.NewLogger(dbl.LogLevelDebug)
+	mongoDB = db.New(
+		db.WithURI(b.GetURI()),
+		db.WithDatabaseName(b.GetDatabaseName()),
+		db.WithCollectionName(b.GetCollectionName()),
+		db.WithTimeout(30),
+		db.WithLogger(*dbLogger),
+	)
+
+	b.isNew = true
+	b.isDeleted = false
+	b.isDirty = false
+	return b
+}
+
+func NewBrandWithLogger(logger logging.Logger) func(*Brand) {
+	return func(b *Brand) {
+		b.Logger = &logger
+	}
+}
+
+// Sets how the Brand's CustomTimes are written to JSON and stored in Mongo,
+// without it they use the DefaultCustomTimeConfig
+func NewBrandWithTimeConfig(config CustomTimeConfig) func(*Brand) {
+	return func(b *Brand) {
+		b.timeConfig = &config
+	}
+}
+
+func (b *Brand) GetCollectionName() string {
+	logging := b.Logger
+	logging.Debug("Brand.GetCollectionName() was called")
+	return "brands"
+}
+
+func (b *Brand) GetDatabaseName() string {
+	logging := b.Logger
+	logging.Debug("Brand.GetDatabaseName() was called")
+	return os.Getenv("MONGO_DATABASE")
+}
+
+func (b *Brand) GetURI() string {
+	logging := b.Logger
+	logging.Debug("Brand.GetURI() was called")
+	username := os.Getenv("MONGO_USER_NAME")
+	password := os.Getenv("MONGO_PASSWORD")
+
+	uri := os.Getenv("MONGO_URI")
+	mongoURI := fmt.Sprintf(uri, username, password)
+	masked := fmt.Sprintf(uri, "********", "********")
+	logging.Info("Mongo URI: %s", masked)
+	return mongoURI
+}
+
+func (b *Brand) GetID() primitive.ObjectID {
+	logging := b.Logger
+	logging.Debug("Brand.GetID() was called")
+	return b.ID
+}
+
+func (b *Brand) SetID(id primitive.ObjectID) {
+	logging := b.Logger
+	logging.Debug("Brand.SetID() was called")
+	b.ID = id
+}
+
+// If the Model has changes, will return true
+func (b *Brand) IsDirty() bool {
+	logging := b.Logger
+	logging.Debug("Brand.IsDirty() was called")
+	if b.originalState == nil {
+		return false
+	}
+
+	originalBytes, err := b.originalState.Serialize()
+	if err != nil {
+		return false
+	}
+
+	currentBytes, err := b.Serialize()
+	if err != nil {
+		return false
+	}
+
+	b.isDirty = string(originalBytes) != string(currentBytes)
+	logging.Info("Brand.IsDirty() isDirty: %t", b.isDirty)
+	return b.isDirty
+}
+
+// When the Model is first created,
+// the model is considered New. After the model is
+// Saved or Loaded it is no longer New
+func (b *Brand) IsNew() bool {
+	logging := b.Logger
+	logging.Debug("Brand.IsNew() was called")
+	return b.isNew
+}
+
+// Saves the Model to a Data Store
+func (b *Brand) Save() error {
+	logging := b.Logger
+	logging.Debug("Brand.Save() was called")
+	applyTimeConfig(b.timeConfig, b)
+	if b.isNew {
+		logging.Debug("Brand.Save() Brand is new")
+		// -- Set the date created to now
+		b.DateCreated.Now()
+		//-- Upsert document, a Brand is unique by its canonical name
+		err := mongoDB.Upsert(b, "canonicalName")
+		if err != nil {
+			logging.Error("Brand.Save() Error creating Brand in MongoDB: %s", err.Error())
+			return errors.NewChuxModelsError("Brand.Save() Error creating Brand in MongoDB", err)
+		}
+	} else if b.IsDirty() && !b.isDeleted {
+		logging.Debug("Brand.Save() Brand is dirty")
+		// Ensure the ID is a valid hex string representation of an ObjectID
+		_, err := primitive.ObjectIDFromHex(b.ID.Hex())
+		if err != nil {
+			logging.Error("Brand.Save() invalid ObjectID: %s", err.Error())
+			return errors.NewChuxModelsError("Brand.Save() invalid ObjectID", err)
+		}
+		// -- Set the date modified to now
+		b.DateModified.Now()
+		//--update this document
+		err = mongoDB.Update(b, b.ID.Hex())
+		if err != nil {
+			logging.Error("Brand.Save() Error updating Brand in MongoDB: %s", err.Error())
+			return errors.NewChuxModelsError("Brand.Save() Error updating Brand in MongoDB", err)
+		}
+	} else if b.isDeleted && !b.isNew {
+		logging.Info("Brand.Save() Brand is deleted")
+		//--delete the document
+		err := mongoDB.Delete(b, b.ID.Hex())
+		if err != nil {
+			logging.Error("Brand.Save() Error deleting Brand in MongoDB: %s", err.Error())
+			return errors.NewChuxModelsError("Brand.Save() Error deleting Brand in MongoDB", err)
+		}
+	}
+
+	// If the Brand has been deleted, then this is a new Brand
+	b.isNew = b.isDeleted
+	b.isDirty = b.IsDirty()
+	b.isDeleted = false
+
+	if b.isNew {
+		b.originalState = nil
+	} else {
+		//--reset state
+		serialized, err := b.Serialize()
+		if err != nil {
+			logging.Error("Brand.Save() Error serializing Brand: %s", err.Error())
+			return errors.NewChuxModelsError("Brand.Save() Error serializing Brand.", err)
+		}
+		b.SetState(serialized)
+	}
+
+	logging.Info("Brand.Save() Brand saved successfully")
+	return nil
+}
+
+// Loads a Model from MongoDB by id
+func (b *Brand) Load(id string) (interface{}, error) {
+	logging := b.Logger
+	logging.Debug("Brand.Load() was called")
+
+	retVal, err := mongoDB.GetByID(b, id)
+	if err != nil {
+		logging.Error("Brand.Load() Error loading Brand from MongoDB: %s", err.Error())
+		return nil, errors.NewChuxModelsError("Brand.Load() Error loading Brand from MongoDB", err)
+	}
+	cluster, ok := retVal.(*Brand)
+	if !ok {
+		logging.Error("Brand.Load() unable to cast retVal to *Brand")
+		return nil, errors.NewChuxModelsError("Brand.Load() unable to cast retVal to *Brand", nil)
+	}
+	err = cluster.markLoaded()
+	if err != nil {
+		logging.Error("Brand.Load() Error setting state: %s", err.Error())
+		return nil, errors.NewChuxModelsError("Brand.Load() Error setting state", err)
+	}
+	logging.Info("Brand.Load() Brand loaded successfully")
+	return retVal, nil
+}
+
+func (b *Brand) Query(args ...interface{}) ([]db.IMongoDocument, error) {
+	logging := b.Logger
+	logging.Debug("Brand.Query() was called")
+
+	results, err := mongoDB.Query(b, args...)
+	if err != nil {
+		logging.Error("Brand.Query() Error occurred querying Brands: %s", err.Error())
+		return nil, errors.NewChuxModelsError("Brand.Query() Error occurred querying Brands", err)
+	}
+	logging.Info("Brand.Query() Brands queried successfully")
+	return results, nil
+}
+
+// Marks a Model for deletion from the Data Store
+// when Save() is called, the Model will be deleted
+func (b *Brand) Delete() error {
+	logging := b.Logger
+	logging.Debug("Brand.Delete() was called")
+	b.isDeleted = true
+	return nil
+}
+
+// Sets the internal state of the model.
+func (b *Brand) SetState(json string) error {
+	logging := b.Logger
+	logging.Debug("Brand.SetState() was called")
+	// Store the current state as the original state
+	original := &Brand{}
+	*original = *b
+	b.originalState = original
+
+	// Deserialize the new state
+	return b.Deserialize([]byte(json))
+}
+
+// Marks a Brand returned by Query() as loaded so that
+// changes made to it are persisted by Save()
+func (b *Brand) markLoaded() error {
+	serialized, err := b.Serialize()
+	if err != nil {
+		return errors.NewChuxModelsError("Brand.markLoaded() Error serializing Brand", err)
+	}
+	b.SetState(serialized)
+	b.isNew = false
+	b.isDirty = false
+	b.isDeleted = false
+	return nil
+}
+
+// Sets the internal state of the model of a new Brand
+// from a JSON String.
+func (b *Brand) Parse(json string) error {
+	logging := b.Logger
+	logging.Debug("Brand.Parse() was called")
+	err := b.SetState(json)
+	if err != nil {
+		logging.Error("Brand.Parse() error setting state")
+		return errors.NewChuxModelsError("Brand.Parse() Error setting state", err)
+	}
+	b.isNew = true // this is a new model
+	return nil
+}
+
+func (b *Brand) Search(args ...interface{}) ([]interface{}, error) {
+	logging := b.Logger
+	logging.Debug("Brand.Search() was called")
+	return nil, nil
+}
+
+func (b *Brand) Serialize() (string, error) {
+	logging := b.Logger
+	logging.Debug("Brand.Serialize() was called")
+	applyTimeConfig(b.timeConfig, b)
+	bytes, err := json.Marshal(b)
+	if err != nil {
+		logging.Error("Brand.Serialize() error occurred: %s", err.Error())
+		return "", errors.NewChuxModelsError("Brand.Serialize() error occurred", err)
+	}
+	return string(bytes), nil
+}
+
+func (b *Brand) Deserialize(jsonData []byte) error {
+	logging := b.Logger
+	logging.Debug("Brand.Deserialize() was called")
+	applyTimeConfig(b.timeConfig, b)
+	err := json.Unmarshal(jsonData, b)
+	if err != nil {
+		logging.Error("Brand.Deserialize() error occurred: %s", err.Error())
+		return errors.NewChuxModelsError("Brand.Deserialize() error occurred", err)
+	}
+	return nil
+}
+
+// AddAlias adds another spelling of the Brand
+func (b *Brand) AddAlias(alias string) {
+	logging := b.Logger
+	logging.Debug("Brand.AddAlias() was called")
+	alias = strings.TrimSpace(alias)
+	if alias == "" || brandKey(alias) == brandKey(b.CanonicalName) {
+		return
+	}
+	for _, existing := range b.Aliases {
+		if brandKey(existing) == brandKey(alias) {
+			return
+		}
+	}
+	b.Aliases = append(b.Aliases, alias)
+}
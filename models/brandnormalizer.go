@@ -0,0 +1,203 @@
+package models
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/chuxorg/chux-models/errors"
+	"github.com/chuxorg/chux-models/logging"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Corporate suffixes that do not distinguish one brand from another
+var brandSuffixes = map[string]bool{
+	"inc": true, "incorporated": true, "corp": true, "corporation": true,
+	"co": true, "company": true, "ltd": true, "limited": true, "llc": true,
+	"plc": true, "gmbh": true, "ag": true, "sa": true, "srl": true, "bv": true,
+	"nv": true, "oy": true, "ab": true, "kk": true, "pty": true, "brands": true,
+}
+
+// BrandFrequency is a raw brand string that matched no Brand
+// and how many Products carry it.
+type BrandFrequency struct {
+	Brand    string   `json:"brand"`
+	Count    int      `json:"count"`
+	Variants []string `json:"variants"`
+}
+
+// BrandNormalizer resolves free text brand names to Brands
+type BrandNormalizer struct {
+	brands map[string]*Brand
+	byID   map[primitive.ObjectID]*Brand
+}
+
+// NewBrandNormalizer indexes the canonical names and aliases of brands
+func NewBrandNormalizer(brands []*Brand) *BrandNormalizer {
+	n := &BrandNormalizer{
+		brands: make(map[string]*Brand),
+		byID:   make(map[primitive.ObjectID]*Brand),
+	}
+	for _, brand := range brands {
+		n.Add(brand)
+	}
+	return n
+}
+
+// LoadBrandNormalizer builds a BrandNormalizer from the stored Brands
+func LoadBrandNormalizer(logging logging.Logger) (*BrandNormalizer, error) {
+	brd := NewBrand()
+	brd.Logger = &logging
+	docs, err := brd.Query()
+	if err != nil {
+		logging.Error("LoadBrandNormalizer() Error querying brands: %s", err.Error())
+		return nil, errors.NewChuxModelsError("LoadBrandNormalizer() Error querying brands", err)
+	}
+	brands := make([]*Brand, 0, len(docs))
+	for _, doc := range docs {
+		brands = append(brands, doc.(*Brand))
+	}
+	logging.Info("LoadBrandNormalizer() Loaded %d brands", len(brands))
+	return NewBrandNormalizer(brands), nil
+}
+
+// Add indexes a Brand. A later Brand with the same key replaces an earlier one.
+func (n *BrandNormalizer) Add(brand *Brand) {
+	if !brand.ID.IsZero() {
+		n.byID[brand.ID] = brand
+	}
+	for _, name := range append([]string{brand.CanonicalName}, brand.Aliases...) {
+		if key := brandKey(name); key != "" {
+			n.brands[key] = brand
+		}
+	}
+}
+
+// Resolve returns the Brand a raw brand string refers to
+func (n *BrandNormalizer) Resolve(raw string) (*Brand, bool) {
+	key := brandKey(raw)
+	if key == "" {
+		return nil, false
+	}
+	brand, ok := n.brands[key]
+	return brand, ok
+}
+
+// Manufacturer returns the parent manufacturer of a Brand, following
+// ParentID up to the top most Brand. Returns false when the Brand has no parent.
+func (n *BrandNormalizer) Manufacturer(brand *Brand) (*Brand, bool) {
+	parent, ok := n.byID[brand.ParentID]
+	if !ok || brand.ParentID.IsZero() {
+		return nil, false
+	}
+	// -- Guard against cycles in curated data
+	seen := map[primitive.ObjectID]bool{brand.ID: true}
+	for !parent.ParentID.IsZero() && !seen[parent.ID] {
+		next, ok := n.byID[parent.ParentID]
+		if !ok {
+			break
+		}
+		seen[parent.ID] = true
+		parent = next
+	}
+	return parent, true
+}
+
+// Reduces a brand name to a key that ignores case, punctuation, spacing
+// and corporate suffixes, so "Hewlett-Packard Co." and "hewlett packard" match.
+func brandKey(name string) string {
+	tokens := Tokenize(name)
+	for len(tokens) > 1 && brandSuffixes[tokens[len(tokens)-1]] {
+		tokens = tokens[:len(tokens)-1]
+	}
+	if len(tokens) > 1 && tokens[0] == "the" {
+		tokens = tokens[1:]
+	}
+	return strings.Join(tokens, "")
+}
+
+// NormalizeBrand resolves the Product's Brand. The scraped value is kept
+// in BrandRaw, and when it matches a Brand the Product's Brand is replaced
+// with the canonical name and BrandID is set.
+func (p *Product) NormalizeBrand(n *BrandNormalizer) {
+	logging := p.Logger
+	logging.Debug("Product.NormalizeBrand() was called")
+	if p.BrandRaw == "" {
+		p.BrandRaw = p.Brand
+	}
+	brand, ok := n.Resolve(p.BrandRaw)
+	if !ok {
+		p.Brand = p.BrandRaw
+		p.BrandID = primitive.NilObjectID
+		return
+	}
+	p.Brand = brand.CanonicalName
+	p.BrandID = brand.ID
+}
+
+// UnmatchedBrands counts the raw brand strings of Products that match no
+// Brand, most frequent first. Spellings that only differ in case or
+// punctuation are counted together and listed as variants.
+func UnmatchedBrands(products []*Product, n *BrandNormalizer) []BrandFrequency {
+	byKey := make(map[string]*BrandFrequency)
+	variants := make(map[string]map[string]int)
+	for _, product := range products {
+		raw := product.BrandRaw
+		if raw == "" {
+			raw = product.Brand
+		}
+		raw = strings.TrimSpace(raw)
+		key := brandKey(raw)
+		if key == "" {
+			continue
+		}
+		if _, ok := n.Resolve(raw); ok {
+			continue
+		}
+		frequency, ok := byKey[key]
+		if !ok {
+			frequency = &BrandFrequency{}
+			byKey[key] = frequency
+			variants[key] = make(map[string]int)
+		}
+		frequency.Count++
+		variants[key][raw]++
+	}
+
+	frequencies := make([]BrandFrequency, 0, len(byKey))
+	for key, frequency := range byKey {
+		frequency.Brand = mostCommon(variants[key])
+		for variant := range variants[key] {
+			frequency.Variants = append(frequency.Variants, variant)
+		}
+		sort.Strings(frequency.Variants)
+		frequencies = append(frequencies, *frequency)
+	}
+	sort.Slice(frequencies, func(i, j int) bool {
+		if frequencies[i].Count != frequencies[j].Count {
+			return frequencies[i].Count > frequencies[j].Count
+		}
+		return frequencies[i].Brand < frequencies[j].Brand
+	})
+	return frequencies
+}
+
+// ListUnmatchedBrands loads the Products without a BrandID and
+// counts their brand strings that match no Brand, for curation.
+func ListUnmatchedBrands(logging logging.Logger, n *BrandNormalizer) ([]BrandFrequency, error) {
+	prd := NewProduct()
+	prd.Logger = &logging
+	// -- Unmatched Products are stored with a NilObjectID, null also matches a missing brandId
+	docs, err := prd.Query("brandId", bson.M{"$in": bson.A{nil, primitive.NilObjectID}})
+	if err != nil {
+		logging.Error("ListUnmatchedBrands() Error querying products: %s", err.Error())
+		return nil, errors.NewChuxModelsError("ListUnmatchedBrands() Error querying products", err)
+	}
+	products := make([]*Product, 0, len(docs))
+	for _, doc := range docs {
+		products = append(products, doc.(*Product))
+	}
+	frequencies := UnmatchedBrands(products, n)
+	logging.Info("ListUnmatchedBrands() Found %d unmatched brands", len(frequencies))
+	return frequencies, nil
+}
@@ -0,0 +1,194 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Words a byline starts with before the names, in the languages scraped
+var bylinePrefixRegex = regexp.MustCompile(`(?i)^(?:(?:written|words|story|reporting|text|photos?|posted|published)\s+)?(?:by|von|par|por|door|av|przez)\s*:?\s+`)
+
+// Separators between the names of a byline
+var bylineSeparatorRegex = regexp.MustCompile(`\s*(?:,|;|/|\||\n|&|\+)\s*`)
+
+// Conjunctions between the names of a byline. They are lower case only,
+// so that initials such as the "E" of "Jane E Doe" are not taken for them.
+var bylineConjunctionRegex = regexp.MustCompile(`\s+(?:with reporting by|and|und|et|y|e|en|och|og|i|with)\s+`)
+
+// Parenthesized notes, emails, handles and links in a byline
+var bylineNoiseRegex = regexp.MustCompile(`\([^)]*\)|\[[^\]]*\]|\S+@\S+|@\w+|https?://\S+`)
+
+// Honorifics that are not part of a name
+var nameHonorificRegex = regexp.MustCompile(`(?i)^(?:dr|mr|mrs|ms|mx|prof|sir|dame|rev)\.?\s+`)
+
+// Words that describe a role or an organization rather than a person.
+// Parts of a byline made only of these words are dropped, and they are
+// trimmed from the end of names, e.g. "Jane Doe Staff Writer", as long
+// as two words are left. Some of them are surnames too, as in "Michael
+// Bloomberg".
+var bylineRoleWords = map[string]bool{
+	"staff": true, "writer": true, "writers": true, "reporter": true, "reporters": true,
+	"editor": true, "editors": true, "correspondent": true, "correspondents": true,
+	"contributor": true, "contributors": true, "columnist": true, "columnists": true,
+	"senior": true, "chief": true, "special": true, "political": true, "business": true,
+	"sports": true, "science": true, "technology": true, "health": true, "news": true,
+	"foreign": true, "national": true, "associate": true, "deputy": true, "managing": true,
+	"contributing": true, "freelance": true, "guest": true, "desk": true, "team": true,
+	"bureau": true, "wire": true, "wires": true, "service": true, "services": true,
+	"associated": true, "press": true, "ap": true, "afp": true, "reuters": true,
+	"bloomberg": true, "upi": true, "dpa": true, "pa": true, "media": true, "the": true,
+	"of": true, "for": true, "at": true, "photographer": true, "photographers": true,
+	"analyst": true, "producer": true, "author": true, "authors": true, "redaktion": true,
+	"redacción": true, "rédaction": true, "admin": true, "administrator": true,
+}
+
+// ParseByline splits a byline such as "By Jane Doe and John Roe, Staff
+// Writers" into the names of its authors, ["Jane Doe", "John Roe"].
+// Prefixes, honorifics, roles, agencies, emails and dates are removed
+// and names written in a single case are title cased.
+func ParseByline(byline string) []string {
+	byline = bylineNoiseRegex.ReplaceAllString(byline, " ")
+	byline = strings.TrimSpace(byline)
+	var names []string
+	seen := make(map[string]bool)
+	for _, part := range splitByline(byline) {
+		name := cleanAuthorName(part)
+		if name == "" {
+			continue
+		}
+		key := AuthorKey(name)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// Splits a byline into the parts with a name each. A conjunction only
+// separates names when neither side of it is a single word, so that
+// "Jane e Doe" stays whole while "Jane Doe and John Roe" and "Jane Doe
+// and Reuters" are split.
+func splitByline(byline string) []string {
+	var parts []string
+	for _, part := range bylineSeparatorRegex.Split(byline, -1) {
+		segments := bylineConjunctionRegex.Split(part, -1)
+		conjunctions := bylineConjunctionRegex.FindAllString(part, -1)
+		current := segments[0]
+		for i, conjunction := range conjunctions {
+			next := segments[i+1]
+			if len(strings.Fields(cleanAuthorName(current))) != 1 && len(strings.Fields(cleanAuthorName(next))) != 1 {
+				parts = append(parts, current)
+				current = next
+			} else {
+				current += conjunction + next
+			}
+		}
+		parts = append(parts, current)
+	}
+	return parts
+}
+
+// Reduces a part of a byline to a person's name, or "" when it is not one
+func cleanAuthorName(part string) string {
+	part = strings.Trim(strings.TrimSpace(part), `-–—:.,"'`)
+	for {
+		stripped := bylinePrefixRegex.ReplaceAllString(part, "")
+		stripped = nameHonorificRegex.ReplaceAllString(stripped, "")
+		if stripped == part {
+			break
+		}
+		part = strings.TrimSpace(stripped)
+	}
+	// -- A prefix on its own, e.g. the "By" of "By: Jane Doe" split from its name
+	if bylinePrefixRegex.MatchString(part + " ") {
+		return ""
+	}
+
+	words := strings.Fields(part)
+	roles := 0
+	for _, word := range words {
+		if isBylineRoleWord(word) {
+			roles++
+		}
+	}
+	if roles == len(words) {
+		return ""
+	}
+	for len(words) > 2 && isBylineRoleWord(words[len(words)-1]) {
+		words = words[:len(words)-1]
+	}
+	if len(words) == 0 || len(words) > 5 {
+		return ""
+	}
+	for _, word := range words {
+		for _, r := range word {
+			if unicode.IsDigit(r) {
+				return ""
+			}
+		}
+	}
+	name := strings.Join(words, " ")
+	if strings.IndexFunc(name, unicode.IsLetter) < 0 {
+		return ""
+	}
+	if name == strings.ToUpper(name) || name == strings.ToLower(name) {
+		name = titleCase(name)
+	}
+	return name
+}
+
+func isBylineRoleWord(word string) bool {
+	return bylineRoleWords[strings.ToLower(strings.Trim(word, ".,"))]
+}
+
+// Upper cases the first letter of each word, and of each part of
+// hyphenated and apostrophe names such as "Smith-Jones" and "O'Neil"
+func titleCase(s string) string {
+	runes := []rune(strings.ToLower(s))
+	start := true
+	for i, r := range runes {
+		if start && unicode.IsLetter(r) {
+			runes[i] = unicode.ToUpper(r)
+		}
+		start = r == ' ' || r == '-' || r == '\''
+	}
+	return string(runes)
+}
+
+// AuthorKey normalizes a name to the key Authors are unique by.
+// Initials are left out so "Jane Q. Doe" and "Jane Doe" are the same.
+func AuthorKey(name string) string {
+	var words []string
+	for _, word := range Tokenize(name) {
+		if len([]rune(word)) > 1 {
+			words = append(words, word)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// NormalizeAuthors parses the Article's Author and AuthorsList bylines
+// into the names of its authors. AuthorsList is set to the names and
+// Author to the names joined with commas, the raw byline is kept in Byline.
+func (a *Article) NormalizeAuthors() {
+	logging := a.Logger
+	logging.Debug("Article.NormalizeAuthors() was called")
+	if a.Byline == "" {
+		a.Byline = a.Author
+	}
+	var names []string
+	seen := make(map[string]bool)
+	for _, byline := range append([]string{a.Author}, a.AuthorsList...) {
+		for _, name := range ParseByline(byline) {
+			if key := AuthorKey(name); !seen[key] {
+				seen[key] = true
+				names = append(names, name)
+			}
+		}
+	}
+	a.AuthorsList = names
+	a.Author = strings.Join(names, ", ")
+}
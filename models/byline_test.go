@@ -0,0 +1,37 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseByline(t *testing.T) {
+	tests := []struct {
+		byline string
+		want   []string
+	}{
+		{"By Jane Doe", []string{"Jane Doe"}},
+		{"By Jane Doe and John Roe, Staff Writers", []string{"Jane Doe", "John Roe"}},
+		{"By Jane E Doe and John Roe", []string{"Jane E Doe", "John Roe"}},
+		{"By Michael Bloomberg", []string{"Michael Bloomberg"}},
+		{"By Jane Guest", []string{"Jane Guest"}},
+		{"By John Chief and Mary Press", []string{"John Chief", "Mary Press"}},
+		{"Jane Doe Staff Writer", []string{"Jane Doe"}},
+		{"By Jane Doe, Associated Press", []string{"Jane Doe"}},
+		{"Jane Doe and Reuters", []string{"Jane Doe"}},
+		{"Por María García y José Pérez", []string{"María García", "José Pérez"}},
+		{"Von Hans Müller und Eva Schmidt", []string{"Hans Müller", "Eva Schmidt"}},
+		{"By JANE DOE (jane@example.com)", []string{"Jane Doe"}},
+		{"By Dr. Jane Doe / @janedoe", []string{"Jane Doe"}},
+		{"Jane Doe with reporting by John Roe", []string{"Jane Doe", "John Roe"}},
+		{"By: Jane Doe; Jane Q. Doe", []string{"Jane Doe"}},
+		{"By Staff", nil},
+		{"Updated 2023-03-04", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := ParseByline(tt.byline); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseByline(%q) = %q, want %q", tt.byline, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/chuxorg/chux-models/errors"
+	"github.com/chuxorg/chux-models/logging"
+)
+
+// CategorizeProgress reports how far a Categorizer run has come
+type CategorizeProgress struct {
+	Total     int `json:"total"`
+	Processed int `json:"processed"`
+	Failed    int `json:"failed"`
+	Remaining int `json:"remaining"`
+}
+
+// CategorizeFailure records a product that could not be categorized
+type CategorizeFailure struct {
+	ProductID string `json:"productId"`
+	Err       error  `json:"-"`
+	Message   string `json:"message"`
+}
+
+// CategorizeResult is returned when a Categorizer run completes
+type CategorizeResult struct {
+	CategorizeProgress
+	Skipped  int                 `json:"skipped"`
+	Failures []CategorizeFailure `json:"failures"`
+}
+
+// The checkpoint written to disk so a restarted run can resume.
+// Categorized products drop out of the "isCategorized" query on their
+// own, so only the count of them is kept along with the failed products.
+type categorizeCheckpoint struct {
+	Processed int               `json:"processed"`
+	Failed    map[string]string `json:"failed"`
+}
+
+// Categorizer creates categories from product breadcrumbs with a pool of
+// workers. Failures are collected per product instead of stopping the run
+// and completed products can be checkpointed to a file so a restarted run
+// resumes where the previous one stopped.
+type Categorizer struct {
+	Workers            int
+	CheckpointPath     string
+	CheckpointInterval int
+	RetryFailed        bool
+	Progress           func(CategorizeProgress)
+	ProgressChannel    chan<- CategorizeProgress
+	Logger             *logging.Logger
+
+	mu         sync.Mutex
+	progress   CategorizeProgress
+	failures   []CategorizeFailure
+	checkpoint categorizeCheckpoint
+	sinceSave  int
+}
+
+// Creates a NewCategorizer with Options.
+// By default a single worker is used and no checkpoint is written.
+func NewCategorizer(options ...func(*Categorizer)) *Categorizer {
+	c := &Categorizer{
+		Workers:            1,
+		CheckpointInterval: 100,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	if c.Workers < 1 {
+		c.Workers = 1
+	}
+	if c.CheckpointInterval < 1 {
+		c.CheckpointInterval = 1
+	}
+	return c
+}
+
+func NewCategorizerWithLogger(logger logging.Logger) func(*Categorizer) {
+	return func(c *Categorizer) {
+		c.Logger = &logger
+	}
+}
+
+// Sets the number of products categorized concurrently
+func NewCategorizerWithWorkers(workers int) func(*Categorizer) {
+	return func(c *Categorizer) {
+		c.Workers = workers
+	}
+}
+
+// Sets the file used to checkpoint completed products and how
+// many products are completed between writes of the checkpoint.
+func NewCategorizerWithCheckpoint(path string, interval int) func(*Categorizer) {
+	return func(c *Categorizer) {
+		c.CheckpointPath = path
+		c.CheckpointInterval = interval
+	}
+}
+
+// When set, products that failed in a previous run are attempted again
+func NewCategorizerWithRetryFailed(retry bool) func(*Categorizer) {
+	return func(c *Categorizer) {
+		c.RetryFailed = retry
+	}
+}
+
+// Sets a callback that is called after every product
+func NewCategorizerWithProgress(progress func(CategorizeProgress)) func(*Categorizer) {
+	return func(c *Categorizer) {
+		c.Progress = progress
+	}
+}
+
+// Sets a channel that receives progress after every product.
+// Sends do not block, progress is dropped when the channel is full.
+func NewCategorizerWithProgressChannel(progress chan<- CategorizeProgress) func(*Categorizer) {
+	return func(c *Categorizer) {
+		c.ProgressChannel = progress
+	}
+}
+
+// Run categorizes all products that are not already categorized.
+// An error is only returned when the run could not start or the
+// checkpoint could not be written, per product errors are in the result.
+func (c *Categorizer) Run() (*CategorizeResult, error) {
+	logging := c.Logger
+	logging.Debug("Categorizer.Run() called")
+
+	err := c.loadCheckpoint()
+	if err != nil {
+		logging.Error("Categorizer.Run() Error loading checkpoint: %s", err.Error())
+		return nil, err
+	}
+
+	// - Get all products that are not categorized
+	prd := NewProduct()
+	prd.Logger = c.Logger
+	products, err := prd.Query("isCategorized", false)
+	if err != nil {
+		logging.Error("Categorizer.Run() Error querying database: %s", err.Error())
+		return nil, errors.NewChuxModelsError("Categorizer.Run() Error querying database", err)
+	}
+
+	// -- Skip products that failed in a previous run
+	pending := make([]*Product, 0, len(products))
+	for _, doc := range products {
+		product := doc.(*Product)
+		if _, failed := c.checkpoint.Failed[product.ID.Hex()]; failed && !c.RetryFailed {
+			continue
+		}
+		pending = append(pending, product)
+	}
+	skipped := len(products) - len(pending)
+	logging.Info("Categorizer.Run() Found %d products to categorize, skipping %d from checkpoint", len(pending), skipped)
+
+	c.progress = CategorizeProgress{Total: len(pending), Remaining: len(pending)}
+	c.failures = nil
+
+	work := make(chan *Product)
+	var wg sync.WaitGroup
+	for i := 0; i < c.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for product := range work {
+				product.Logger = c.Logger
+				c.complete(product.ID.Hex(), categorizeProduct(product, c.Logger))
+			}
+		}()
+	}
+	for _, product := range pending {
+		work <- product
+	}
+	close(work)
+	wg.Wait()
+
+	err = c.saveCheckpoint()
+	if err != nil {
+		logging.Error("Categorizer.Run() Error saving checkpoint: %s", err.Error())
+		return nil, err
+	}
+
+	result := &CategorizeResult{
+		CategorizeProgress: c.progress,
+		Skipped:            skipped,
+		Failures:           c.failures,
+	}
+	logging.Info("Categorizer.Run() Done categorizing products. Processed: %d Failed: %d", result.Processed, result.Failed)
+	return result, nil
+}
+
+// Records the outcome of a single product and reports progress
+func (c *Categorizer) complete(productID string, err error) {
+	logging := c.Logger
+	c.mu.Lock()
+	if err != nil {
+		logging.Error("Categorizer.Run() Error categorizing product %s: %s", productID, err.Error())
+		c.progress.Failed++
+		c.failures = append(c.failures, CategorizeFailure{ProductID: productID, Err: err, Message: err.Error()})
+		c.checkpoint.Failed[productID] = err.Error()
+	} else {
+		c.progress.Processed++
+		c.checkpoint.Processed++
+		delete(c.checkpoint.Failed, productID)
+	}
+	c.progress.Remaining = c.progress.Total - c.progress.Processed - c.progress.Failed
+	progress := c.progress
+
+	c.sinceSave++
+	if c.sinceSave >= c.CheckpointInterval {
+		c.sinceSave = 0
+		if cpErr := c.writeCheckpoint(); cpErr != nil {
+			logging.Warning("Categorizer.Run() Unable to write checkpoint: %s", cpErr.Error())
+		}
+	}
+	c.mu.Unlock()
+
+	if c.Progress != nil {
+		c.Progress(progress)
+	}
+	if c.ProgressChannel != nil {
+		select {
+		case c.ProgressChannel <- progress:
+		default:
+		}
+	}
+}
+
+func (c *Categorizer) loadCheckpoint() error {
+	c.checkpoint = categorizeCheckpoint{Failed: make(map[string]string)}
+	if c.CheckpointPath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(c.CheckpointPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.NewChuxModelsError("Categorizer.loadCheckpoint() Unable to read checkpoint", err)
+	}
+	err = json.Unmarshal(data, &c.checkpoint)
+	if err != nil {
+		msg := fmt.Sprintf("Categorizer.loadCheckpoint() Invalid checkpoint file: %s", c.CheckpointPath)
+		return errors.NewChuxModelsError(msg, err)
+	}
+	if c.checkpoint.Failed == nil {
+		c.checkpoint.Failed = make(map[string]string)
+	}
+	return nil
+}
+
+func (c *Categorizer) saveCheckpoint() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.writeCheckpoint()
+}
+
+// Writes the checkpoint atomically, the caller must hold c.mu
+func (c *Categorizer) writeCheckpoint() error {
+	if c.CheckpointPath == "" {
+		return nil
+	}
+	data, err := json.Marshal(c.checkpoint)
+	if err != nil {
+		return errors.NewChuxModelsError("Categorizer.writeCheckpoint() Unable to serialize checkpoint", err)
+	}
+	tmp := c.CheckpointPath + ".tmp"
+	err = os.WriteFile(tmp, data, 0644)
+	if err != nil {
+		return errors.NewChuxModelsError("Categorizer.writeCheckpoint() Unable to write checkpoint", err)
+	}
+	err = os.Rename(tmp, c.CheckpointPath)
+	if err != nil {
+		return errors.NewChuxModelsError("Categorizer.writeCheckpoint() Unable to replace checkpoint", err)
+	}
+	return nil
+}
+
+// Creates a category for each of the product's breadcrumbs, links the
+// categories into a tree and points the product at the deepest category.
+func categorizeProduct(pd *Product, logging *logging.Logger) error {
+	err := pd.markLoaded()
+	if err != nil {
+		return errors.NewChuxModelsError("Product.Categorize() Error loading product", err)
+	}
+
+	// -- Iterate over the product's breadcrumbs and create categories
+	createdCategories := make([]*Category, len(pd.Breadcrumbs))
+	for index, breadcrumb := range pd.Breadcrumbs {
+		// -- Create a category document. NewCategory() is not used because it
+		// -- replaces the shared MongoDB configuration, which is not safe while
+		// -- other workers are saving.
+		category := &Category{Logger: logging, isNew: true}
+		category.Name = breadcrumb.Name
+		category.Index = index
+
+		err := category.Save()
+		if err != nil {
+			logging.Error("Product.Categorize() Error saving category: %s", err.Error())
+			return errors.NewChuxModelsError("Product.Categorize() Error saving category", err)
+		}
+		createdCategories[index] = category
+	}
+
+	/*
+		After all categories are created for a product, iterate over the created categories and set the ParentID accordingly.
+		The first category in the list (index 0) is its own parent.
+		This will help with the tree structure of the categories.
+	*/
+	logging.Info("Product.Categorize() Setting ParentID for %d categories", len(createdCategories))
+	for index, category := range createdCategories {
+		if index > 0 {
+			category.ParentID = createdCategories[index-1].ID
+		} else {
+			category.ParentID = category.ID
+		}
+		err := category.Save()
+		if err != nil {
+			logging.Error("Product.Categorize() Error updating category ParentID: %s", err.Error())
+			return errors.NewChuxModelsError("Product.Categorize() Error updating category ParentID", err)
+		}
+	}
+
+	pd.IsCategorized = true
+	if len(createdCategories) > 0 {
+		pd.CategoryID = createdCategories[len(createdCategories)-1].ID
+	}
+	err = pd.Save()
+	if err != nil {
+		logging.Error("Product.Categorize() Error setting product CategoryID: %s", err.Error())
+		return errors.NewChuxModelsError("Product.Categorize() Error setting product's CategoryID", err)
+	}
+	return nil
+}
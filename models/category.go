@@ -14,18 +14,22 @@ import (
 )
 
 type Category struct {
-	ID            primitive.ObjectID `bson:"_id,omitempty"`
-	ProductID     primitive.ObjectID `bson:"product_id"`
-	Name          string             `bson:"name"`
-	Index         int                `bson:"index"`
-	ParentID      primitive.ObjectID `bson:"parent_id"`
-	isNew         bool               `bson:"-" json:"-"`
-	isDeleted     bool               `bson:"-" json:"-"`
-	isDirty       bool               `bson:"-" json:"-"`
-	originalState *Category          `bson:"-" json:"-"`
-	DateCreated   CustomTime         `bson:"dateCreated,omitempty" json:"dateCreated,omitempty"`
-	DateModified  CustomTime         `bson:"dateModified,omitempty" json:"dateModified,omitempty"`
-	Logger        *logging.Logger    `bson:"-" json:"-"`
+	ID               primitive.ObjectID `bson:"_id,omitempty"`
+	ProductID        primitive.ObjectID `bson:"product_id"`
+	Name             string             `bson:"name"`
+	Index            int                `bson:"index"`
+	ParentID         primitive.ObjectID `bson:"parent_id"`
+	TaxonomyID       int                `bson:"taxonomyId,omitempty"`
+	TaxonomyScore    float64            `bson:"taxonomyScore,omitempty"`
+	TaxonomyOverride bool               `bson:"taxonomyOverride"`
+	isNew            bool               `bson:"-" json:"-"`
+	isDeleted        bool               `bson:"-" json:"-"`
+	isDirty          bool               `bson:"-" json:"-"`
+	originalState    *Category          `bson:"-" json:"-"`
+	DateCreated      CustomTime         `bson:"dateCreated,omitempty" json:"dateCreated,omitempty"`
+	DateModified     CustomTime         `bson:"dateModified,omitempty" json:"dateModified,omitempty"`
+	timeConfig       *CustomTimeConfig  `bson:"-" json:"-"`
+	Logger           *logging.Logger    `bson:"-" json:"-"`
 }
 
 // Creates a NewCategory with Options
@@ -58,6 +62,14 @@ func NewCategoryWithLogger(logger logging.Logger) func(*Category) {
 	}
 }
 
+// Sets how the Category's CustomTimes are written to JSON and stored in Mongo,
+// without it they use the DefaultCustomTimeConfig
+func NewCategoryWithTimeConfig(config CustomTimeConfig) func(*Category) {
+	return func(c *Category) {
+		c.timeConfig = &config
+	}
+}
+
 // GetCollectionName returns the name of the collection
 func (c *Category) GetCollectionName() string {
 	c.Logger.Debug("GetCollectionName() called")
@@ -168,10 +180,69 @@ func (c *Category) CompareCategories(oldCategory, newCategory Category) (map[str
 	return changes, nil
 }
 
+// SuggestTaxonomy returns up to limit Google Product Taxonomy
+// nodes that best match the Category's name.
+func (c *Category) SuggestTaxonomy(t *Taxonomy, limit int) []TaxonomyMatch {
+	logging := c.Logger
+	logging.Debug("Category.SuggestTaxonomy() called")
+	return t.Suggest(c.Name, limit)
+}
+
+// MapToTaxonomy sets the Category's TaxonomyID to the best suggestion
+// scoring at least minScore. Categories with a manual override are left untouched.
+// Returns true when the TaxonomyID was changed.
+func (c *Category) MapToTaxonomy(t *Taxonomy, minScore float64) bool {
+	logging := c.Logger
+	logging.Debug("Category.MapToTaxonomy() called")
+	if c.TaxonomyOverride {
+		logging.Info("Category.MapToTaxonomy() Category %s has a manual taxonomy override", c.Name)
+		return false
+	}
+	matches := t.Suggest(c.Name, 1)
+	if len(matches) == 0 || matches[0].Score < minScore {
+		logging.Info("Category.MapToTaxonomy() No taxonomy match for Category %s", c.Name)
+		return false
+	}
+	if c.TaxonomyID == matches[0].Node.ID {
+		return false
+	}
+	c.TaxonomyID = matches[0].Node.ID
+	c.TaxonomyScore = matches[0].Score
+	logging.Info("Category.MapToTaxonomy() Mapped Category %s to %s", c.Name, matches[0].Node.FullPath())
+	return true
+}
+
+// OverrideTaxonomy manually maps the Category to a taxonomy id.
+// The mapping sticks across later calls to MapToTaxonomy.
+func (c *Category) OverrideTaxonomy(t *Taxonomy, id int) error {
+	logging := c.Logger
+	logging.Debug("Category.OverrideTaxonomy() called")
+	if _, ok := t.Node(id); !ok {
+		msg := fmt.Sprintf("Category.OverrideTaxonomy() Unknown taxonomy id: %d", id)
+		logging.Error(msg)
+		return errors.NewChuxModelsError(msg, nil)
+	}
+	c.TaxonomyID = id
+	c.TaxonomyScore = 1
+	c.TaxonomyOverride = true
+	return nil
+}
+
+// ClearTaxonomyOverride removes a manual mapping so the Category
+// can be mapped by suggestion again.
+func (c *Category) ClearTaxonomyOverride() {
+	logging := c.Logger
+	logging.Debug("Category.ClearTaxonomyOverride() called")
+	c.TaxonomyID = 0
+	c.TaxonomyScore = 0
+	c.TaxonomyOverride = false
+}
+
 // Saves the Model to a Data Store
 func (c *Category) Save() error {
 	logging := c.Logger
 	logging.Debug("Save() called")
+	applyTimeConfig(c.timeConfig, c)
 	if c.isNew {
 		logging.Info("Save() Category isNew")
 		// -- Set the date created to now
@@ -293,6 +364,20 @@ func (c *Category) SetState(json string) error {
 	return c.Deserialize([]byte(json))
 }
 
+// Marks a Category returned by Query() as loaded so that
+// changes made to it are persisted by Save()
+func (c *Category) markLoaded() error {
+	serialized, err := c.Serialize()
+	if err != nil {
+		return errors.NewChuxModelsError("Category.markLoaded() Error serializing Category", err)
+	}
+	c.SetState(serialized)
+	c.isNew = false
+	c.isDirty = false
+	c.isDeleted = false
+	return nil
+}
+
 // Sets the internal state of the model of a new Category
 // from a JSON String.
 func (c *Category) Parse(json string) error {
@@ -314,6 +399,7 @@ func (c *Category) Search(args ...interface{}) ([]interface{}, error) {
 func (c *Category) Serialize() (string, error) {
 	logging := c.Logger
 	logging.Debug("Category.Serialize() called")
+	applyTimeConfig(c.timeConfig, c)
 	bytes, err := json.Marshal(c)
 	if err != nil {
 		logging.Error("Category.Serialize() Error serializing Category: %s", err.Error())
@@ -325,6 +411,7 @@ func (c *Category) Serialize() (string, error) {
 func (c *Category) Deserialize(jsonData []byte) error {
 	logging := c.Logger
 	logging.Debug("Category.Deserialize() called")
+	applyTimeConfig(c.timeConfig, c)
 	err := json.Unmarshal(jsonData, c)
 	if err != nil {
 		logging.Error("Category.Deserialize() Error deserializing Category: %s", err.Error())
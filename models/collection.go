@@ -0,0 +1,54 @@
+package models
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/chuxorg/chux-datastore/db"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Timeout of the operations made with the driver, the same as the datastore's
+const driverTimeout = 30 * time.Second
+
+// The unique indexes created by ensureUniqueIndex, by collection and keys
+var uniqueIndexes sync.Map
+
+// Returns the driver collection a Model is stored in, for the atomic
+// updates, sorted queries and aggregations the datastore does not have.
+// The context is cancelled by the returned func.
+func collectionOf(doc db.IMongoDocument) (*mongo.Collection, context.Context, context.CancelFunc, error) {
+	client, err := mongoDB.Connect()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	collection := client.Database(doc.GetDatabaseName()).Collection(doc.GetCollectionName())
+	ctx, cancel := context.WithTimeout(context.Background(), driverTimeout)
+	return collection, ctx, cancel, nil
+}
+
+// Creates a unique index on the keys of a collection, once per process.
+// Creating an index that exists already does nothing.
+func ensureUniqueIndex(ctx context.Context, collection *mongo.Collection, keys ...string) error {
+	name := collection.Database().Name() + "." + collection.Name()
+	index := bson.D{}
+	for _, key := range keys {
+		name += "." + key
+		index = append(index, bson.E{Key: key, Value: 1})
+	}
+	if _, done := uniqueIndexes.Load(name); done {
+		return nil
+	}
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    index,
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+	uniqueIndexes.Store(name, true)
+	return nil
+}
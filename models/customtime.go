@@ -2,28 +2,156 @@ package models
 
 import (
 	"encoding/json"
+	"fmt"
+	"reflect"
 	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
 )
 
 // CustomTime is a struct used to hold time types in a struct
 // so that the struct can be marshalled, unmarshalled
 type CustomTime struct {
 	time.Time
+	// Set by the Model the CustomTime belongs to, nil for the DefaultCustomTimeConfig
+	config *CustomTimeConfig
+}
+
+// How a zero CustomTime is written
+type ZeroTimePolicy int
+
+const (
+	// A zero time is written as JSON null and BSON null
+	ZeroTimeAsNull ZeroTimePolicy = iota
+	// A zero time is written as 0001-01-01T00:00:00Z
+	ZeroTimeAsTime
+)
+
+// How a CustomTime is stored in Mongo
+type TimeBSONMode int
+
+const (
+	// Stored as a BSON date, which can be queried and sorted but is
+	// always UTC with millisecond precision. The time zone offset is
+	// lost, a time read back from Mongo is in UTC.
+	TimeBSONDateTime TimeBSONMode = iota
+	// Stored as a document {time, offset, zone} so that the original
+	// time zone offset is kept when the time is read back. Queries and
+	// sorts have to use the "time" field of the document.
+	TimeBSONDocument
+)
+
+// CustomTimeConfig is how CustomTimes are written to JSON and stored in
+// Mongo. Models are given one with their WithTimeConfig option, e.g.
+// NewArticleWithTimeConfig, so that it can differ between Models.
+type CustomTimeConfig struct {
+	// Layout CustomTimes are written to JSON in
+	Format string
+	// How zero CustomTimes are written to JSON and BSON
+	ZeroPolicy ZeroTimePolicy
+	// How CustomTimes are stored in Mongo
+	BSONMode TimeBSONMode
+}
+
+// DefaultCustomTimeConfig writes CustomTimes to JSON as RFC 3339 and
+// zero times as null. They are stored as BSON dates so that they can
+// be queried, which keeps the instant but not the time zone offset, a
+// round trip through Mongo returns the time in UTC. Use TimeBSONDocument
+// for Models that need the offset back.
+func DefaultCustomTimeConfig() CustomTimeConfig {
+	return CustomTimeConfig{
+		Format:     time.RFC3339Nano,
+		ZeroPolicy: ZeroTimeAsNull,
+		BSONMode:   TimeBSONDateTime,
+	}
+}
+
+// The config the CustomTime is written with
+func (ct CustomTime) settings() CustomTimeConfig {
+	if ct.config == nil {
+		return DefaultCustomTimeConfig()
+	}
+	return *ct.config
+}
+
+var customTimeType = reflect.TypeOf(CustomTime{})
+
+// Gives every CustomTime of a Model, including the ones in its nested
+// structs and slices, the config it is written with. Nothing is changed
+// when config is nil.
+func applyTimeConfig(config *CustomTimeConfig, model interface{}) {
+	if config == nil {
+		return
+	}
+	setTimeConfig(reflect.ValueOf(model), config)
+}
+
+func setTimeConfig(v reflect.Value, config *CustomTimeConfig) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			setTimeConfig(v.Elem(), config)
+		}
+	case reflect.Struct:
+		if v.Type() == customTimeType {
+			if v.CanAddr() {
+				v.Addr().Interface().(*CustomTime).config = config
+			}
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			// -- Unexported fields are not written, and hold the originalState and options
+			if v.Type().Field(i).IsExported() {
+				setTimeConfig(v.Field(i), config)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			setTimeConfig(v.Index(i), config)
+		}
+	}
+}
+
+func (ct CustomTime) MarshalJSON() ([]byte, error) {
+	config := ct.settings()
+	if ct.IsZero() && config.ZeroPolicy == ZeroTimeAsNull {
+		return []byte("null"), nil
+	}
+	return json.Marshal(ct.Format(config.Format))
 }
 
 func (ct *CustomTime) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		ct.Time = time.Time{}
+		return nil
+	}
 	var dateString string
 	err := json.Unmarshal(b, &dateString)
 	if err != nil {
 		return err
 	}
+	return ct.parse(dateString)
+}
+
+// Reads a CustomTime from a string in the Format of its config or any
+// format the DateParser knows. An empty string is a zero time.
+func (ct *CustomTime) parse(dateString string) error {
+	if dateString == "" {
+		ct.Time = time.Time{}
+		return nil
+	}
 
 	dateFormats := []string{
+		ct.settings().Format,
 		time.RFC3339,
 		"2006-01-02T15:04:05",
 		"2006-01-02",
 	}
 
+	var err error
 	var parsedTime time.Time
 	for _, format := range dateFormats {
 		parsedTime, err = time.Parse(format, dateString)
@@ -33,10 +161,78 @@ func (ct *CustomTime) UnmarshalJSON(b []byte) error {
 		}
 	}
 
+	// -- Fall back to the formats the DateParser knows, relative
+	// dates are not accepted since there is nothing to anchor them to
+	parser := NewDateParser()
+	if parsed, ok := parseEpoch(dateString); ok {
+		ct.Time = parsed.Time
+		return nil
+	}
+	if parsed, ok := parser.parseLayouts(dateString); ok {
+		ct.Time = parsed.Time
+		return nil
+	}
+
 	return err
 }
 
+func (ct CustomTime) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	config := ct.settings()
+	if ct.IsZero() && config.ZeroPolicy == ZeroTimeAsNull {
+		return bsontype.Null, nil, nil
+	}
+	if config.BSONMode == TimeBSONDocument {
+		zone, offset := ct.Zone()
+		doc, err := bson.Marshal(bson.D{
+			{Key: "time", Value: primitive.NewDateTimeFromTime(ct.Time)},
+			{Key: "offset", Value: int32(offset)},
+			{Key: "zone", Value: zone},
+		})
+		return bsontype.EmbeddedDocument, doc, err
+	}
+	return bsontype.DateTime, bsoncore.AppendDateTime(nil, ct.UnixMilli()), nil
+}
+
+// Reads a BSON date, null, a time stored as a document, including the
+// {time} documents written before CustomTime had a BSON encoding, or a string.
+func (ct *CustomTime) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	value := bson.RawValue{Type: t, Value: data}
+	switch t {
+	case bsontype.Null, bsontype.Undefined:
+		ct.Time = time.Time{}
+		return nil
+	case bsontype.DateTime:
+		ct.Time = value.Time().UTC()
+		return nil
+	case bsontype.String:
+		return ct.parse(value.StringValue())
+	case bsontype.EmbeddedDocument:
+		doc := value.Document()
+		stored, err := doc.LookupErr("time")
+		if err != nil {
+			return err
+		}
+		if stored.Type == bsontype.Null {
+			ct.Time = time.Time{}
+			return nil
+		}
+		parsed, ok := stored.TimeOK()
+		if !ok {
+			return fmt.Errorf("CustomTime.UnmarshalBSONValue() time is a %s", stored.Type)
+		}
+		ct.Time = parsed.UTC()
+		if offset, ok := doc.Lookup("offset").Int32OK(); ok {
+			zone, _ := doc.Lookup("zone").StringValueOK()
+			ct.Time = ct.In(time.FixedZone(zone, int(offset)))
+		}
+		return nil
+	}
+	return fmt.Errorf("CustomTime.UnmarshalBSONValue() cannot read a %s", t)
+}
+
+// Now sets the time to now in UTC, truncated to milliseconds
+// which is the precision a BSON date is stored with.
 func (ct *CustomTime) Now() {
 
-	ct.Time = time.Now()
+	ct.Time = time.Now().UTC().Truncate(time.Millisecond)
 }
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCustomTimeBSONRoundTrip(t *testing.T) {
+	zone := time.FixedZone("EST", -5*3600)
+	written := time.Date(2023, 3, 4, 10, 10, 10, 0, zone)
+	tests := []struct {
+		name   string
+		config *CustomTimeConfig
+		want   time.Time
+	}{
+		{"default", nil, written.UTC()},
+		{"document", &CustomTimeConfig{Format: time.RFC3339Nano, BSONMode: TimeBSONDocument}, written},
+	}
+	for _, tt := range tests {
+		brand := &Brand{CanonicalName: "Acme", DateCreated: CustomTime{Time: written}, timeConfig: tt.config}
+		applyTimeConfig(brand.timeConfig, brand)
+		data, err := bson.Marshal(brand)
+		if err != nil {
+			t.Fatalf("%s: bson.Marshal() error: %v", tt.name, err)
+		}
+		var read Brand
+		if err := bson.Unmarshal(data, &read); err != nil {
+			t.Fatalf("%s: bson.Unmarshal() error: %v", tt.name, err)
+		}
+		got := read.DateCreated.Time
+		_, gotOffset := got.Zone()
+		_, wantOffset := tt.want.Zone()
+		if !got.Equal(tt.want) || gotOffset != wantOffset {
+			t.Errorf("%s: read back %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCustomTimeConfigPerModel(t *testing.T) {
+	date := CustomTime{Time: time.Date(2023, 3, 4, 0, 0, 0, 0, time.UTC)}
+	dayOnly := NewBrandWithTimeConfig(CustomTimeConfig{Format: "2006-01-02", ZeroPolicy: ZeroTimeAsTime})
+	configured := &Brand{CanonicalName: "Acme", DateCreated: date}
+	dayOnly(configured)
+	plain := &Brand{CanonicalName: "Acme", DateCreated: date}
+
+	got, err := configured.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"_id":"000000000000000000000000","canonicalName":"Acme","aliases":null,"parentId":"000000000000000000000000","dateCreated":"2023-03-04","dateModified":"0001-01-01"}`
+	if got != want {
+		t.Errorf("Serialize() with a config = %s, want %s", got, want)
+	}
+	got, err = plain.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `{"_id":"000000000000000000000000","canonicalName":"Acme","aliases":null,"parentId":"000000000000000000000000","dateCreated":"2023-03-04T00:00:00Z","dateModified":null}`
+	if got != want {
+		t.Errorf("Serialize() without a config = %s, want %s", got, want)
+	}
+}
+
+// CustomTimes nested in structs, pointers and slices of a Model get its config too
+func TestApplyTimeConfigNested(t *testing.T) {
+	type dated struct {
+		At CustomTime
+	}
+	model := &struct {
+		Dates  []dated
+		Latest *dated
+		hidden dated
+	}{Dates: []dated{{}, {}}, Latest: &dated{}}
+	config := &CustomTimeConfig{Format: "2006"}
+	applyTimeConfig(config, model)
+	for i, date := range model.Dates {
+		if date.At.config != config {
+			t.Errorf("Dates[%d].At has no config", i)
+		}
+	}
+	if model.Latest.At.config != config {
+		t.Error("Latest.At has no config")
+	}
+	if model.hidden.At.config != nil {
+		t.Error("hidden.At has a config, unexported fields are left alone")
+	}
+}
@@ -0,0 +1,330 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chuxorg/chux-models/errors"
+	"github.com/chuxorg/chux-models/logging"
+)
+
+// The Format of a ParsedDate that was not matched by a layout
+const (
+	DateFormatEpochSeconds      = "epochSeconds"
+	DateFormatEpochMilliseconds = "epochMilliseconds"
+	DateFormatRelative          = "relative"
+)
+
+// ParsedDate is a date read from free text and the format it matched
+type ParsedDate struct {
+	Time time.Time
+	// The name of the layout that matched, or one of the DateFormat constants
+	Format string
+}
+
+// A layout tried by the DateParser and the name it is reported as
+type dateLayout struct {
+	name   string
+	layout string
+}
+
+// Layouts that are not ambiguous, tried in order
+var dateLayouts = []dateLayout{
+	{"RFC3339", time.RFC3339Nano},
+	{"ISO8601", "2006-01-02T15:04:05.999999999"},
+	{"ISO8601", "2006-01-02T15:04:05.999999999-0700"},
+	{"ISO8601", "2006-01-02T15:04"},
+	{"ISO8601", "2006-01-02 15:04:05.999999999Z07:00"},
+	{"ISO8601", "2006-01-02 15:04:05.999999999 -0700"},
+	{"ISO8601", "2006-01-02 15:04:05.999999999"},
+	{"ISO8601", "2006-01-02 15:04"},
+	{"ISO8601", "2006-01-02"},
+	{"ISO8601", "2006/01/02 15:04:05"},
+	{"ISO8601", "2006/01/02"},
+	{"RFC1123", time.RFC1123},
+	{"RFC1123Z", time.RFC1123Z},
+	{"RFC1123", "Mon, 2 Jan 2006 15:04:05"},
+	{"RFC1123", "Mon, 2 Jan 2006 15:04"},
+	{"RFC1123", "2 Jan 2006 15:04:05"},
+	{"RFC1123Z", "Mon, 2 Jan 2006 15:04:05 -0700"},
+	{"RFC1123Z", "Mon, 2 Jan 2006 15:04 -0700"},
+	{"RFC850", "Monday, 02-Jan-06 15:04:05"},
+	{"RFC822", "02 Jan 06 15:04"},
+	{"RFC822Z", time.RFC822Z},
+	{"UnixDate", "Mon Jan _2 15:04:05 2006"},
+	{"January 2, 2006", "Monday, January 2, 2006 3:04 PM -0700"},
+	{"January 2, 2006", "Monday, January 2, 2006 15:04 -0700"},
+	{"January 2, 2006", "Monday, January 2, 2006 3:04 PM"},
+	{"January 2, 2006", "Monday, January 2, 2006"},
+	{"January 2, 2006", "January 2, 2006 3:04 PM -0700"},
+	{"January 2, 2006", "January 2, 2006 15:04 -0700"},
+	{"January 2, 2006", "January 2, 2006 3:04:05 PM"},
+	{"January 2, 2006", "January 2, 2006 3:04 PM"},
+	{"January 2, 2006", "January 2, 2006 15:04"},
+	{"January 2, 2006", "January 2, 2006"},
+	{"January 2, 2006", "January 2 2006"},
+	{"Jan 2, 2006", "Mon, Jan 2, 2006"},
+	{"Jan 2, 2006", "Jan 2, 2006 3:04 PM -0700"},
+	{"Jan 2, 2006", "Jan 2, 2006 3:04 PM"},
+	{"Jan 2, 2006", "Jan 2, 2006 15:04"},
+	{"Jan 2, 2006", "Jan 2, 2006"},
+	{"Jan 2, 2006", "Jan. 2, 2006"},
+	{"Jan 2, 2006", "Jan 2 2006"},
+	{"2 January 2006", "Monday, 2 January 2006 15:04 -0700"},
+	{"2 January 2006", "Monday 2 January 2006"},
+	{"2 January 2006", "2 January 2006 15:04 -0700"},
+	{"2 January 2006", "2 January 2006 15:04"},
+	{"2 January 2006", "2 January 2006"},
+	{"2 Jan 2006", "Mon 2 Jan 2006"},
+	{"2 Jan 2006", "2 Jan 2006 15:04 -0700"},
+	{"2 Jan 2006", "2 Jan 2006 15:04"},
+	{"2 Jan 2006", "2 Jan 2006"},
+	{"January 2006", "January 2006"},
+	{"02.01.2006", "2.1.2006 15:04:05"},
+	{"02.01.2006", "2.1.2006 15:04"},
+	{"02.01.2006", "2.1.2006"},
+	{"02-01-2006", "2-1-2006 15:04"},
+	{"02-01-2006", "2-1-2006"},
+	{"20060102150405", "20060102150405"},
+	{"20060102", "20060102"},
+}
+
+// Numeric dates that could be day first or month first, in both orders
+var (
+	dayFirstLayouts = []dateLayout{
+		{"02/01/2006", "2/1/2006 15:04:05"},
+		{"02/01/2006", "2/1/2006 15:04"},
+		{"02/01/2006", "2/1/2006"},
+		{"02/01/06", "2/1/06"},
+	}
+	monthFirstLayouts = []dateLayout{
+		{"01/02/2006", "1/2/2006 3:04:05 PM"},
+		{"01/02/2006", "1/2/2006 3:04 PM"},
+		{"01/02/2006", "1/2/2006 15:04:05"},
+		{"01/02/2006", "1/2/2006 15:04"},
+		{"01/02/2006", "1/2/2006"},
+		{"01/02/06", "1/2/06"},
+	}
+)
+
+// Time zone abbreviations and their offsets from UTC in hours.
+// Go only knows the offset of an abbreviation in the local time zone.
+var timeZoneOffsets = map[string]float64{
+	"UTC": 0, "GMT": 0, "Z": 0, "UT": 0,
+	"EST": -5, "EDT": -4, "CST": -6, "CDT": -5, "MST": -7, "MDT": -6,
+	"PST": -8, "PDT": -7, "AKST": -9, "AKDT": -8, "HST": -10,
+	"AST": -4, "ADT": -3, "NST": -3.5, "NDT": -2.5,
+	"WET": 0, "WEST": 1, "BST": 1, "IST": 5.5, "CET": 1, "CEST": 2,
+	"EET": 2, "EEST": 3, "MSK": 3, "GST": 4, "PKT": 5, "SGT": 8,
+	"HKT": 8, "JST": 9, "KST": 9, "AWST": 8, "ACST": 9.5, "ACDT": 10.5,
+	"AEST": 10, "AEDT": 11, "NZST": 12, "NZDT": 13,
+}
+
+var (
+	epochRegex     = regexp.MustCompile(`^\d{9,13}(\.\d+)?$`)
+	relativeRegex  = regexp.MustCompile(`^(?:about |over |almost )?(\d+|an?|one) (second|sec|minute|min|hour|hr|day|week|month|year)s? ago$`)
+	ordinalRegex   = regexp.MustCompile(`(?i)\b(\d{1,2})(st|nd|rd|th)\b`)
+	zoneNameRegex  = regexp.MustCompile(`\s*\(?\b([A-Z]{1,4}T|UTC|GMT|UT|Z|[A-Z][a-z]+/[A-Za-z_]+(?:/[A-Za-z_]+)?)\)?$`)
+	publishedRegex = regexp.MustCompile(`(?i)^((published|posted|updated|last updated|modified|on)\s*:?\s+)+`)
+)
+
+// DateParser reads dates in the many formats sites publish them in.
+// Relative dates such as "2 hours ago" are anchored to Reference, the
+// time the page was crawled, and dates without a zone are read in Location.
+type DateParser struct {
+	Reference time.Time
+	Location  *time.Location
+	// Reads ambiguous numeric dates such as 03/04/2023 as day first
+	DayFirst bool
+	Logger   *logging.Logger
+}
+
+// Creates a NewDateParser with Options. By default dates without
+// a zone are UTC, relative dates are anchored to now and
+// ambiguous numeric dates are read month first.
+func NewDateParser(options ...func(*DateParser)) *DateParser {
+	d := &DateParser{
+		Location: time.UTC,
+	}
+	for _, option := range options {
+		option(d)
+	}
+	if d.Location == nil {
+		d.Location = time.UTC
+	}
+	return d
+}
+
+func NewDateParserWithLogger(logger logging.Logger) func(*DateParser) {
+	return func(d *DateParser) {
+		d.Logger = &logger
+	}
+}
+
+// Sets the time relative dates are anchored to, usually the crawl time
+func NewDateParserWithReference(reference time.Time) func(*DateParser) {
+	return func(d *DateParser) {
+		d.Reference = reference
+	}
+}
+
+// Sets the time zone of dates that do not have one
+func NewDateParserWithLocation(location *time.Location) func(*DateParser) {
+	return func(d *DateParser) {
+		d.Location = location
+	}
+}
+
+// Sets whether ambiguous numeric dates are read day first, as in Europe
+func NewDateParserWithDayFirst(dayFirst bool) func(*DateParser) {
+	return func(d *DateParser) {
+		d.DayFirst = dayFirst
+	}
+}
+
+// Parse reads a date and reports the format it matched
+func (d *DateParser) Parse(raw string) (ParsedDate, error) {
+	logging := d.Logger
+	value := strings.TrimSpace(raw)
+	if value == "" {
+		return ParsedDate{}, errors.NewChuxModelsError("DateParser.Parse() Date is empty", nil)
+	}
+
+	if parsed, ok := parseEpoch(value); ok {
+		return parsed, nil
+	}
+	if parsed, ok := d.parseRelative(value); ok {
+		return parsed, nil
+	}
+	if parsed, ok := d.parseLayouts(value); ok {
+		return parsed, nil
+	}
+
+	msg := fmt.Sprintf("DateParser.Parse() Unable to parse date: %s", raw)
+	logging.Debug(msg)
+	return ParsedDate{}, errors.NewChuxModelsError(msg, nil)
+}
+
+// Reads Unix epoch seconds or milliseconds. Milliseconds have 13 digits
+// until the year 2286, longer numbers such as 20230304101010 are dates.
+func parseEpoch(value string) (ParsedDate, bool) {
+	if !epochRegex.MatchString(value) {
+		return ParsedDate{}, false
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return ParsedDate{}, false
+	}
+	// -- Seconds have at most 11 digits until the year 5138
+	if len(strings.Split(value, ".")[0]) <= 11 {
+		sec := int64(number)
+		nsec := int64((number - float64(sec)) * 1e9)
+		return ParsedDate{Time: time.Unix(sec, nsec).UTC(), Format: DateFormatEpochSeconds}, true
+	}
+	return ParsedDate{Time: time.UnixMilli(int64(number)).UTC(), Format: DateFormatEpochMilliseconds}, true
+}
+
+// Reads "just now", "today", "yesterday" and "3 hours ago"
+func (d *DateParser) parseRelative(value string) (ParsedDate, bool) {
+	reference := d.Reference
+	if reference.IsZero() {
+		reference = time.Now().Round(0)
+	}
+	text := strings.ToLower(strings.TrimSpace(publishedRegex.ReplaceAllString(value, "")))
+	switch text {
+	case "now", "just now", "moments ago", "a moment ago", "today":
+		return ParsedDate{Time: reference, Format: DateFormatRelative}, true
+	case "yesterday":
+		return ParsedDate{Time: reference.AddDate(0, 0, -1), Format: DateFormatRelative}, true
+	}
+	match := relativeRegex.FindStringSubmatch(text)
+	if match == nil {
+		return ParsedDate{}, false
+	}
+	amount := 1
+	if n, err := strconv.Atoi(match[1]); err == nil {
+		amount = n
+	}
+	var t time.Time
+	switch match[2] {
+	case "second", "sec":
+		t = reference.Add(-time.Duration(amount) * time.Second)
+	case "minute", "min":
+		t = reference.Add(-time.Duration(amount) * time.Minute)
+	case "hour", "hr":
+		t = reference.Add(-time.Duration(amount) * time.Hour)
+	case "day":
+		t = reference.AddDate(0, 0, -amount)
+	case "week":
+		t = reference.AddDate(0, 0, -7*amount)
+	case "month":
+		t = reference.AddDate(0, -amount, 0)
+	case "year":
+		t = reference.AddDate(-amount, 0, 0)
+	}
+	return ParsedDate{Time: t, Format: DateFormatRelative}, true
+}
+
+// Tries the layouts after cleaning up the text and
+// replacing a named time zone with its offset
+func (d *DateParser) parseLayouts(value string) (ParsedDate, bool) {
+	text := publishedRegex.ReplaceAllString(value, "")
+	text = ordinalRegex.ReplaceAllString(text, "$1")
+	text = strings.ReplaceAll(text, " at ", " ")
+	text = strings.Join(strings.Fields(text), " ")
+
+	location := d.Location
+	if match := zoneNameRegex.FindStringSubmatchIndex(text); match != nil {
+		name := text[match[2]:match[3]]
+		if offset, ok := timeZoneOffsets[name]; ok {
+			location = time.FixedZone(name, int(offset*3600))
+			text = strings.TrimSpace(text[:match[0]])
+		} else if loaded, err := time.LoadLocation(name); err == nil && strings.Contains(name, "/") {
+			location = loaded
+			text = strings.TrimSpace(text[:match[0]])
+		}
+	}
+
+	layouts := make([]dateLayout, 0, len(dateLayouts)+len(dayFirstLayouts)+len(monthFirstLayouts))
+	layouts = append(layouts, dateLayouts...)
+	if d.DayFirst {
+		layouts = append(append(layouts, dayFirstLayouts...), monthFirstLayouts...)
+	} else {
+		layouts = append(append(layouts, monthFirstLayouts...), dayFirstLayouts...)
+	}
+	for _, layout := range layouts {
+		t, err := time.ParseInLocation(layout.layout, text, location)
+		if err == nil {
+			return ParsedDate{Time: t, Format: layout.name}, true
+		}
+	}
+	return ParsedDate{}, false
+}
+
+// ParseDates fills DatePublished and DateModified from the raw strings
+// when they are not set, and records the format each was read from.
+func (a *Article) ParseDates(parser *DateParser) {
+	logging := a.Logger
+	logging.Debug("Article.ParseDates() was called")
+	if a.DatePublished.IsZero() && a.DatePublishedRaw != "" {
+		parsed, err := parser.Parse(a.DatePublishedRaw)
+		if err != nil {
+			logging.Info("Article.ParseDates() DatePublishedRaw %s was not understood", a.DatePublishedRaw)
+		} else {
+			a.DatePublished.Time = parsed.Time
+			a.DatePublishedFormat = parsed.Format
+		}
+	}
+	if a.DateModified.IsZero() && a.DateModifiedRaw != "" {
+		parsed, err := parser.Parse(a.DateModifiedRaw)
+		if err != nil {
+			logging.Info("Article.ParseDates() DateModifiedRaw %s was not understood", a.DateModifiedRaw)
+		} else {
+			a.DateModified.Time = parsed.Time
+			a.DateModifiedFormat = parsed.Format
+		}
+	}
+}
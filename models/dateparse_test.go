@@ -0,0 +1,52 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDateParserParse(t *testing.T) {
+	reference := time.Date(2023, 3, 10, 12, 0, 0, 0, time.UTC)
+	est := time.FixedZone("EST", -5*3600)
+	tests := []struct {
+		raw      string
+		dayFirst bool
+		want     time.Time
+		format   string
+	}{
+		{"2023-03-04T10:10:10Z", false, time.Date(2023, 3, 4, 10, 10, 10, 0, time.UTC), "RFC3339"},
+		{"2023-03-04", false, time.Date(2023, 3, 4, 0, 0, 0, 0, time.UTC), "ISO8601"},
+		{"Published: March 4th, 2023", false, time.Date(2023, 3, 4, 0, 0, 0, 0, time.UTC), "January 2, 2006"},
+		{"Mar 4, 2023 3:04 PM EST", false, time.Date(2023, 3, 4, 15, 4, 0, 0, est), "Jan 2, 2006"},
+		{"03/04/2023", false, time.Date(2023, 3, 4, 0, 0, 0, 0, time.UTC), "01/02/2006"},
+		{"03/04/2023", true, time.Date(2023, 4, 3, 0, 0, 0, 0, time.UTC), "02/01/2006"},
+		{"25/12/2023", false, time.Date(2023, 12, 25, 0, 0, 0, 0, time.UTC), "02/01/2006"},
+		{"4.3.2023", false, time.Date(2023, 3, 4, 0, 0, 0, 0, time.UTC), "02.01.2006"},
+		{"20230304101010", false, time.Date(2023, 3, 4, 10, 10, 10, 0, time.UTC), "20060102150405"},
+		{"20230304", false, time.Date(2023, 3, 4, 0, 0, 0, 0, time.UTC), "20060102"},
+		{"1677924610", false, time.Date(2023, 3, 4, 10, 10, 10, 0, time.UTC), DateFormatEpochSeconds},
+		{"1677924610000", false, time.Date(2023, 3, 4, 10, 10, 10, 0, time.UTC), DateFormatEpochMilliseconds},
+		{"2 hours ago", false, reference.Add(-2 * time.Hour), DateFormatRelative},
+		{"Updated yesterday", false, reference.AddDate(0, 0, -1), DateFormatRelative},
+		{"an hour ago", false, reference.Add(-time.Hour), DateFormatRelative},
+	}
+	for _, tt := range tests {
+		parser := NewDateParser(NewDateParserWithReference(reference), NewDateParserWithDayFirst(tt.dayFirst))
+		got, err := parser.Parse(tt.raw)
+		if err != nil {
+			t.Errorf("Parse(%q) error: %v", tt.raw, err)
+			continue
+		}
+		if !got.Time.Equal(tt.want) || got.Format != tt.format {
+			t.Errorf("Parse(%q) = %v (%s), want %v (%s)", tt.raw, got.Time, got.Format, tt.want, tt.format)
+		}
+	}
+}
+
+func TestDateParserParseErrors(t *testing.T) {
+	for _, raw := range []string{"", "   ", "not a date", "20231340101010", "123456789012345"} {
+		if got, err := NewDateParser().Parse(raw); err == nil {
+			t.Errorf("Parse(%q) = %v, want an error", raw, got.Time)
+		}
+	}
+}
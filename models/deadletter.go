@@ -0,0 +1,459 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/chuxorg/chux-datastore/db"
+	dbl "github.com/chuxorg/chux-datastore/logging"
+	"github.com/chuxorg/chux-models/errors"
+	"github.com/chuxorg/chux-models/logging"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The operations a DeadLetter is recorded for
+const (
+	DeadLetterOperationParse = "parse"
+	DeadLetterOperationSave  = "save"
+)
+
+// The models a DeadLetter can hold
+const (
+	DeadLetterModelProduct = "product"
+	DeadLetterModelArticle = "article"
+)
+
+// DeadLetter holds the input of a Parse() or Save() that failed so that
+// it is not lost and can be replayed once the cause has been fixed.
+type DeadLetter struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	ModelType string             `bson:"modelType" json:"modelType"`
+	Operation string             `bson:"operation" json:"operation"`
+	// The JSON passed to Parse(), or the serialized model that failed to save
+	Payload string `bson:"payload" json:"payload"`
+	// The messages of the error and each error it wraps, outermost first
+	Errors      []string   `bson:"errors" json:"errors"`
+	Attempts    int        `bson:"attempts" json:"attempts"`
+	LastAttempt CustomTime `bson:"lastAttempt,omitempty" json:"lastAttempt,omitempty"`
+	// The errors of the last replay that failed
+	LastErrors    []string          `bson:"lastErrors,omitempty" json:"lastErrors,omitempty"`
+	Resolved      bool              `bson:"resolved" json:"resolved"`
+	DateCreated   CustomTime        `bson:"dateCreated,omitempty" json:"dateCreated,omitempty"`
+	DateModified  CustomTime        `bson:"dateModified,omitempty" json:"dateModified,omitempty"`
+	isNew         bool              `bson:"-" json:"-"`
+	isDeleted     bool              `bson:"-" json:"-"`
+	isDirty       bool              `bson:"-" json:"-"`
+	originalState *DeadLetter       `bson:"-" json:"-"`
+	timeConfig    *CustomTimeConfig `bson:"-" json:"-"`
+	Logger        *logging.Logger   `bson:"-" json:"-"`
+}
+
+func NewDeadLetter(options ...func(*DeadLetter)) *DeadLetter {
+
+	d := &DeadLetter{}
+
+	for _, option := range options {
+		option(d)
+	}
+	dbLogger := dbl.NewLogger(dbl.LogLevelDebug)
+	mongoDB = db.New(
+		db.WithURI(d.GetURI()),
+		db.WithDatabaseName(d.GetDatabaseName()),
+		db.WithCollectionName(d.GetCollectionName()),
+		db.WithTimeout(30),
+		db.WithLogger(*dbLogger),
+	)
+
+	d.isNew = true
+	d.isDeleted = false
+	d.isDirty = false
+	return d
+}
+
+func NewDeadLetterWithLogger(logger logging.Logger) func(*DeadLetter) {
+	return func(d *DeadLetter) {
+		d.Logger = &logger
+	}
+}
+
+// Sets how the DeadLetter's CustomTimes are written to JSON and stored in Mongo,
+// without it they use the DefaultCustomTimeConfig
+func NewDeadLetterWithTimeConfig(config CustomTimeConfig) func(*DeadLetter) {
+	return func(d *DeadLetter) {
+		d.timeConfig = &config
+	}
+}
+
+func (d *DeadLetter) GetCollectionName() string {
+	logging := d.Logger
+	logging.Debug("DeadLetter.GetCollectionName() was called")
+	return "deadLetters"
+}
+
+func (d *DeadLetter) GetDatabaseName() string {
+	logging := d.Logger
+	logging.Debug("DeadLetter.GetDatabaseName() was called")
+	return os.Getenv("MONGO_DATABASE")
+}
+
+func (d *DeadLetter) GetURI() string {
+	logging := d.Logger
+	logging.Debug("DeadLetter.GetURI() was called")
+	username := os.Getenv("MONGO_USER_NAME")
+	password := os.Getenv("MONGO_PASSWORD")
+
+	uri := os.Getenv("MONGO_URI")
+	mongoURI := fmt.Sprintf(uri, username, password)
+	masked := fmt.Sprintf(uri, "********", "********")
+	logging.Info("Mongo URI: %s", masked)
+	return mongoURI
+}
+
+func (d *DeadLetter) GetID() primitive.ObjectID {
+	logging := d.Logger
+	logging.Debug("DeadLetter.GetID() was called")
+	return d.ID
+}
+
+func (d *DeadLetter) SetID(id primitive.ObjectID) {
+	logging := d.Logger
+	logging.Debug("DeadLetter.SetID() was called")
+	d.ID = id
+}
+
+// If the Model has changes, will return true
+func (d *DeadLetter) IsDirty() bool {
+	logging := d.Logger
+	logging.Debug("DeadLetter.IsDirty() was called")
+	if d.originalState == nil {
+		return false
+	}
+
+	originalBytes, err := d.originalState.Serialize()
+	if err != nil {
+		return false
+	}
+
+	currentBytes, err := d.Serialize()
+	if err != nil {
+		return false
+	}
+
+	d.isDirty = string(originalBytes) != string(currentBytes)
+	logging.Info("DeadLetter.IsDirty() isDirty: %t", d.isDirty)
+	return d.isDirty
+}
+
+// When the Model is first created,
+// the model is considered New. After the model is
+// Saved or Loaded it is no longer New
+func (d *DeadLetter) IsNew() bool {
+	logging := d.Logger
+	logging.Debug("DeadLetter.IsNew() was called")
+	return d.isNew
+}
+
+// Saves the Model to a Data Store
+func (d *DeadLetter) Save() error {
+	logging := d.Logger
+	logging.Debug("DeadLetter.Save() was called")
+	applyTimeConfig(d.timeConfig, d)
+	if d.isNew {
+		logging.Debug("DeadLetter.Save() DeadLetter is new")
+		// -- Set the date created to now
+		d.DateCreated.Now()
+		//-- Every failure is its own DeadLetter, so give it an ID up front
+		if d.ID.IsZero() {
+			d.ID = primitive.NewObjectID()
+		}
+		err := mongoDB.Upsert(d)
+		if err != nil {
+			logging.Error("DeadLetter.Save() Error creating DeadLetter in MongoDB: %s", err.Error())
+			return errors.NewChuxModelsError("DeadLetter.Save() Error creating DeadLetter in MongoDB", err)
+		}
+	} else if d.IsDirty() && !d.isDeleted {
+		logging.Debug("DeadLetter.Save() DeadLetter is dirty")
+		// Ensure the ID is a valid hex string representation of an ObjectID
+		_, err := primitive.ObjectIDFromHex(d.ID.Hex())
+		if err != nil {
+			logging.Error("DeadLetter.Save() invalid ObjectID: %s", err.Error())
+			return errors.NewChuxModelsError("DeadLetter.Save() invalid ObjectID", err)
+		}
+		// -- Set the date modified to now
+		d.DateModified.Now()
+		//--update this document
+		err = mongoDB.Update(d, d.ID.Hex())
+		if err != nil {
+			logging.Error("DeadLetter.Save() Error updating DeadLetter in MongoDB: %s", err.Error())
+			return errors.NewChuxModelsError("DeadLetter.Save() Error updating DeadLetter in MongoDB", err)
+		}
+	} else if d.isDeleted && !d.isNew {
+		logging.Info("DeadLetter.Save() DeadLetter is deleted")
+		//--delete the document
+		err := mongoDB.Delete(d, d.ID.Hex())
+		if err != nil {
+			logging.Error("DeadLetter.Save() Error deleting DeadLetter in MongoDB: %s", err.Error())
+			return errors.NewChuxModelsError("DeadLetter.Save() Error deleting DeadLetter in MongoDB", err)
+		}
+	}
+
+	// If the DeadLetter has been deleted, then this is a new DeadLetter
+	d.isNew = d.isDeleted
+	d.isDirty = d.IsDirty()
+	d.isDeleted = false
+
+	if d.isNew {
+		d.originalState = nil
+	} else {
+		//--reset state
+		serialized, err := d.Serialize()
+		if err != nil {
+			logging.Error("DeadLetter.Save() Error serializing DeadLetter: %s", err.Error())
+			return errors.NewChuxModelsError("DeadLetter.Save() Error serializing DeadLetter.", err)
+		}
+		d.SetState(serialized)
+	}
+
+	logging.Info("DeadLetter.Save() DeadLetter saved successfully")
+	return nil
+}
+
+// Loads a Model from MongoDB by id
+func (d *DeadLetter) Load(id string) (interface{}, error) {
+	logging := d.Logger
+	logging.Debug("DeadLetter.Load() was called")
+
+	retVal, err := mongoDB.GetByID(d, id)
+	if err != nil {
+		logging.Error("DeadLetter.Load() Error loading DeadLetter from MongoDB: %s", err.Error())
+		return nil, errors.NewChuxModelsError("DeadLetter.Load() Error loading DeadLetter from MongoDB", err)
+	}
+	letter, ok := retVal.(*DeadLetter)
+	if !ok {
+		logging.Error("DeadLetter.Load() unable to cast retVal to *DeadLetter")
+		return nil, errors.NewChuxModelsError("DeadLetter.Load() unable to cast retVal to *DeadLetter", nil)
+	}
+	err = letter.markLoaded()
+	if err != nil {
+		logging.Error("DeadLetter.Load() Error setting state: %s", err.Error())
+		return nil, errors.NewChuxModelsError("DeadLetter.Load() Error setting state", err)
+	}
+	logging.Info("DeadLetter.Load() DeadLetter loaded successfully")
+	return retVal, nil
+}
+
+func (d *DeadLetter) Query(args ...interface{}) ([]db.IMongoDocument, error) {
+	logging := d.Logger
+	logging.Debug("DeadLetter.Query() was called")
+
+	results, err := mongoDB.Query(d, args...)
+	if err != nil {
+		logging.Error("DeadLetter.Query() Error occurred querying DeadLetters: %s", err.Error())
+		return nil, errors.NewChuxModelsError("DeadLetter.Query() Error occurred querying DeadLetters", err)
+	}
+	logging.Info("DeadLetter.Query() DeadLetters queried successfully")
+	return results, nil
+}
+
+// Marks a Model for deletion from the Data Store
+// when Save() is called, the Model will be deleted
+func (d *DeadLetter) Delete() error {
+	logging := d.Logger
+	logging.Debug("DeadLetter.Delete() was called")
+	d.isDeleted = true
+	return nil
+}
+
+// Sets the internal state of the model.
+func (d *DeadLetter) SetState(json string) error {
+	logging := d.Logger
+	logging.Debug("DeadLetter.SetState() was called")
+	// Store the current state as the original state
+	original := &DeadLetter{}
+	*original = *d
+	d.originalState = original
+
+	// Deserialize the new state
+	return d.Deserialize([]byte(json))
+}
+
+// Marks a DeadLetter returned by Query() as loaded so that
+// changes made to it are persisted by Save()
+func (d *DeadLetter) markLoaded() error {
+	serialized, err := d.Serialize()
+	if err != nil {
+		return errors.NewChuxModelsError("DeadLetter.markLoaded() Error serializing DeadLetter", err)
+	}
+	d.SetState(serialized)
+	d.isNew = false
+	d.isDirty = false
+	d.isDeleted = false
+	return nil
+}
+
+// Sets the internal state of the model of a new DeadLetter
+// from a JSON String.
+func (d *DeadLetter) Parse(json string) error {
+	logging := d.Logger
+	logging.Debug("DeadLetter.Parse() was called")
+	err := d.SetState(json)
+	if err != nil {
+		logging.Error("DeadLetter.Parse() error setting state")
+		return errors.NewChuxModelsError("DeadLetter.Parse() Error setting state", err)
+	}
+	d.isNew = true // this is a new model
+	return nil
+}
+
+func (d *DeadLetter) Search(args ...interface{}) ([]interface{}, error) {
+	logging := d.Logger
+	logging.Debug("DeadLetter.Search() was called")
+	return nil, nil
+}
+
+func (d *DeadLetter) Serialize() (string, error) {
+	logging := d.Logger
+	logging.Debug("DeadLetter.Serialize() was called")
+	applyTimeConfig(d.timeConfig, d)
+	bytes, err := json.Marshal(d)
+	if err != nil {
+		logging.Error("DeadLetter.Serialize() error occurred: %s", err.Error())
+		return "", errors.NewChuxModelsError("DeadLetter.Serialize() error occurred", err)
+	}
+	return string(bytes), nil
+}
+
+func (d *DeadLetter) Deserialize(jsonData []byte) error {
+	logging := d.Logger
+	logging.Debug("DeadLetter.Deserialize() was called")
+	applyTimeConfig(d.timeConfig, d)
+	err := json.Unmarshal(jsonData, d)
+	if err != nil {
+		logging.Error("DeadLetter.Deserialize() error occurred: %s", err.Error())
+		return errors.NewChuxModelsError("DeadLetter.Deserialize() error occurred", err)
+	}
+	return nil
+}
+
+// ReplayResult counts the DeadLetters a replay went through
+type ReplayResult struct {
+	Attempted int `json:"attempted"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	// DeadLetters that reached the maximum number of attempts
+	Skipped int `json:"skipped"`
+}
+
+// Records a failed Parse() or Save(). Failing to record the DeadLetter is
+// only logged so that the error of the operation is the one returned.
+func recordDeadLetter(logging *logging.Logger, modelType, operation, payload string, err error) {
+	letter := &DeadLetter{
+		ModelType: modelType,
+		Operation: operation,
+		Payload:   payload,
+		Errors:    errorChain(err),
+		Logger:    logging,
+		isNew:     true,
+	}
+	if saveErr := letter.Save(); saveErr != nil {
+		logging.Error("recordDeadLetter() Unable to record a %s %s failure: %s", modelType, operation, saveErr.Error())
+	}
+}
+
+// Returns the messages of err and of every error it wraps, outermost first
+func errorChain(err error) []string {
+	var chain []string
+	for err != nil {
+		chain = append(chain, err.Error())
+		wrapper, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			break
+		}
+		err = wrapper.Unwrap()
+	}
+	return chain
+}
+
+// Returns true when err is, or wraps, ErrQuarantined
+func isQuarantined(err error) bool {
+	for err != nil {
+		if err == error(ErrQuarantined) {
+			return true
+		}
+		wrapper, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			return false
+		}
+		err = wrapper.Unwrap()
+	}
+	return false
+}
+
+// Replay parses and saves the Payload again, counting the attempt.
+// The DeadLetter is marked Resolved when it succeeds.
+func (d *DeadLetter) Replay() error {
+	logging := d.Logger
+	logging.Debug("DeadLetter.Replay() was called")
+	d.Attempts++
+	d.LastAttempt.Now()
+
+	var err error
+	switch d.ModelType {
+	case DeadLetterModelProduct:
+		product := &Product{Logger: d.Logger}
+		if err = product.Parse(d.Payload); err == nil {
+			err = product.Save()
+		}
+	case DeadLetterModelArticle:
+		article := &Article{Logger: d.Logger}
+		if err = article.Parse(d.Payload); err == nil {
+			err = article.Save()
+		}
+	default:
+		err = errors.NewChuxModelsError(fmt.Sprintf("DeadLetter.Replay() unknown model type %s", d.ModelType), nil)
+	}
+
+	if err != nil {
+		logging.Info("DeadLetter.Replay() Attempt %d of %s %s failed: %s", d.Attempts, d.ModelType, d.ID.Hex(), err.Error())
+		d.LastErrors = errorChain(err)
+	} else {
+		d.Resolved = true
+		d.LastErrors = nil
+	}
+	if saveErr := d.Save(); saveErr != nil {
+		return saveErr
+	}
+	return err
+}
+
+// ReplayDeadLetters replays every unresolved DeadLetter. DeadLetters that
+// have been attempted maxAttempts times are skipped, 0 means no limit.
+func ReplayDeadLetters(logging logging.Logger, maxAttempts int) (*ReplayResult, error) {
+	letter := NewDeadLetter(NewDeadLetterWithLogger(logging))
+	docs, err := letter.Query("resolved", false)
+	if err != nil {
+		return nil, err
+	}
+	result := &ReplayResult{}
+	for _, doc := range docs {
+		dead := doc.(*DeadLetter)
+		dead.Logger = &logging
+		if err := dead.markLoaded(); err != nil {
+			return nil, err
+		}
+		if maxAttempts > 0 && dead.Attempts >= maxAttempts {
+			result.Skipped++
+			continue
+		}
+		result.Attempted++
+		if err := dead.Replay(); err != nil {
+			result.Failed++
+			continue
+		}
+		result.Succeeded++
+	}
+	logging.Info("ReplayDeadLetters() Replayed %d dead letters, %d succeeded, %d failed, %d skipped",
+		result.Attempted, result.Succeeded, result.Failed, result.Skipped)
+	return result, nil
+}
@@ -0,0 +1,191 @@
+package models
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/chuxorg/chux-models/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Articles need at least this Similarity to be near-duplicates
+// when no threshold is given
+const DefaultDuplicateThreshold = 0.8
+
+// ArticleMatch is a stored Article that is a near-duplicate of another
+type ArticleMatch struct {
+	Article    *Article
+	Similarity float64
+}
+
+// DuplicateGroup is an original Article and its near-duplicates
+type DuplicateGroup struct {
+	Original   *Article
+	Duplicates []*Article
+}
+
+// ComputeFingerprint sets the Article's Fingerprint from its ArticleBody
+func (a *Article) ComputeFingerprint() {
+	logging := a.Logger
+	logging.Debug("Article.ComputeFingerprint() was called")
+	a.Fingerprint = NewContentFingerprint(a.ArticleBody)
+}
+
+// FindNearDuplicates returns the stored Articles whose ArticleBody is
+// at least threshold alike to the Article's, most alike first. Stored
+// copies of the Article itself, with the same CanonicalURL, are left
+// out and so are the Articles grouped under them.
+func (a *Article) FindNearDuplicates(threshold float64) ([]ArticleMatch, error) {
+	logging := a.Logger
+	logging.Debug("Article.FindNearDuplicates() was called")
+	if a.Fingerprint == nil {
+		a.ComputeFingerprint()
+	}
+	if a.Fingerprint == nil {
+		return nil, nil
+	}
+
+	// -- The ID the Article is stored with, which its own duplicates have as their DuplicateOf
+	self := a.ID
+	if self.IsZero() {
+		stored, err := a.storedID()
+		if err != nil {
+			return nil, err
+		}
+		self = stored
+	}
+	query := &Article{Logger: a.Logger}
+	docs, err := query.Query("fingerprint.bands", bson.M{"$in": a.Fingerprint.Bands})
+	if err != nil {
+		return nil, err
+	}
+	var matches []ArticleMatch
+	for _, doc := range docs {
+		found := doc.(*Article)
+		if found.CanonicalURL == a.CanonicalURL || (!self.IsZero() && (found.ID == self || found.DuplicateOf == self)) {
+			continue
+		}
+		similarity := a.Fingerprint.Similarity(found.Fingerprint)
+		if similarity < threshold {
+			continue
+		}
+		found.Logger = a.Logger
+		if err := found.markLoaded(); err != nil {
+			return nil, err
+		}
+		matches = append(matches, ArticleMatch{Article: found, Similarity: similarity})
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Similarity > matches[j].Similarity
+	})
+	logging.Info("Article.FindNearDuplicates() Found %d near-duplicates of %s", len(matches), a.CanonicalURL)
+	return matches, nil
+}
+
+// The ID of the stored Article with the same CanonicalURL, which the
+// Upsert of a new Article replaces, or NilObjectID when there is none
+func (a *Article) storedID() (primitive.ObjectID, error) {
+	query := &Article{Logger: a.Logger}
+	docs, err := query.Query("canonicalUrl", a.CanonicalURL)
+	if err != nil || len(docs) == 0 {
+		return primitive.NilObjectID, err
+	}
+	return docs[0].(*Article).ID, nil
+}
+
+// Groups the Article under the original of the stored
+// near-duplicate it is most alike to, if there is one
+func (a *Article) markDuplicate(threshold float64) error {
+	logging := a.Logger
+	matches, err := a.FindNearDuplicates(threshold)
+	if err != nil {
+		logging.Error("Article.markDuplicate() error finding near-duplicates: %s", err.Error())
+		return errors.NewChuxModelsError("Article.markDuplicate() error finding near-duplicates", err)
+	}
+	if len(matches) == 0 {
+		a.DuplicateOf = primitive.NilObjectID
+		return nil
+	}
+	best := matches[0].Article
+	a.DuplicateOf = best.DuplicateOf
+	if a.DuplicateOf.IsZero() {
+		a.DuplicateOf = best.ID
+	}
+	logging.Info("Article.markDuplicate() %s is a duplicate of %s", a.CanonicalURL, a.DuplicateOf.Hex())
+	return nil
+}
+
+// Duplicates returns the stored Articles grouped under the Article
+func (a *Article) Duplicates() ([]*Article, error) {
+	logging := a.Logger
+	logging.Debug("Article.Duplicates() was called")
+	query := &Article{Logger: a.Logger}
+	docs, err := query.Query("duplicateOf", a.ID)
+	if err != nil {
+		return nil, err
+	}
+	duplicates := make([]*Article, 0, len(docs))
+	for _, doc := range docs {
+		found := doc.(*Article)
+		found.Logger = a.Logger
+		if err := found.markLoaded(); err != nil {
+			return nil, err
+		}
+		duplicates = append(duplicates, found)
+	}
+	return duplicates, nil
+}
+
+// GroupNearDuplicates groups Articles whose ArticleBody is at least
+// threshold alike. The Article published first is the original of
+// its group, the others get its ID as their DuplicateOf and have to
+// be saved. Only groups with duplicates are returned.
+func GroupNearDuplicates(articles []*Article, threshold float64) []DuplicateGroup {
+	ordered := make([]*Article, len(articles))
+	copy(ordered, articles)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		ti, tj := ordered[i].publishedAt(), ordered[j].publishedAt()
+		if ti.IsZero() || tj.IsZero() {
+			return !ti.IsZero()
+		}
+		return ti.Before(tj.Time)
+	})
+
+	index := NewDuplicateIndex(threshold)
+	var groups []*DuplicateGroup
+	groupOf := make(map[string]int)
+	for i, article := range ordered {
+		if article.Fingerprint == nil {
+			article.ComputeFingerprint()
+		}
+		key := strconv.Itoa(i)
+		if matches := index.Find(article.Fingerprint); len(matches) > 0 {
+			group := groups[groupOf[matches[0].Key]]
+			article.DuplicateOf = group.Original.ID
+			group.Duplicates = append(group.Duplicates, article)
+			groupOf[key] = groupOf[matches[0].Key]
+		} else {
+			article.DuplicateOf = primitive.NilObjectID
+			groupOf[key] = len(groups)
+			groups = append(groups, &DuplicateGroup{Original: article})
+		}
+		index.Add(key, article.Fingerprint)
+	}
+
+	var grouped []DuplicateGroup
+	for _, group := range groups {
+		if len(group.Duplicates) > 0 {
+			grouped = append(grouped, *group)
+		}
+	}
+	return grouped
+}
+
+// When the Article was published, or created if that is not known
+func (a *Article) publishedAt() CustomTime {
+	if !a.DatePublished.IsZero() {
+		return a.DatePublished
+	}
+	return a.DateCreated
+}
@@ -0,0 +1,421 @@
+package models
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/chuxorg/chux-models/errors"
+	"github.com/chuxorg/chux-models/logging"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// EntityType is the kind of thing an Article mentions
+type EntityType string
+
+const (
+	EntityBrand   EntityType = "brand"
+	EntityProduct EntityType = "product"
+	EntityCompany EntityType = "company"
+)
+
+// How sure a single occurrence of each kind of name makes a mention.
+// MPNs and full product names are specific, a brand or company name
+// can also be an ordinary word.
+const (
+	mpnMentionWeight         = 0.9
+	productNameMentionWeight = 0.85
+	brandMentionWeight       = 0.7
+	companyMentionWeight     = 0.6
+	// Added to the weight of names in the Headline
+	headlineMentionBoost = 0.1
+	// Evidence a Product is mentioned when its Brand is mentioned too
+	brandContextWeight = 0.5
+)
+
+// Names with their words run together are matched against
+// runs of up to this many words of a text
+const maxCompactWords = 4
+
+// Words of a text, as they are written
+var entityTokenRegex = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// EntityMention is a Brand, Product or company an Article mentions.
+// Score is how likely the Article is about it, from 0 to 1, it grows
+// with the number of times it is named and when it is in the Headline.
+type EntityMention struct {
+	Type EntityType `bson:"type" json:"type"`
+	// Not set for companies, which are only known by their names
+	EntityID   primitive.ObjectID `bson:"entityId,omitempty" json:"entityId,omitempty"`
+	Name       string             `bson:"name" json:"name"`
+	Matched    []string           `bson:"matched" json:"matched"`
+	Count      int                `bson:"count" json:"count"`
+	InHeadline bool               `bson:"inHeadline" json:"inHeadline"`
+	Score      float64            `bson:"score" json:"score"`
+}
+
+// A Brand, Product or company one of the names in an EntityLinker refers to
+type entityCandidate struct {
+	Type     EntityType
+	ID       primitive.ObjectID
+	Name     string
+	BrandKey string
+	Weight   float64
+}
+
+// EntityLinker finds the Brands, Products and companies a text names.
+// Names are matched on their normalized words, the longest name at
+// each place in the text wins. MPNs and company names are also matched
+// with their words run together, so "WH1000XM5" matches "WH-1000XM5"
+// and "Best Buy" matches "bestbuy".
+type EntityLinker struct {
+	// Mentions need at least this Score to be kept
+	MinScore float64
+	Logger   *logging.Logger
+	names    map[string][]*entityCandidate
+	compact  map[string][]*entityCandidate
+	longest  int
+}
+
+// Creates a NewEntityLinker with Options, without any names.
+// By default mentions need a Score of 0.5.
+func NewEntityLinker(options ...func(*EntityLinker)) *EntityLinker {
+	l := &EntityLinker{
+		MinScore: 0.5,
+		names:    make(map[string][]*entityCandidate),
+		compact:  make(map[string][]*entityCandidate),
+	}
+	for _, option := range options {
+		option(l)
+	}
+	return l
+}
+
+func NewEntityLinkerWithLogger(logger logging.Logger) func(*EntityLinker) {
+	return func(l *EntityLinker) {
+		l.Logger = &logger
+	}
+}
+
+// Sets the Score mentions need to be kept
+func NewEntityLinkerWithMinScore(score float64) func(*EntityLinker) {
+	return func(l *EntityLinker) {
+		l.MinScore = score
+	}
+}
+
+// LoadEntityLinker builds an EntityLinker from the stored Brands and
+// Products and the names of the companies the Products were scraped from
+func LoadEntityLinker(logging logging.Logger, options ...func(*EntityLinker)) (*EntityLinker, error) {
+	linker := NewEntityLinker(options...)
+	if linker.Logger == nil {
+		linker.Logger = &logging
+	}
+
+	brd := NewBrand()
+	brd.Logger = &logging
+	docs, err := brd.Query()
+	if err != nil {
+		logging.Error("LoadEntityLinker() Error querying brands: %s", err.Error())
+		return nil, errors.NewChuxModelsError("LoadEntityLinker() Error querying brands", err)
+	}
+	for _, doc := range docs {
+		linker.AddBrand(doc.(*Brand))
+	}
+
+	prd := NewProduct()
+	prd.Logger = &logging
+	docs, err = prd.Query()
+	if err != nil {
+		logging.Error("LoadEntityLinker() Error querying products: %s", err.Error())
+		return nil, errors.NewChuxModelsError("LoadEntityLinker() Error querying products", err)
+	}
+	companies := make(map[string]bool)
+	for _, doc := range docs {
+		product := doc.(*Product)
+		linker.AddProduct(product)
+		if product.CompanyName != "" && !companies[product.CompanyName] {
+			companies[product.CompanyName] = true
+			linker.AddCompany(product.CompanyName)
+		}
+	}
+	logging.Info("LoadEntityLinker() Loaded %d names", len(linker.names)+len(linker.compact))
+	return linker, nil
+}
+
+// AddBrand adds the canonical name and aliases of a Brand
+func (l *EntityLinker) AddBrand(brand *Brand) {
+	candidate := &entityCandidate{
+		Type:     EntityBrand,
+		ID:       brand.ID,
+		Name:     brand.CanonicalName,
+		BrandKey: brandKey(brand.CanonicalName),
+		Weight:   brandMentionWeight,
+	}
+	for _, name := range append([]string{brand.CanonicalName}, brand.Aliases...) {
+		l.addName(l.names, withoutCorporateSuffix(Tokenize(name)), " ", candidate)
+	}
+}
+
+// AddProduct adds the name of a Product, with and without its Brand
+// in front, and its MPN
+func (l *EntityLinker) AddProduct(product *Product) {
+	name := &entityCandidate{
+		Type:     EntityProduct,
+		ID:       product.ID,
+		Name:     product.Name,
+		BrandKey: brandKey(product.Brand),
+		Weight:   productNameMentionWeight,
+	}
+	tokens := Tokenize(product.Name)
+	l.addName(l.names, tokens, " ", name)
+	if brand := Tokenize(product.Brand); len(brand) > 0 && len(tokens) > len(brand)+1 &&
+		strings.Join(tokens[:len(brand)], " ") == strings.Join(brand, " ") {
+		l.addName(l.names, tokens[len(brand):], " ", name)
+	}
+
+	// -- MPNs without a letter and a digit, such as "100", are too likely to be something else
+	mpn := strings.Join(Tokenize(product.MPN), "")
+	if len(mpn) >= 4 && strings.IndexFunc(mpn, unicode.IsLetter) >= 0 && strings.IndexFunc(mpn, unicode.IsDigit) >= 0 {
+		candidate := *name
+		candidate.Weight = mpnMentionWeight
+		l.addName(l.compact, []string{mpn}, "", &candidate)
+	}
+}
+
+// AddCompany adds the name of a company, such as the CompanyName of
+// the Articles and Products scraped from its site
+func (l *EntityLinker) AddCompany(name string) {
+	tokens := withoutCorporateSuffix(Tokenize(name))
+	if len(strings.Join(tokens, "")) < 3 {
+		return
+	}
+	candidate := &entityCandidate{
+		Type:   EntityCompany,
+		Name:   name,
+		Weight: companyMentionWeight,
+	}
+	if len(tokens) == 1 {
+		l.addName(l.compact, tokens, "", candidate)
+	} else {
+		l.addName(l.names, tokens, " ", candidate)
+	}
+}
+
+// Indexes a name under its tokens joined with sep, once per entity
+func (l *EntityLinker) addName(index map[string][]*entityCandidate, tokens []string, sep string, candidate *entityCandidate) {
+	key := strings.Join(tokens, sep)
+	if key == "" {
+		return
+	}
+	for _, existing := range index[key] {
+		if existing.Type == candidate.Type && existing.ID == candidate.ID && existing.Name == candidate.Name {
+			return
+		}
+	}
+	index[key] = append(index[key], candidate)
+	longest := len(tokens)
+	if sep == "" {
+		longest = maxCompactWords
+	}
+	l.longest = maxInt(l.longest, longest)
+}
+
+// Drops corporate suffixes such as "Inc" from the end of a name
+func withoutCorporateSuffix(tokens []string) []string {
+	for len(tokens) > 1 && brandSuffixes[tokens[len(tokens)-1]] {
+		tokens = tokens[:len(tokens)-1]
+	}
+	return tokens
+}
+
+// A word of a text, its normalized form and where it is in the text
+type entityToken struct {
+	text       string
+	normalized string
+	start, end int
+}
+
+// An occurrence of a name in a text
+type entityOccurrence struct {
+	candidate *entityCandidate
+	text      string
+	headline  bool
+}
+
+// Link returns the Brands, Products and companies a text with a
+// headline mentions, highest Score first
+func (l *EntityLinker) Link(headline, body string) []EntityMention {
+	logging := l.Logger
+	logging.Debug("EntityLinker.Link() was called")
+	occurrences := append(l.find(headline, true), l.find(body, false)...)
+
+	type tally struct {
+		mention  EntityMention
+		evidence []float64
+		brandKey string
+	}
+	tallies := make(map[string]*tally)
+	var order []string
+	brands := make(map[string]bool)
+	for _, occurrence := range occurrences {
+		c := occurrence.candidate
+		key := string(c.Type) + ":" + c.ID.Hex() + ":" + c.Name
+		t, ok := tallies[key]
+		if !ok {
+			t = &tally{
+				mention:  EntityMention{Type: c.Type, EntityID: c.ID, Name: c.Name},
+				brandKey: c.BrandKey,
+			}
+			tallies[key] = t
+			order = append(order, key)
+		}
+		t.mention.Count++
+		if !containsString(t.mention.Matched, occurrence.text) {
+			t.mention.Matched = append(t.mention.Matched, occurrence.text)
+		}
+		weight := c.Weight
+		if occurrence.headline {
+			t.mention.InHeadline = true
+			weight = math.Min(1, weight+headlineMentionBoost)
+		}
+		t.evidence = append(t.evidence, weight)
+		if c.Type == EntityBrand {
+			brands[c.BrandKey] = true
+		}
+	}
+
+	var mentions []EntityMention
+	for _, key := range order {
+		t := tallies[key]
+		if t.mention.Type == EntityProduct && t.brandKey != "" && brands[t.brandKey] {
+			t.evidence = append(t.evidence, brandContextWeight)
+		}
+		// -- Each occurrence is independent evidence of the mention
+		doubt := 1.0
+		for _, weight := range t.evidence {
+			doubt *= 1 - weight
+		}
+		t.mention.Score = math.Round((1-doubt)*1000) / 1000
+		if t.mention.Score >= l.MinScore {
+			mentions = append(mentions, t.mention)
+		}
+	}
+	sort.SliceStable(mentions, func(i, j int) bool {
+		return mentions[i].Score > mentions[j].Score
+	})
+	return mentions
+}
+
+// Finds the names in a text, trying the longest name first at each word
+func (l *EntityLinker) find(text string, headline bool) []entityOccurrence {
+	var tokens []entityToken
+	for _, span := range entityTokenRegex.FindAllStringIndex(text, -1) {
+		word := text[span[0]:span[1]]
+		if normalized := NormalizeText(word); normalized != "" {
+			tokens = append(tokens, entityToken{text: word, normalized: normalized, start: span[0], end: span[1]})
+		}
+	}
+
+	var occurrences []entityOccurrence
+	for i := 0; i < len(tokens); {
+		matched := 0
+		for size := minInt(l.longest, len(tokens)-i); size > 0 && matched == 0; size-- {
+			words := make([]string, size)
+			for j := range words {
+				words[j] = tokens[i+j].normalized
+			}
+			candidates := l.names[strings.Join(words, " ")]
+			if size <= maxCompactWords {
+				candidates = append(append([]*entityCandidate{}, candidates...), l.compact[strings.Join(words, "")]...)
+			}
+			for _, candidate := range candidates {
+				// -- A single word name written in lower case is likely an ordinary word
+				if size == 1 && strings.ToLower(tokens[i].text) == tokens[i].text {
+					continue
+				}
+				occurrences = append(occurrences, entityOccurrence{
+					candidate: candidate,
+					text:      text[tokens[i].start:tokens[i+size-1].end],
+					headline:  headline,
+				})
+				matched = size
+			}
+		}
+		i += maxInt(matched, 1)
+	}
+	return occurrences
+}
+
+// LinkEntities sets the Article's Mentions to the Brands, Products and
+// companies its Headline and ArticleBody mention
+func (a *Article) LinkEntities(linker *EntityLinker) {
+	logging := a.Logger
+	logging.Debug("Article.LinkEntities() was called")
+	a.Mentions = linker.Link(a.Headline, a.ArticleBody)
+}
+
+// Queries the stored Articles with a mention that matches filter
+// and has at least minScore
+func articlesMentioning(logging *logging.Logger, filter bson.M, minScore float64) ([]*Article, error) {
+	filter["score"] = bson.M{"$gte": minScore}
+	query := &Article{Logger: logging}
+	docs, err := query.Query("mentions", bson.M{"$elemMatch": filter})
+	if err != nil {
+		return nil, err
+	}
+	articles := make([]*Article, 0, len(docs))
+	for _, doc := range docs {
+		found := doc.(*Article)
+		found.Logger = logging
+		if err := found.markLoaded(); err != nil {
+			return nil, err
+		}
+		articles = append(articles, found)
+	}
+	return articles, nil
+}
+
+// MentionedIn returns the stored Articles that mention the Product
+// with at least minScore
+func (p *Product) MentionedIn(minScore float64) ([]*Article, error) {
+	logging := p.Logger
+	logging.Debug("Product.MentionedIn() was called")
+	return articlesMentioning(p.Logger, bson.M{"type": EntityProduct, "entityId": p.ID}, minScore)
+}
+
+// MentionedIn returns the stored Articles that mention the Brand
+// with at least minScore
+func (b *Brand) MentionedIn(minScore float64) ([]*Article, error) {
+	logging := b.Logger
+	logging.Debug("Brand.MentionedIn() was called")
+	return articlesMentioning(b.Logger, bson.M{"type": EntityBrand, "entityId": b.ID}, minScore)
+}
+
+// ArticlesMentioningProduct returns the stored Articles that mention
+// the Product with an id with at least minScore
+func ArticlesMentioningProduct(logging logging.Logger, productID primitive.ObjectID, minScore float64) ([]*Article, error) {
+	NewArticle(NewArticleWithLogger(logging))
+	articles, err := articlesMentioning(&logging, bson.M{"type": EntityProduct, "entityId": productID}, minScore)
+	if err != nil {
+		return nil, err
+	}
+	logging.Info("ArticlesMentioningProduct() Found %d Articles mentioning %s", len(articles), productID.Hex())
+	return articles, nil
+}
+
+// ArticlesMentioningCompany returns the stored Articles that mention
+// a company, by the name it was added to the EntityLinker with, with
+// at least minScore
+func ArticlesMentioningCompany(logging logging.Logger, companyName string, minScore float64) ([]*Article, error) {
+	NewArticle(NewArticleWithLogger(logging))
+	articles, err := articlesMentioning(&logging, bson.M{"type": EntityCompany, "name": companyName}, minScore)
+	if err != nil {
+		return nil, err
+	}
+	logging.Info("ArticlesMentioningCompany() Found %d Articles mentioning %s", len(articles), companyName)
+	return articles, nil
+}
@@ -0,0 +1,248 @@
+package models
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+	"sort"
+	"strings"
+)
+
+// Number of words in a shingle
+const ShingleSize = 3
+
+// Number of MinHash values in a ContentFingerprint
+const minHashPermutations = 64
+
+// Number of locality sensitive hashing bands the MinHash values are
+// split into, two texts become candidates when a band matches. With 16
+// bands of 4 values texts that are 80% alike are found 99.9% of the time.
+const minHashBands = 16
+
+// Seeds of the MinHash functions, fixed so fingerprints stored
+// at different times can be compared
+var minHashSeeds = func() []uint64 {
+	seeds := make([]uint64, minHashPermutations)
+	state := uint64(0x9e3779b97f4a7c15)
+	for i := range seeds {
+		state += 0x9e3779b97f4a7c15
+		seeds[i] = mix64(state)
+	}
+	return seeds
+}()
+
+// ContentFingerprint identifies a text by its content so that copies
+// of it that were lightly edited can be found
+type ContentFingerprint struct {
+	// 64 bit SimHash of the shingles, stored as int64 as BSON has no unsigned integers
+	SimHash int64 `bson:"simHash" json:"simHash"`
+	// The smallest hash of the shingles under each MinHash function
+	MinHash []uint32 `bson:"minHash" json:"minHash"`
+	// Keys of the locality sensitive hashing bands, used to find candidates in the Data Store
+	Bands []string `bson:"bands" json:"bands"`
+	// Number of shingles the fingerprint was computed from
+	Shingles int `bson:"shingles" json:"shingles"`
+}
+
+// Shingles returns the overlapping runs of size words of a text, after
+// normalizing it. Texts shorter than size are a single shingle.
+func Shingles(text string, size int) []string {
+	tokens := Tokenize(text)
+	if len(tokens) == 0 {
+		return nil
+	}
+	if size < 1 {
+		size = 1
+	}
+	if len(tokens) <= size {
+		return []string{strings.Join(tokens, " ")}
+	}
+	shingles := make([]string, 0, len(tokens)-size+1)
+	for i := 0; i+size <= len(tokens); i++ {
+		shingles = append(shingles, strings.Join(tokens[i:i+size], " "))
+	}
+	return shingles
+}
+
+// NewContentFingerprint computes the SimHash and MinHash fingerprint
+// of a text. A text without words has no fingerprint.
+func NewContentFingerprint(text string) *ContentFingerprint {
+	shingles := Shingles(text, ShingleSize)
+	if len(shingles) == 0 {
+		return nil
+	}
+	hashes := make([]uint64, len(shingles))
+	for i, shingle := range shingles {
+		h := fnv.New64a()
+		h.Write([]byte(shingle))
+		hashes[i] = h.Sum64()
+	}
+
+	fingerprint := &ContentFingerprint{
+		SimHash:  int64(simHash(hashes)),
+		MinHash:  make([]uint32, minHashPermutations),
+		Shingles: len(shingles),
+	}
+	for i, seed := range minHashSeeds {
+		lowest := uint32(0xffffffff)
+		for _, hash := range hashes {
+			if value := uint32(mix64(hash ^ seed)); value < lowest {
+				lowest = value
+			}
+		}
+		fingerprint.MinHash[i] = lowest
+	}
+	rows := minHashPermutations / minHashBands
+	for band := 0; band < minHashBands; band++ {
+		h := fnv.New32a()
+		for _, value := range fingerprint.MinHash[band*rows : (band+1)*rows] {
+			h.Write([]byte{byte(value), byte(value >> 8), byte(value >> 16), byte(value >> 24)})
+		}
+		fingerprint.Bands = append(fingerprint.Bands, fmt.Sprintf("%d:%08x", band, h.Sum32()))
+	}
+	return fingerprint
+}
+
+// Each bit of a SimHash is set when more of the hashes have it set than not
+func simHash(hashes []uint64) uint64 {
+	var weights [64]int
+	for _, hash := range hashes {
+		for bit := 0; bit < 64; bit++ {
+			if hash&(1<<uint(bit)) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+	var fingerprint uint64
+	for bit, weight := range weights {
+		if weight > 0 {
+			fingerprint |= 1 << uint(bit)
+		}
+	}
+	return fingerprint
+}
+
+// The splitmix64 finalizer, spreads the bits of x over the result
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// Similarity estimates the Jaccard similarity of the shingles of two
+// fingerprinted texts from the share of MinHash values they agree on
+func (f *ContentFingerprint) Similarity(other *ContentFingerprint) float64 {
+	if f == nil || other == nil || len(f.MinHash) == 0 || len(f.MinHash) != len(other.MinHash) {
+		return 0
+	}
+	same := 0
+	for i, value := range f.MinHash {
+		if other.MinHash[i] == value {
+			same++
+		}
+	}
+	return float64(same) / float64(len(f.MinHash))
+}
+
+// SimHashDistance is the number of bits the SimHashes of two
+// fingerprints differ in, unrelated texts differ in about 32
+func (f *ContentFingerprint) SimHashDistance(other *ContentFingerprint) int {
+	if f == nil || other == nil {
+		return 64
+	}
+	return bits.OnesCount64(uint64(f.SimHash ^ other.SimHash))
+}
+
+// DuplicateMatch is a fingerprinted text that is like another one
+type DuplicateMatch struct {
+	Key        string  `json:"key"`
+	Similarity float64 `json:"similarity"`
+}
+
+// DuplicateIndex finds near-duplicate texts in memory with locality
+// sensitive hashing over the bands of their fingerprints
+type DuplicateIndex struct {
+	// Texts need at least this Similarity to be near-duplicates
+	Threshold    float64
+	buckets      map[string][]string
+	fingerprints map[string]*ContentFingerprint
+}
+
+// Creates a NewDuplicateIndex of texts that are near-duplicates
+// when their Similarity is at least threshold
+func NewDuplicateIndex(threshold float64) *DuplicateIndex {
+	return &DuplicateIndex{
+		Threshold:    threshold,
+		buckets:      make(map[string][]string),
+		fingerprints: make(map[string]*ContentFingerprint),
+	}
+}
+
+// Add indexes a fingerprint under key, replacing one added before
+func (x *DuplicateIndex) Add(key string, fingerprint *ContentFingerprint) {
+	if fingerprint == nil {
+		return
+	}
+	if _, ok := x.fingerprints[key]; ok {
+		x.Remove(key)
+	}
+	x.fingerprints[key] = fingerprint
+	for _, band := range fingerprint.Bands {
+		x.buckets[band] = append(x.buckets[band], key)
+	}
+}
+
+// Remove takes a key out of the index
+func (x *DuplicateIndex) Remove(key string) {
+	fingerprint, ok := x.fingerprints[key]
+	if !ok {
+		return
+	}
+	delete(x.fingerprints, key)
+	for _, band := range fingerprint.Bands {
+		keys := x.buckets[band]
+		for i, k := range keys {
+			if k == key {
+				x.buckets[band] = append(keys[:i], keys[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// Find returns the indexed texts that are near-duplicates of
+// fingerprint, most alike first
+func (x *DuplicateIndex) Find(fingerprint *ContentFingerprint) []DuplicateMatch {
+	if fingerprint == nil {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var matches []DuplicateMatch
+	for _, band := range fingerprint.Bands {
+		for _, key := range x.buckets[band] {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if similarity := fingerprint.Similarity(x.fingerprints[key]); similarity >= x.Threshold {
+				matches = append(matches, DuplicateMatch{Key: key, Similarity: similarity})
+			}
+		}
+	}
+	sortDuplicateMatches(matches)
+	return matches
+}
+
+func sortDuplicateMatches(matches []DuplicateMatch) {
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Similarity == matches[j].Similarity {
+			return matches[i].Key < matches[j].Key
+		}
+		return matches[i].Similarity > matches[j].Similarity
+	})
+}
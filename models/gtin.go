@@ -0,0 +1,83 @@
+package models
+
+import (
+	"strings"
+)
+
+// NormalizeGTIN strips everything but digits from a GTIN, validates
+// its length and check digit, and left pads it with zeros to the
+// 14 digit GTIN-14 form so UPC-A, EAN-13 and GTIN-14 values compare equal.
+// Returns false when the value is not a valid GTIN.
+func NormalizeGTIN(value string) (string, bool) {
+	var b strings.Builder
+	for _, r := range value {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	digits := b.String()
+	switch len(digits) {
+	case 8, 12, 13, 14:
+	default:
+		return "", false
+	}
+	if strings.Trim(digits, "0") == "" {
+		return "", false
+	}
+	digits = strings.Repeat("0", 14-len(digits)) + digits
+	if !validGTINCheckDigit(digits) {
+		return "", false
+	}
+	return digits, true
+}
+
+// ValidGTIN returns true when value is a GTIN-8, UPC-A, EAN-13
+// or GTIN-14 with a correct check digit.
+func ValidGTIN(value string) bool {
+	_, ok := NormalizeGTIN(value)
+	return ok
+}
+
+// Verifies the GS1 mod 10 check digit of a 14 digit GTIN
+func validGTINCheckDigit(digits string) bool {
+	sum := 0
+	for i := 0; i < 13; i++ {
+		d := int(digits[i] - '0')
+		// -- Weights alternate 3,1 starting from the leftmost digit of a GTIN-14
+		if i%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	check := (10 - sum%10) % 10
+	return check == int(digits[13]-'0')
+}
+
+// NormalizedGTINs returns the distinct, valid, normalized GTINs of a Product
+func (p *Product) NormalizedGTINs() []string {
+	seen := make(map[string]bool, len(p.GTINs))
+	gtins := make([]string, 0, len(p.GTINs))
+	for _, gtin := range p.GTINs {
+		normalized, ok := NormalizeGTIN(gtin.Value)
+		if !ok || seen[normalized] {
+			continue
+		}
+		seen[normalized] = true
+		gtins = append(gtins, normalized)
+	}
+	return gtins
+}
+
+// NormalizeMPN upper cases a manufacturer part number and
+// removes spaces, dashes, dots and slashes.
+func NormalizeMPN(mpn string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(mpn) {
+		switch r {
+		case ' ', '-', '.', '/', '_':
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
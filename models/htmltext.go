@@ -0,0 +1,387 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Elements that are kept by SanitizeHTML and the attributes they may keep
+var allowedElements = map[atom.Atom][]string{
+	atom.P: nil, atom.Br: nil, atom.Hr: nil, atom.Div: nil, atom.Span: nil,
+	atom.H1: nil, atom.H2: nil, atom.H3: nil, atom.H4: nil, atom.H5: nil, atom.H6: nil,
+	atom.Ul: nil, atom.Ol: {"start"}, atom.Li: nil, atom.Dl: nil, atom.Dt: nil, atom.Dd: nil,
+	atom.Strong: nil, atom.B: nil, atom.Em: nil, atom.I: nil, atom.U: nil, atom.S: nil,
+	atom.Sub: nil, atom.Sup: nil, atom.Small: nil, atom.Mark: nil,
+	atom.Blockquote: nil, atom.Pre: {"class"}, atom.Code: {"class"},
+	atom.Table: nil, atom.Caption: nil, atom.Thead: nil, atom.Tbody: nil, atom.Tfoot: nil,
+	atom.Tr: nil, atom.Th: {"colspan", "rowspan"}, atom.Td: {"colspan", "rowspan"},
+	atom.A:      {"href", "title"},
+	atom.Img:    {"src", "alt", "title", "width", "height"},
+	atom.Figure: nil, atom.Figcaption: nil,
+}
+
+// Elements that are removed along with everything inside them
+var droppedElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Iframe: true,
+	atom.Object: true, atom.Embed: true, atom.Applet: true, atom.Form: true,
+	atom.Input: true, atom.Button: true, atom.Select: true, atom.Textarea: true,
+	atom.Svg: true, atom.Math: true, atom.Template: true, atom.Head: true,
+	atom.Title: true, atom.Meta: true, atom.Link: true, atom.Base: true,
+	atom.Frame: true, atom.Frameset: true, atom.Audio: true, atom.Video: true,
+}
+
+// Elements that start a new paragraph in text
+var blockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.H1: true, atom.H2: true, atom.H3: true,
+	atom.H4: true, atom.H5: true, atom.H6: true, atom.Ul: true, atom.Ol: true,
+	atom.Dl: true, atom.Blockquote: true, atom.Pre: true, atom.Table: true,
+	atom.Hr: true, atom.Figure: true, atom.Section: true, atom.Article: true,
+	atom.Header: true, atom.Footer: true, atom.Aside: true, atom.Nav: true,
+	atom.Address: true, atom.Main: true,
+}
+
+// Parses an HTML fragment as the contents of a body element
+func parseHTMLFragment(fragment string) ([]*html.Node, error) {
+	context := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	return html.ParseFragment(strings.NewReader(fragment), context)
+}
+
+// SanitizeHTML keeps only allowlisted elements and attributes of an HTML
+// fragment. Scripts, styles, embeds and forms are removed with their
+// content, event handlers and inline styles are dropped, links are limited
+// to http, https and mailto and tracking pixels are removed.
+func SanitizeHTML(fragment string) string {
+	if strings.TrimSpace(fragment) == "" {
+		return ""
+	}
+	nodes, err := parseHTMLFragment(fragment)
+	if err != nil {
+		return html.EscapeString(fragment)
+	}
+	var b strings.Builder
+	for _, node := range nodes {
+		sanitizeNode(&b, node)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func sanitizeNode(b *strings.Builder, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(html.EscapeString(n.Data))
+		return
+	case html.ElementNode:
+	default:
+		// -- Comments and doctypes are dropped, documents are walked
+		if n.Type == html.DocumentNode {
+			for child := n.FirstChild; child != nil; child = child.NextSibling {
+				sanitizeNode(b, child)
+			}
+		}
+		return
+	}
+
+	if droppedElements[n.DataAtom] {
+		return
+	}
+	allowed, ok := allowedElements[n.DataAtom]
+	if !ok {
+		// -- Unknown elements are unwrapped, their content is kept
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			sanitizeNode(b, child)
+		}
+		return
+	}
+	if n.DataAtom == atom.Img {
+		// -- Tracking pixels and images without a safe source show nothing
+		if _, ok := safeURL(attrValue(n, "src"), false); !ok || isTrackingPixel(n) {
+			return
+		}
+	}
+
+	b.WriteString("<" + n.Data)
+	for _, attr := range n.Attr {
+		if attr.Namespace != "" || !containsString(allowed, attr.Key) {
+			continue
+		}
+		value := attr.Val
+		if attr.Key == "href" || attr.Key == "src" {
+			var ok bool
+			if value, ok = safeURL(value, attr.Key == "href"); !ok {
+				continue
+			}
+		}
+		fmt.Fprintf(b, " %s=\"%s\"", attr.Key, html.EscapeString(value))
+	}
+	if n.DataAtom == atom.A {
+		b.WriteString(` rel="nofollow noopener"`)
+	}
+	b.WriteString(">")
+	if isVoidElement(n.DataAtom) {
+		return
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		sanitizeNode(b, child)
+	}
+	b.WriteString("</" + n.Data + ">")
+}
+
+// Returns the URL when its scheme is safe to render. Links may be
+// http, https, mailto or relative, images http, https or relative.
+func safeURL(value string, link bool) (string, bool) {
+	value = strings.TrimSpace(value)
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return "", false
+	}
+	switch strings.ToLower(parsed.Scheme) {
+	case "http", "https":
+		return value, true
+	case "mailto":
+		return value, link
+	case "":
+		return value, !strings.HasPrefix(value, "//")
+	}
+	return "", false
+}
+
+// Images of a pixel or less, or hidden, are used to track readers
+func isTrackingPixel(n *html.Node) bool {
+	for _, key := range []string{"width", "height"} {
+		if value := strings.TrimSuffix(attrValue(n, key), "px"); value != "" {
+			if size, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && size <= 1 {
+				return true
+			}
+		}
+	}
+	style := strings.ReplaceAll(strings.ToLower(attrValue(n, "style")), " ", "")
+	return strings.Contains(style, "display:none") || strings.Contains(style, "visibility:hidden")
+}
+
+func attrValue(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+func isVoidElement(a atom.Atom) bool {
+	return a == atom.Br || a == atom.Hr || a == atom.Img
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Writes text with collapsed whitespace, deferring spaces and line
+// breaks until the next word so none are left at the ends.
+type textWriter struct {
+	b        strings.Builder
+	newlines int
+	space    bool
+}
+
+func (w *textWriter) word(s string) {
+	if s == "" {
+		return
+	}
+	if w.b.Len() > 0 {
+		switch {
+		case w.newlines > 0:
+			w.b.WriteString(strings.Repeat("\n", minInt(w.newlines, 2)))
+		case w.space:
+			w.b.WriteString(" ")
+		}
+	}
+	w.newlines = 0
+	w.space = false
+	w.b.WriteString(s)
+}
+
+func (w *textWriter) text(s string) {
+	if s == "" {
+		return
+	}
+	if isHTMLSpace(s[0]) {
+		w.space = true
+	}
+	fields := strings.Fields(s)
+	for i, field := range fields {
+		if i > 0 {
+			w.space = true
+		}
+		w.word(field)
+	}
+	if len(fields) > 0 && isHTMLSpace(s[len(s)-1]) {
+		w.space = true
+	}
+}
+
+func (w *textWriter) breakLine(n int) {
+	if n > w.newlines {
+		w.newlines = n
+	}
+}
+
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// HTMLToText converts an HTML fragment to plain text. Paragraphs and
+// headings are separated by a blank line, list items are put on their
+// own line with a "-" or their number, and links are followed by
+// their URL when it differs from the link text.
+func HTMLToText(fragment string) string {
+	if strings.TrimSpace(fragment) == "" {
+		return ""
+	}
+	nodes, err := parseHTMLFragment(fragment)
+	if err != nil {
+		return strings.TrimSpace(fragment)
+	}
+	w := &textWriter{}
+	for _, node := range nodes {
+		writeNodeText(w, node)
+	}
+	return w.b.String()
+}
+
+func writeNodeText(w *textWriter, n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		w.text(n.Data)
+		return
+	case html.ElementNode, html.DocumentNode:
+	default:
+		return
+	}
+	if droppedElements[n.DataAtom] {
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		w.breakLine(1)
+		return
+	case atom.Hr:
+		w.breakLine(2)
+		return
+	case atom.Img:
+		if alt := strings.TrimSpace(attrValue(n, "alt")); alt != "" && !isTrackingPixel(n) {
+			w.text(" ")
+			w.word("[" + alt + "]")
+			w.text(" ")
+		}
+		return
+	case atom.Pre:
+		w.breakLine(2)
+		for _, line := range strings.Split(strings.Trim(nodeText(n), "\n"), "\n") {
+			w.word(line)
+			w.breakLine(1)
+		}
+		w.breakLine(2)
+		return
+	case atom.Li:
+		w.breakLine(1)
+		w.word(listMarker(n))
+		w.space = true
+	case atom.Tr, atom.Dt, atom.Dd, atom.Caption, atom.Figcaption:
+		w.breakLine(1)
+	case atom.Td, atom.Th:
+		if n.PrevSibling != nil {
+			w.word(" |")
+			w.space = true
+		}
+	}
+	if blockElements[n.DataAtom] {
+		w.breakLine(2)
+	}
+
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		writeNodeText(w, child)
+	}
+
+	if n.DataAtom == atom.A {
+		href := strings.TrimSpace(attrValue(n, "href"))
+		text := strings.TrimSpace(nodeText(n))
+		if _, ok := safeURL(href, true); ok && href != "" && !strings.HasPrefix(href, "#") &&
+			href != text && strings.TrimPrefix(href, "mailto:") != text {
+			w.space = true
+			w.word("(" + href + ")")
+		}
+	}
+	if blockElements[n.DataAtom] {
+		w.breakLine(2)
+	}
+}
+
+// Returns "-" for an unordered list item and its number for an ordered one
+func listMarker(li *html.Node) string {
+	parent := li.Parent
+	if parent == nil || parent.DataAtom != atom.Ol {
+		return "-"
+	}
+	number := 1
+	if start, err := strconv.Atoi(attrValue(parent, "start")); err == nil {
+		number = start
+	}
+	for sibling := li.PrevSibling; sibling != nil; sibling = sibling.PrevSibling {
+		if sibling.Type == html.ElementNode && sibling.DataAtom == atom.Li {
+			number++
+		}
+	}
+	return strconv.Itoa(number) + "."
+}
+
+// Returns the text inside a node as it is, without formatting
+func nodeText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && droppedElements[child.DataAtom] {
+			continue
+		}
+		if child.Type == html.ElementNode && child.DataAtom == atom.Br {
+			b.WriteString("\n")
+			continue
+		}
+		b.WriteString(nodeText(child))
+	}
+	return b.String()
+}
+
+// SanitizeHTML sanitizes the Product's DescriptionHTML and derives
+// the Description from it when the extractor left it empty.
+func (p *Product) SanitizeHTML() {
+	logging := p.Logger
+	logging.Debug("Product.SanitizeHTML() was called")
+	p.DescriptionHTML = SanitizeHTML(p.DescriptionHTML)
+	if strings.TrimSpace(p.Description) == "" {
+		p.Description = HTMLToText(p.DescriptionHTML)
+	}
+}
+
+// SanitizeHTML sanitizes the Article's ArticleBodyHTML and derives
+// the ArticleBody from it when the extractor left it empty.
+func (a *Article) SanitizeHTML() {
+	logging := a.Logger
+	logging.Debug("Article.SanitizeHTML() was called")
+	a.ArticleBodyHTML = SanitizeHTML(a.ArticleBodyHTML)
+	if strings.TrimSpace(a.ArticleBody) == "" {
+		a.ArticleBody = HTMLToText(a.ArticleBodyHTML)
+	}
+}
@@ -0,0 +1,49 @@
+package models
+
+import "testing"
+
+func TestSanitizeHTML(t *testing.T) {
+	tests := []struct {
+		fragment string
+		want     string
+	}{
+		{`<p>Hello <b>world</b></p>`, `<p>Hello <b>world</b></p>`},
+		{`<p onclick="evil()" style="color:red">Text</p><script>alert(1)</script>`, `<p>Text</p>`},
+		{`<iframe src="https://example.com"></iframe><div><span>kept</span></div>`, `<div><span>kept</span></div>`},
+		{
+			`<a href="javascript:alert(1)">x</a> <a href="https://example.com/a" target="_blank" title="t">y</a>`,
+			`<a rel="nofollow noopener">x</a> <a href="https://example.com/a" title="t" rel="nofollow noopener">y</a>`,
+		},
+		{
+			`<a href="mailto:jane@example.com">mail</a><a href="ftp://example.com">ftp</a>`,
+			`<a href="mailto:jane@example.com" rel="nofollow noopener">mail</a><a rel="nofollow noopener">ftp</a>`,
+		},
+		{`<img src="https://t.example.com/p.gif" width="1" height="1"><img src="/a.png" alt="A">`, `<img src="/a.png" alt="A">`},
+		{`<ol start="3" type="a"><li>one</li></ol>`, `<ol start="3"><li>one</li></ol>`},
+		{`plain & text <unknown>inside</unknown>`, `plain &amp; text inside`},
+		{"   ", ""},
+	}
+	for _, tt := range tests {
+		if got := SanitizeHTML(tt.fragment); got != tt.want {
+			t.Errorf("SanitizeHTML(%q) = %q, want %q", tt.fragment, got, tt.want)
+		}
+	}
+}
+
+func TestHTMLToText(t *testing.T) {
+	tests := []struct {
+		fragment string
+		want     string
+	}{
+		{`<p>Hello <b>world</b></p><p>Second   paragraph</p>`, "Hello world\n\nSecond paragraph"},
+		{`<ul><li>one</li><li>two</li></ul>`, "- one\n- two"},
+		{`<p>a<br>b</p><script>x</script>`, "a\nb"},
+		{`Caf&eacute; &amp; bar`, "Café & bar"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := HTMLToText(tt.fragment); got != tt.want {
+			t.Errorf("HTMLToText(%q) = %q, want %q", tt.fragment, got, tt.want)
+		}
+	}
+}
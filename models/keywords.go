@@ -0,0 +1,343 @@
+package models
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/chuxorg/chux-models/logging"
+)
+
+// Punctuation that ends a candidate keyword phrase
+var keywordBoundaryRegex = regexp.MustCompile(`[.,;:!?()\[\]{}"“”„«»‹›…|/\\*•·–—\n\r\t]+`)
+
+// Words that do not start, end or make up a keyword, by language.
+// They are written with their accents folded, as NormalizeText does.
+var keywordStopwords = map[string]map[string]bool{
+	"en": stopwordSet(`a about above after again against all almost also although always am among an and another any
+		anyone anything are around as at away back be became because become been before being below
+		between both but by came can cannot come could did didn do does doesn done don down during each
+		either else enough etc even ever every few for from further get gets getting give given go goes going
+		got had has hasn have having he her here hers him his how however i if in including instead into is isn it
+		its itself just keep know last least less let like made make makes many may me might more most much must my
+		need never next no nor not now of off often on once one only or other others our out over own per perhaps
+		put rather re really right said same say says see seen several shall she should since so some something
+		still such take than that the their them then there these they thing things think this those though
+		through thus to too under until up upon us use used using very via want was way we well went were
+		what whatever when where whether which while who whom whose why will with within without won would yet
+		you your yours ll ve wasn aren weren wouldn couldn shouldn`),
+	"de": stopwordSet(`aber alle allem allen aller alles als also am an ander andere anderen auch auf aus bei beim bereits
+		bin bis bist da dabei dadurch dafur damit dann darf darum das dass dein deine dem den denn der des deshalb
+		dessen die dies diese diesem diesen dieser dieses doch dort du durch ein eine einem einen einer eines einige
+		er es etwa euch euer fur gegen gewesen hab habe haben hat hatte hatten hier hin hinter ich ihm ihn ihnen ihr
+		ihre ihrem ihren ihrer im in ist ja jede jedem jeden jeder jedes jetzt kann kannst kein keine keinen konnen
+		konnte machen man mehr mein meine mich mir mit muss mussen nach nicht nichts noch nun nur ob oder ohne schon
+		sehr sein seine seinem seinen seiner seit sich sie sind so solche soll sollte sondern sowie uber um und uns
+		unser unter viel vom von vor wann war waren warum was weil weiter welche welchem welchen welcher wenn wer
+		werde werden wie wieder will wir wird wo wurde wurden zu zum zur zwar zwischen`),
+	"fr": stopwordSet(`a afin ai aie aient ainsi alors au aucun aussi autre autres aux avait avant avec avoir ayant bien
+		c ce ceci cela celle celles celui ces cet cette ceux chaque chez comme comment d dans de des deux devant doit
+		donc dont du elle elles en encore entre est et etaient etait ete etre eu eux fait faire fois font hors il ils
+		j je jusqu l la le les leur leurs lors lui m ma mais me meme mes moi moins mon n ne ni nos notre nous on ont
+		ou par parce pas peu peut plus pour pourquoi qu quand que quel quelle quelles quels qui s sa sans se selon ses
+		si son sont sous sur ta te tes toi ton tous tout toute toutes tres tu un une vers vos votre vous y`),
+	"es": stopwordSet(`a al algo algunas algunos ante antes como con contra cual cuando de del desde donde durante e el
+		ella ellas ellos en entre era eran es esa esas ese eso esos esta estaba estado estan estar este esto estos fue
+		fueron gran ha haber habia han hasta hay la las le les lo los mas me mi mientras muy nada ni no nos nosotros o
+		otra otras otro otros para pero poco por porque puede que quien se sea ser si sin sino sobre son su sus tambien
+		tanto te tiene tienen todo todos tu un una unas uno unos y ya yo`),
+	"it": stopwordSet(`a ad al alla alle agli ai anche ancora avere c che chi ci come con contro cosi cui da dal dalla
+		dalle dai degli dei del della delle di dopo dove e ed era erano essere fa gli ha hanno i il in io la le lei
+		lo loro lui ma mi molto ne negli nei nel nella nelle no noi non o per perche piu poi prima qua quale quando
+		quella quelle quelli quello questa queste questi questo se sei senza si sia siamo sono su sua sue sugli sui
+		sul sulla suo suoi tra tutti tutto un una uno voi`),
+	"pt": stopwordSet(`a ao aos as ate com como da das de dela dele deles do dos e ela elas ele eles em entre era eram
+		essa esse esta estao este eu foi foram ha isso isto ja la lhe mais mas me mesmo meu minha muito na nao nas
+		nem no nos nossa nosso num numa o os ou para pela pelas pelo pelos por quando que quem se sem ser seu sua
+		suas seus so tambem te tem ter um uma umas uns voce`),
+	"nl": stopwordSet(`aan al alles als altijd andere ben bij daar dan dat de der deze die dit doch doen door dus een
+		eens en er ge geen geweest haar had heb hebben heeft hem het hier hij hoe hun iemand iets ik in is ja je kan
+		kon kunnen maar me meer men met mij mijn moet na naar niet niets nog nu of om omdat onder ons ook op over
+		reeds te tegen toch toen tot u uit uw van veel voor want waren was wat we wel werd wezen wie wij wil worden
+		wordt zal ze zelf zich zij zijn zo zonder zou`),
+}
+
+// Builds the set of the words in a whitespace separated list
+func stopwordSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(words) {
+		set[word] = true
+	}
+	return set
+}
+
+// Stopwords returns the stopwords of a language, given as an ISO 639-1
+// code, or nil when there are none for it
+func Stopwords(language string) map[string]bool {
+	return keywordStopwords[primaryLanguage(language)]
+}
+
+// Keyword is a word or phrase that tells what a text is about
+type Keyword struct {
+	Term  string  `bson:"term" json:"term"`
+	Slug  string  `bson:"slug" json:"slug"`
+	Score float64 `bson:"score" json:"score"`
+}
+
+// KeywordExtractor finds the keywords of texts with RAKE, candidate
+// phrases are the runs of words between stopwords and punctuation and
+// are scored by how often their words occur and in how long phrases.
+// When the extractor has seen other documents the scores are weighted
+// by the inverse document frequency of the words, as in TF-IDF.
+type KeywordExtractor struct {
+	// Number of keywords returned for a text
+	MaxKeywords int
+	// Longer phrases are split
+	MaxPhraseWords int
+	// Shorter single words are not keywords
+	MinWordLength int
+	// Keywords of a title score this many times as much as those of a body
+	TitleWeight float64
+	Logger      *logging.Logger
+	documents   int
+	frequencies map[string]int
+}
+
+// Creates a NewKeywordExtractor with Options.
+// By default up to 10 keywords of up to 3 words are extracted, single
+// words need at least 3 letters and titles count twice.
+func NewKeywordExtractor(options ...func(*KeywordExtractor)) *KeywordExtractor {
+	x := &KeywordExtractor{
+		MaxKeywords:    10,
+		MaxPhraseWords: 3,
+		MinWordLength:  3,
+		TitleWeight:    2,
+		frequencies:    make(map[string]int),
+	}
+	for _, option := range options {
+		option(x)
+	}
+	return x
+}
+
+func NewKeywordExtractorWithLogger(logger logging.Logger) func(*KeywordExtractor) {
+	return func(x *KeywordExtractor) {
+		x.Logger = &logger
+	}
+}
+
+// Sets the number of keywords returned for a text
+func NewKeywordExtractorWithMaxKeywords(max int) func(*KeywordExtractor) {
+	return func(x *KeywordExtractor) {
+		x.MaxKeywords = max
+	}
+}
+
+// Sets the number of words a keyword phrase can have
+func NewKeywordExtractorWithMaxPhraseWords(max int) func(*KeywordExtractor) {
+	return func(x *KeywordExtractor) {
+		x.MaxPhraseWords = max
+	}
+}
+
+// Sets how many times as much the keywords of a title score
+func NewKeywordExtractorWithTitleWeight(weight float64) func(*KeywordExtractor) {
+	return func(x *KeywordExtractor) {
+		x.TitleWeight = weight
+	}
+}
+
+// AddDocument counts the words of a text in the document frequencies
+// the inverse document frequency weights are computed from. Adding a
+// sample of the corpus first keeps words that are common in it, such
+// as the name of a site, from becoming keywords.
+func (x *KeywordExtractor) AddDocument(text, language string) {
+	seen := make(map[string]bool)
+	for _, phrase := range x.phrases(text, language) {
+		for _, word := range phrase {
+			if !seen[word] {
+				seen[word] = true
+				x.frequencies[word]++
+			}
+		}
+	}
+	x.documents++
+}
+
+// The smoothed inverse document frequency of a word, 1 when
+// no documents have been added
+func (x *KeywordExtractor) idf(word string) float64 {
+	if x.documents == 0 {
+		return 1
+	}
+	return math.Log(float64(1+x.documents)/float64(1+x.frequencies[word])) + 1
+}
+
+// Extract returns the keywords of a text with a title, best first.
+// language is an ISO 639-1 code that picks the stopwords, when it is
+// empty or has none the stopwords of all languages are used.
+func (x *KeywordExtractor) Extract(title, body, language string) []Keyword {
+	logging := x.Logger
+	logging.Debug("KeywordExtractor.Extract() was called")
+	titlePhrases := x.phrases(title, language)
+	phrases := append(append([][]string{}, titlePhrases...), x.phrases(body, language)...)
+	if len(phrases) == 0 {
+		return nil
+	}
+
+	// -- RAKE word scores, the degree of a word over its frequency
+	frequency := make(map[string]float64)
+	degree := make(map[string]float64)
+	for _, phrase := range phrases {
+		for _, word := range phrase {
+			frequency[word]++
+			degree[word] += float64(len(phrase))
+		}
+	}
+
+	type candidate struct {
+		keyword Keyword
+		count   float64
+	}
+	candidates := make(map[string]*candidate)
+	var order []string
+	for i, phrase := range phrases {
+		term := strings.Join(phrase, " ")
+		slug := Slugify(term)
+		if slug == "" {
+			continue
+		}
+		c, ok := candidates[slug]
+		if !ok {
+			score := 0.0
+			idf := 0.0
+			for _, word := range phrase {
+				score += degree[word] / frequency[word]
+				idf += x.idf(word)
+			}
+			c = &candidate{keyword: Keyword{Term: term, Slug: slug, Score: score * idf / float64(len(phrase))}}
+			candidates[slug] = c
+			order = append(order, slug)
+		}
+		if i < len(titlePhrases) {
+			c.count += x.TitleWeight
+		} else {
+			c.count++
+		}
+	}
+
+	keywords := make([]Keyword, 0, len(candidates))
+	for _, slug := range order {
+		c := candidates[slug]
+		c.keyword.Score *= 1 + math.Log(c.count)
+		keywords = append(keywords, c.keyword)
+	}
+	sort.SliceStable(keywords, func(i, j int) bool {
+		return keywords[i].Score > keywords[j].Score
+	})
+	if x.MaxKeywords > 0 && len(keywords) > x.MaxKeywords {
+		keywords = keywords[:x.MaxKeywords]
+	}
+	return keywords
+}
+
+// Splits a text into candidate phrases, the lower cased words between
+// punctuation, stopwords and numbers, of up to MaxPhraseWords words
+func (x *KeywordExtractor) phrases(text, language string) [][]string {
+	stopwords := Stopwords(language)
+	var phrases [][]string
+	end := func(phrase []string) {
+		// -- Runs that are too long, such as product names, are split into shorter phrases
+		for len(phrase) > 0 {
+			size := len(phrase)
+			if x.MaxPhraseWords > 0 && size > x.MaxPhraseWords {
+				size = x.MaxPhraseWords
+			}
+			chunk := phrase[:size]
+			phrase = phrase[size:]
+			if len(chunk) == 1 && len([]rune(chunk[0])) < x.MinWordLength {
+				continue
+			}
+			phrases = append(phrases, chunk)
+		}
+	}
+	for _, fragment := range keywordBoundaryRegex.Split(text, -1) {
+		var phrase []string
+		for _, word := range keywordWords(fragment) {
+			if isKeywordStopword(word, stopwords) {
+				end(phrase)
+				phrase = nil
+				continue
+			}
+			phrase = append(phrase, word)
+		}
+		end(phrase)
+	}
+	return phrases
+}
+
+// The lower cased words of a text, hyphenated words are kept whole
+// and so are the symbols ending words such as "c++" and "c#"
+func keywordWords(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '+' && r != '#'
+	})
+	kept := words[:0]
+	for _, word := range words {
+		word = strings.TrimRight(strings.TrimLeft(word, "-+#"), "-")
+		if word != "" {
+			kept = append(kept, word)
+		}
+	}
+	return kept
+}
+
+// Words that are stopwords, numbers or a single letter end a phrase.
+// Without stopwords for the language those of every language are used.
+func isKeywordStopword(word string, stopwords map[string]bool) bool {
+	if len([]rune(word)) < 2 || strings.IndexFunc(word, unicode.IsLetter) < 0 {
+		return true
+	}
+	folded := foldReplacer.Replace(word)
+	if stopwords != nil {
+		return stopwords[folded]
+	}
+	for _, set := range keywordStopwords {
+		if set[folded] {
+			return true
+		}
+	}
+	return false
+}
+
+// Symbols ending the names of languages and products, such as "C++",
+// "C#" and "Disney+", which Tokenize would drop
+var slugSymbolRegex = regexp.MustCompile(`([\p{L}\p{N}])(\+\+|\+|#)([^\p{L}\p{N}+#]|$)`)
+
+var slugSymbolWords = map[string]string{"++": " plus plus ", "+": " plus ", "#": " sharp "}
+
+// Slugify normalizes a term to the slug a Tag is unique by,
+// "Café Culture" becomes "cafe-culture" and "C++" "c-plus-plus"
+func Slugify(term string) string {
+	term = slugSymbolRegex.ReplaceAllStringFunc(term, func(match string) string {
+		parts := slugSymbolRegex.FindStringSubmatch(match)
+		return parts[1] + slugSymbolWords[parts[2]] + parts[3]
+	})
+	return strings.Join(Tokenize(term), "-")
+}
+
+// ExtractKeywords returns the keywords of the Article's Headline and ArticleBody
+func (a *Article) ExtractKeywords(extractor *KeywordExtractor) []Keyword {
+	logging := a.Logger
+	logging.Debug("Article.ExtractKeywords() was called")
+	return extractor.Extract(a.Headline, a.ArticleBody, a.InLanguage)
+}
+
+// ExtractKeywords returns the keywords of the Product's Name and Description
+func (p *Product) ExtractKeywords(extractor *KeywordExtractor) []Keyword {
+	logging := p.Logger
+	logging.Debug("Product.ExtractKeywords() was called")
+	return extractor.Extract(p.Name, p.Description, p.InLanguage)
+}
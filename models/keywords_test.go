@@ -0,0 +1,49 @@
+package models
+
+import "testing"
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		term string
+		want string
+	}{
+		{"Café Culture", "cafe-culture"},
+		{"C", "c"},
+		{"C++", "c-plus-plus"},
+		{"C#", "c-sharp"},
+		{"F# and C#", "f-sharp-and-c-sharp"},
+		{"Disney+ prices", "disney-plus-prices"},
+		{"#hashtag", "hashtag"},
+		{"Rock & Roll", "rock-and-roll"},
+		{"  ", ""},
+	}
+	for _, tt := range tests {
+		if got := Slugify(tt.term); got != tt.want {
+			t.Errorf("Slugify(%q) = %q, want %q", tt.term, got, tt.want)
+		}
+	}
+}
+
+func TestKeywordWords(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Learning C++ and C#", []string{"learning", "c++", "and", "c#"}},
+		{"#Launch of the e-reader", []string{"launch", "of", "the", "e-reader"}},
+		{"-- + #", nil},
+	}
+	for _, tt := range tests {
+		got := keywordWords(tt.text)
+		if len(got) != len(tt.want) {
+			t.Errorf("keywordWords(%q) = %q, want %q", tt.text, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("keywordWords(%q) = %q, want %q", tt.text, got, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,514 @@
+package models
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/chuxorg/chux-models/logging"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Sample text the trigram profiles of languages written in the Latin
+// and Cyrillic scripts are built from. Languages with a script of
+// their own are told apart by script alone.
+var languageSamples = map[string]string{
+	"en": `The new model is available in three colours and ships within two days. Customers who bought this item also
+		bought the matching case. The government said on Thursday that the economy had grown faster than expected in the
+		third quarter, which was good news for workers and their families. This is one of the best products we have ever
+		tested, and it would make a great gift for anyone who likes to cook. Please read the instructions before using
+		it for the first time. All the people of the world are born free and equal in dignity and rights. There were
+		more than a thousand visitors at the show this year, and most of them said they would come back again.
+		Made of stainless steel with a soft cotton lining, this wireless set includes a charger, a battery and a kitchen
+		knife. Free shipping on all orders over fifty dollars. Choose your size and colour: black, white, red or blue.
+		Each purchase comes with a two year warranty and easy returns.`,
+	"de": `Das neue Modell ist in drei Farben erhältlich und wird innerhalb von zwei Tagen versandt. Kunden, die diesen
+		Artikel gekauft haben, kauften auch die passende Hülle. Die Regierung teilte am Donnerstag mit, dass die
+		Wirtschaft im dritten Quartal schneller gewachsen ist als erwartet, was eine gute Nachricht für die Arbeitnehmer
+		und ihre Familien war. Dies ist eines der besten Produkte, die wir je getestet haben, und es wäre ein schönes
+		Geschenk für alle, die gerne kochen. Bitte lesen Sie die Anleitung, bevor Sie es zum ersten Mal benutzen. Alle
+		Menschen sind frei und gleich an Würde und Rechten geboren. In diesem Jahr waren mehr als tausend Besucher auf
+		der Messe, und die meisten von ihnen sagten, dass sie wiederkommen würden.
+		Aus Edelstahl mit einem weichen Baumwollfutter, dieses kabellose Set enthält ein Ladegerät, einen Akku und ein
+		Küchenmesser. Kostenloser Versand für alle Bestellungen über fünfzig Euro. Wählen Sie Ihre Größe und Farbe:
+		schwarz, weiß, rot oder blau. Jeder Kauf wird mit zwei Jahren Garantie und einfacher Rückgabe geliefert.`,
+	"fr": `Le nouveau modèle est disponible en trois couleurs et il est expédié sous deux jours. Les clients qui ont
+		acheté cet article ont également acheté l'étui assorti. Le gouvernement a déclaré jeudi que l'économie avait
+		progressé plus vite que prévu au troisième trimestre, ce qui était une bonne nouvelle pour les travailleurs et
+		leurs familles. C'est l'un des meilleurs produits que nous ayons jamais testés, et ce serait un beau cadeau pour
+		tous ceux qui aiment cuisiner. Veuillez lire les instructions avant la première utilisation. Tous les êtres
+		humains naissent libres et égaux en dignité et en droits. Il y avait plus de mille visiteurs au salon cette
+		année, et la plupart d'entre eux ont dit qu'ils reviendraient.
+		Fabriqué en acier inoxydable avec une doublure douce en coton, cet ensemble sans fil comprend un chargeur, une
+		batterie et un couteau de cuisine. Livraison gratuite pour toutes les commandes de plus de cinquante euros.
+		Choisissez votre taille et votre couleur : noir, blanc, rouge ou bleu. Chaque achat est accompagné d'une
+		garantie de deux ans et de retours faciles.`,
+	"es": `El nuevo modelo está disponible en tres colores y se envía en un plazo de dos días. Los clientes que compraron
+		este artículo también compraron la funda a juego. El gobierno dijo el jueves que la economía había crecido más
+		rápido de lo esperado en el tercer trimestre, lo que fue una buena noticia para los trabajadores y sus familias.
+		Este es uno de los mejores productos que hemos probado, y sería un gran regalo para cualquiera a quien le guste
+		cocinar. Por favor, lea las instrucciones antes de usarlo por primera vez. Todos los seres humanos nacen libres e
+		iguales en dignidad y derechos. Hubo más de mil visitantes en la feria este año, y la mayoría de ellos dijo que
+		volvería.
+		Fabricado en acero inoxidable con un suave forro de algodón, este juego inalámbrico incluye un cargador, una
+		batería y un cuchillo de cocina. Envío gratis en todos los pedidos de más de cincuenta euros. Elige tu talla y
+		color: negro, blanco, rojo o azul. Cada compra incluye una garantía de dos años y devoluciones fáciles.`,
+	"it": `Il nuovo modello è disponibile in tre colori e viene spedito entro due giorni. I clienti che hanno acquistato
+		questo articolo hanno acquistato anche la custodia abbinata. Il governo ha detto giovedì che l'economia è
+		cresciuta più rapidamente del previsto nel terzo trimestre, una buona notizia per i lavoratori e le loro
+		famiglie. Questo è uno dei migliori prodotti che abbiamo mai provato, e sarebbe un bel regalo per chiunque ami
+		cucinare. Si prega di leggere le istruzioni prima di usarlo per la prima volta. Tutti gli esseri umani nascono
+		liberi ed eguali in dignità e diritti. Quest'anno alla fiera c'erano più di mille visitatori, e la maggior parte
+		di loro ha detto che sarebbe tornata.
+		Realizzato in acciaio inossidabile con una morbida fodera di cotone, questo set senza fili comprende un
+		caricabatterie, una batteria e un coltello da cucina. Spedizione gratuita per tutti gli ordini superiori a
+		cinquanta euro. Scegli la tua taglia e il colore: nero, bianco, rosso o blu. Ogni acquisto include una garanzia
+		di due anni e resi facili.`,
+	"pt": `O novo modelo está disponível em três cores e é enviado em até dois dias. Os clientes que compraram este
+		artigo também compraram a capa combinando. O governo disse na quinta-feira que a economia cresceu mais rápido do
+		que o esperado no terceiro trimestre, o que foi uma boa notícia para os trabalhadores e suas famílias. Este é um
+		dos melhores produtos que já testamos, e seria um ótimo presente para quem gosta de cozinhar. Por favor, leia as
+		instruções antes de usar pela primeira vez. Todos os seres humanos nascem livres e iguais em dignidade e em
+		direitos. Havia mais de mil visitantes na feira este ano, e a maioria deles disse que voltaria.
+		Feito de aço inoxidável com um forro macio de algodão, este conjunto sem fio inclui um carregador, uma bateria e
+		uma faca de cozinha. Frete grátis em todos os pedidos acima de cinquenta reais. Escolha o seu tamanho e a sua
+		cor: preto, branco, vermelho ou azul. Cada compra vem com dois anos de garantia e devoluções fáceis.`,
+	"nl": `Het nieuwe model is verkrijgbaar in drie kleuren en wordt binnen twee dagen verzonden. Klanten die dit artikel
+		kochten, kochten ook de bijpassende hoes. De regering zei donderdag dat de economie in het derde kwartaal sneller
+		was gegroeid dan verwacht, wat goed nieuws was voor werknemers en hun gezinnen. Dit is een van de beste producten
+		die we ooit hebben getest, en het zou een mooi cadeau zijn voor iedereen die graag kookt. Lees de instructies
+		voordat u het voor de eerste keer gebruikt. Alle mensen worden vrij en gelijk in waardigheid en rechten geboren.
+		Er waren dit jaar meer dan duizend bezoekers op de beurs, en de meesten van hen zeiden dat ze terug zouden komen.
+		Gemaakt van roestvrij staal met een zachte katoenen voering, deze draadloze set bevat een oplader, een batterij
+		en een keukenmes. Gratis verzending voor alle bestellingen boven de vijftig euro. Kies je maat en kleur: zwart,
+		wit, rood of blauw. Bij elke aankoop krijg je twee jaar garantie en eenvoudig retourneren.`,
+	"sv": `Den nya modellen finns i tre färger och skickas inom två dagar. Kunder som köpte den här artikeln köpte också
+		det matchande fodralet. Regeringen sade i torsdags att ekonomin hade vuxit snabbare än väntat under det tredje
+		kvartalet, vilket var goda nyheter för arbetarna och deras familjer. Det här är en av de bästa produkter vi
+		någonsin har testat, och den skulle vara en fin present till alla som tycker om att laga mat. Läs instruktionerna
+		innan du använder den för första gången. Alla människor är födda fria och lika i värde och rättigheter. Det var
+		mer än tusen besökare på mässan i år, och de flesta av dem sade att de skulle komma tillbaka.
+		Tillverkad av rostfritt stål med ett mjukt bomullsfoder, detta trådlösa set innehåller en laddare, ett batteri
+		och en kökskniv. Fri frakt på alla beställningar över femhundra kronor. Välj storlek och färg: svart, vit, röd
+		eller blå. Varje köp har två års garanti och enkla returer.`,
+	"pl": `Nowy model jest dostępny w trzech kolorach i jest wysyłany w ciągu dwóch dni. Klienci, którzy kupili ten
+		produkt, kupili również pasujące etui. Rząd poinformował w czwartek, że gospodarka w trzecim kwartale rosła
+		szybciej, niż oczekiwano, co było dobrą wiadomością dla pracowników i ich rodzin. To jeden z najlepszych
+		produktów, jakie kiedykolwiek testowaliśmy, i byłby świetnym prezentem dla każdego, kto lubi gotować. Przed
+		pierwszym użyciem prosimy przeczytać instrukcję. Wszyscy ludzie rodzą się wolni i równi pod względem swej
+		godności i swych praw. W tym roku na targach było ponad tysiąc zwiedzających, a większość z nich powiedziała,
+		że wróci.
+		Wykonany ze stali nierdzewnej z miękką bawełnianą podszewką, ten bezprzewodowy zestaw zawiera ładowarkę, baterię
+		i nóż kuchenny. Darmowa dostawa dla wszystkich zamówień powyżej pięćdziesięciu złotych. Wybierz swój rozmiar i
+		kolor: czarny, biały, czerwony lub niebieski. Każdy zakup objęty jest dwuletnią gwarancją i łatwym zwrotem.`,
+	"tr": `Yeni model üç renk seçeneğiyle satışta ve iki gün içinde kargoya verilir. Bu ürünü satın alan müşteriler
+		uyumlu kılıfı da satın aldı. Hükümet perşembe günü yaptığı açıklamada ekonominin üçüncü çeyrekte beklenenden daha
+		hızlı büyüdüğünü söyledi, bu da çalışanlar ve aileleri için iyi bir haberdi. Bu, şimdiye kadar test ettiğimiz en
+		iyi ürünlerden biri ve yemek yapmayı seven herkes için harika bir hediye olur. Lütfen ilk kullanımdan önce
+		talimatları okuyun. Bütün insanlar hür, haysiyet ve haklar bakımından eşit doğarlar. Bu yıl fuarda binden fazla
+		ziyaretçi vardı ve çoğu tekrar geleceklerini söyledi.
+		Yumuşak pamuklu astarı olan paslanmaz çelikten yapılmış bu kablosuz set bir şarj cihazı, bir pil ve bir mutfak
+		bıçağı içerir. Elli liranın üzerindeki tüm siparişlerde kargo ücretsizdir. Bedeninizi ve renginizi seçin: siyah,
+		beyaz, kırmızı veya mavi. Her satın alma iki yıl garanti ve kolay iade ile birlikte gelir.`,
+	"ru": `Новая модель доступна в трёх цветах и отправляется в течение двух дней. Покупатели, которые купили этот
+		товар, также купили подходящий чехол. Правительство заявило в четверг, что экономика в третьем квартале росла
+		быстрее, чем ожидалось, и это было хорошей новостью для работников и их семей. Это один из лучших продуктов,
+		которые мы когда-либо тестировали, и он станет отличным подарком для всех, кто любит готовить. Пожалуйста,
+		прочитайте инструкцию перед первым использованием. Все люди рождаются свободными и равными в своём достоинстве
+		и правах. В этом году на выставке было более тысячи посетителей, и большинство из них сказали, что вернутся.
+		Изготовлен из нержавеющей стали с мягкой хлопковой подкладкой, этот беспроводной набор включает зарядное
+		устройство, аккумулятор и кухонный нож. Бесплатная доставка для всех заказов свыше пятисот рублей. Выберите свой
+		размер и цвет: чёрный, белый, красный или синий. Каждая покупка сопровождается гарантией на два года и простым
+		возвратом.`,
+	"uk": `Нова модель доступна в трьох кольорах і відправляється протягом двох днів. Покупці, які придбали цей товар,
+		також придбали відповідний чохол. Уряд заявив у четвер, що економіка у третьому кварталі зростала швидше, ніж
+		очікувалося, і це була гарна новина для працівників та їхніх родин. Це один із найкращих продуктів, які ми
+		будь-коли тестували, і він стане чудовим подарунком для всіх, хто любить готувати. Будь ласка, прочитайте
+		інструкцію перед першим використанням. Усі люди народжуються вільними і рівними у своїй гідності та правах.
+		Цього року на виставці було понад тисячу відвідувачів, і більшість із них сказали, що повернуться.
+		Виготовлений з нержавіючої сталі з м'якою бавовняною підкладкою, цей бездротовий набір містить зарядний
+		пристрій, акумулятор і кухонний ніж. Безкоштовна доставка для всіх замовлень понад п'ятсот гривень. Оберіть свій
+		розмір і колір: чорний, білий, червоний або синій. Кожна покупка супроводжується гарантією на два роки та
+		простим поверненням.`,
+}
+
+// Languages told apart by the script they are written in
+var scriptLanguages = []struct {
+	script   *unicode.RangeTable
+	language string
+}{
+	{unicode.Greek, "el"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Thai, "th"},
+	{unicode.Hangul, "ko"},
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Han, "zh"},
+}
+
+// How much each trigram counts towards a language. Trigrams that
+// overlap are not independent, counting each in full would make
+// the detector far too sure of itself on short texts.
+const trigramEvidenceWeight = 0.3
+
+// Number of trigrams read from a text, enough to tell any language apart
+const maxLanguageTrigrams = 2000
+
+// The trigram log probabilities of a language
+type languageProfile struct {
+	language string
+	logProbs map[string]float64
+	unseen   float64
+}
+
+var (
+	languageProfilesOnce sync.Once
+	latinProfiles        []*languageProfile
+	cyrillicProfiles     []*languageProfile
+)
+
+// Builds the trigram profiles from the samples the first time they are needed
+func loadLanguageProfiles() {
+	languageProfilesOnce.Do(func() {
+		languages := make([]string, 0, len(languageSamples))
+		for language := range languageSamples {
+			languages = append(languages, language)
+		}
+		sort.Strings(languages)
+
+		counts := make(map[string]map[string]int, len(languages))
+		vocabulary := make(map[string]bool)
+		for _, language := range languages {
+			counts[language] = make(map[string]int)
+			for _, trigram := range textTrigrams(languageSamples[language], 0) {
+				counts[language][trigram]++
+				vocabulary[trigram] = true
+			}
+		}
+		for _, language := range languages {
+			total := 0
+			for _, count := range counts[language] {
+				total += count
+			}
+			// -- Add one smoothing so unseen trigrams are unlikely but not impossible
+			denominator := math.Log(float64(total + len(vocabulary) + 1))
+			profile := &languageProfile{
+				language: language,
+				logProbs: make(map[string]float64, len(counts[language])),
+				unseen:   -denominator,
+			}
+			for trigram, count := range counts[language] {
+				profile.logProbs[trigram] = math.Log(float64(count+1)) - denominator
+			}
+			if strings.ContainsAny(languageSamples[language], "абвгдежзийклмнопрстуфхцчшщыьэюяіїє") {
+				cyrillicProfiles = append(cyrillicProfiles, profile)
+			} else {
+				latinProfiles = append(latinProfiles, profile)
+			}
+		}
+	})
+}
+
+// Returns the character trigrams of the words of a text, each word
+// padded with a space so trigrams at the start and end of words count
+func textTrigrams(text string, limit int) []string {
+	var trigrams []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		runes := []rune(" " + word + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			trigrams = append(trigrams, string(runes[i:i+3]))
+			if limit > 0 && len(trigrams) >= limit {
+				return trigrams
+			}
+		}
+	}
+	return trigrams
+}
+
+// LanguageGuess is a detected language as an ISO 639-1 code and how
+// sure the detector is of it, from 0 to 1
+type LanguageGuess struct {
+	Language   string  `json:"language"`
+	Confidence float64 `json:"confidence"`
+}
+
+// LanguageDetector identifies the language of a text from the script it
+// is written in and, for Latin and Cyrillic text, its character trigrams.
+type LanguageDetector struct {
+	// Languages the detector may answer with, all of them when empty
+	Languages []string
+	// Guesses below this confidence are not used to fill in or correct a language
+	MinConfidence float64
+	Logger        *logging.Logger
+}
+
+// Creates a NewLanguageDetector with Options.
+// By default any language may be detected and
+// guesses need a confidence of 0.8 to be used.
+func NewLanguageDetector(options ...func(*LanguageDetector)) *LanguageDetector {
+	d := &LanguageDetector{
+		MinConfidence: 0.8,
+	}
+	for _, option := range options {
+		option(d)
+	}
+	return d
+}
+
+func NewLanguageDetectorWithLogger(logger logging.Logger) func(*LanguageDetector) {
+	return func(d *LanguageDetector) {
+		d.Logger = &logger
+	}
+}
+
+// Limits the languages the detector may answer with
+func NewLanguageDetectorWithLanguages(languages ...string) func(*LanguageDetector) {
+	return func(d *LanguageDetector) {
+		d.Languages = languages
+	}
+}
+
+// Sets the confidence a guess needs to be used
+func NewLanguageDetectorWithMinConfidence(confidence float64) func(*LanguageDetector) {
+	return func(d *LanguageDetector) {
+		d.MinConfidence = confidence
+	}
+}
+
+// SupportedLanguages returns the languages a LanguageDetector can detect
+func SupportedLanguages() []string {
+	var languages []string
+	for language := range languageSamples {
+		languages = append(languages, language)
+	}
+	for _, script := range scriptLanguages {
+		if !containsString(languages, script.language) {
+			languages = append(languages, script.language)
+		}
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// Detect returns the most likely language of a text. A text
+// without letters has no language and a confidence of 0.
+func (d *LanguageDetector) Detect(text string) LanguageGuess {
+	guesses := d.Rank(text)
+	if len(guesses) == 0 {
+		return LanguageGuess{}
+	}
+	return guesses[0]
+}
+
+// Rank returns the languages a text may be in, most likely first
+func (d *LanguageDetector) Rank(text string) []LanguageGuess {
+	logging := d.Logger
+	logging.Debug("LanguageDetector.Rank() was called")
+	loadLanguageProfiles()
+
+	scripts := make(map[string]int)
+	letters := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.Is(unicode.Latin, r):
+			scripts["latin"]++
+		case unicode.Is(unicode.Cyrillic, r):
+			scripts["cyrillic"]++
+		default:
+			for _, script := range scriptLanguages {
+				if unicode.Is(script.script, r) {
+					scripts[script.language]++
+					break
+				}
+			}
+		}
+	}
+	if letters == 0 {
+		return nil
+	}
+	// -- Japanese mixes kana with Han characters, Chinese has no kana
+	if scripts["ja"] > 0 && scripts["zh"] > 0 {
+		scripts["ja"] += scripts["zh"]
+		delete(scripts, "zh")
+	}
+
+	var guesses []LanguageGuess
+	for script, count := range scripts {
+		share := float64(count) / float64(letters)
+		switch script {
+		case "latin":
+			guesses = append(guesses, d.rankTrigrams(text, latinProfiles, share)...)
+		case "cyrillic":
+			guesses = append(guesses, d.rankTrigrams(text, cyrillicProfiles, share)...)
+		default:
+			if d.allows(script) {
+				guesses = append(guesses, LanguageGuess{Language: script, Confidence: share})
+			}
+		}
+	}
+	sort.SliceStable(guesses, func(i, j int) bool {
+		if guesses[i].Confidence == guesses[j].Confidence {
+			return guesses[i].Language < guesses[j].Language
+		}
+		return guesses[i].Confidence > guesses[j].Confidence
+	})
+	return guesses
+}
+
+// Scores the languages of a script with naive Bayes over the text's
+// trigrams, share is the part of the text's letters in the script
+func (d *LanguageDetector) rankTrigrams(text string, profiles []*languageProfile, share float64) []LanguageGuess {
+	trigrams := textTrigrams(text, maxLanguageTrigrams)
+	var candidates []*languageProfile
+	for _, profile := range profiles {
+		if d.allows(profile.language) {
+			candidates = append(candidates, profile)
+		}
+	}
+	if len(candidates) == 0 || len(trigrams) == 0 {
+		return nil
+	}
+
+	scores := make([]float64, len(candidates))
+	best := math.Inf(-1)
+	for i, profile := range candidates {
+		for _, trigram := range trigrams {
+			logProb, ok := profile.logProbs[trigram]
+			if !ok {
+				logProb = profile.unseen
+			}
+			scores[i] += logProb * trigramEvidenceWeight
+		}
+		best = math.Max(best, scores[i])
+	}
+	total := 0.0
+	for i := range scores {
+		scores[i] = math.Exp(scores[i] - best)
+		total += scores[i]
+	}
+	guesses := make([]LanguageGuess, len(candidates))
+	for i, profile := range candidates {
+		guesses[i] = LanguageGuess{Language: profile.language, Confidence: scores[i] / total * share}
+	}
+	return guesses
+}
+
+func (d *LanguageDetector) allows(language string) bool {
+	return len(d.Languages) == 0 || containsString(d.Languages, language)
+}
+
+// Returns the ISO 639-1 part of a language tag, "en-US" is "en"
+func primaryLanguage(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	return tag
+}
+
+// Checks a declared language against the one detected in text.
+// A missing declared language is filled in when the detector is
+// confident. A declared language is only replaced when the detector
+// can detect it and is confident the text is in another language. A
+// language without a profile is always kept, the detector can only
+// mistake it for one of the languages it knows.
+// Returns the language to use and the confidence in it.
+func (d *LanguageDetector) verify(declared string, text string) (string, float64) {
+	logging := d.Logger
+	primary := primaryLanguage(declared)
+	if primary != "" && (!containsString(SupportedLanguages(), primary) || !d.allows(primary)) {
+		return declared, 0
+	}
+	guesses := d.Rank(text)
+	if len(guesses) == 0 {
+		return declared, 0
+	}
+	if primary != "" {
+		for _, guess := range guesses {
+			if guess.Language == primary && (guess == guesses[0] || guesses[0].Confidence < d.MinConfidence) {
+				return declared, guess.Confidence
+			}
+		}
+	}
+	if guesses[0].Confidence < d.MinConfidence {
+		return declared, 0
+	}
+	if declared != "" {
+		logging.Warning("LanguageDetector.verify() replacing language %s with detected %s", declared, guesses[0].Language)
+	}
+	return guesses[0].Language, guesses[0].Confidence
+}
+
+// DetectLanguage fills in or corrects the Article's InLanguage
+// from its Headline and ArticleBody
+func (a *Article) DetectLanguage(detector *LanguageDetector) {
+	logging := a.Logger
+	logging.Debug("Article.DetectLanguage() was called")
+	a.InLanguage, a.LanguageConfidence = detector.verify(a.InLanguage, a.Headline+"\n"+a.ArticleBody)
+}
+
+// DetectLanguage fills in or corrects the Product's InLanguage
+// from its Name and Description
+func (p *Product) DetectLanguage(detector *LanguageDetector) {
+	logging := p.Logger
+	logging.Debug("Product.DetectLanguage() was called")
+	p.InLanguage, p.LanguageConfidence = detector.verify(p.InLanguage, p.Name+"\n"+p.Description)
+}
+
+// Matches a language tag with any region, "en" matches "en" and "en-US"
+func languageFilter(language string) primitive.Regex {
+	return primitive.Regex{Pattern: "^" + regexp.QuoteMeta(primaryLanguage(language)) + "([-_]|$)", Options: "i"}
+}
+
+// ArticlesInLanguage returns the Articles in a language, given as
+// an ISO 639-1 code. Articles tagged with a region are included.
+func ArticlesInLanguage(logging logging.Logger, language string) ([]*Article, error) {
+	article := NewArticle(NewArticleWithLogger(logging))
+	docs, err := article.Query("inLanguage", languageFilter(language))
+	if err != nil {
+		return nil, err
+	}
+	articles := make([]*Article, 0, len(docs))
+	for _, doc := range docs {
+		found := doc.(*Article)
+		found.Logger = &logging
+		if err := found.markLoaded(); err != nil {
+			return nil, err
+		}
+		articles = append(articles, found)
+	}
+	logging.Info("ArticlesInLanguage() Found %d Articles in %s", len(articles), language)
+	return articles, nil
+}
+
+// ProductsInLanguage returns the Products in a language, given as
+// an ISO 639-1 code. Products tagged with a region are included.
+func ProductsInLanguage(logging logging.Logger, language string) ([]*Product, error) {
+	product := NewProduct(NewProductWithLogger(logging))
+	docs, err := product.Query("inLanguage", languageFilter(language))
+	if err != nil {
+		return nil, err
+	}
+	products := make([]*Product, 0, len(docs))
+	for _, doc := range docs {
+		found := doc.(*Product)
+		found.Logger = &logging
+		if err := found.markLoaded(); err != nil {
+			return nil, err
+		}
+		products = append(products, found)
+	}
+	logging.Info("ProductsInLanguage() Found %d Products in %s", len(products), language)
+	return products, nil
+}
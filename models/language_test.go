@@ -0,0 +1,41 @@
+package models
+
+import "testing"
+
+func TestLanguageDetectorVerify(t *testing.T) {
+	const (
+		english = "The city council approved the new budget on Tuesday after a long debate about schools, roads and the future of public transport in the region."
+		german  = "Der Stadtrat hat am Dienstag nach einer langen Debatte über Schulen, Straßen und die Zukunft des öffentlichen Verkehrs in der Region den neuen Haushalt beschlossen."
+		finnish = "Kaupunginvaltuusto hyväksyi tiistaina uuden talousarvion pitkän keskustelun jälkeen kouluista, teistä ja joukkoliikenteen tulevaisuudesta alueella."
+		danish  = "Byrådet vedtog tirsdag det nye budget efter en lang debat om skoler, veje og fremtiden for den offentlige transport i regionen."
+		czech   = "Městské zastupitelstvo v úterý schválilo nový rozpočet po dlouhé debatě o školách, silnicích a budoucnosti veřejné dopravy v regionu."
+	)
+	tests := []struct {
+		declared string
+		text     string
+		want     string
+	}{
+		// -- Languages without a profile are kept
+		{"fi", finnish, "fi"},
+		{"da", danish, "da"},
+		{"cs", czech, "cs"},
+		{"da-DK", danish, "da-DK"},
+		// -- Supported languages are kept unless they clearly lose
+		{"en", english, "en"},
+		{"en-US", english, "en-US"},
+		{"de", german, "de"},
+		{"de", english, "en"},
+		{"en", german, "de"},
+		// -- Only a missing language is filled in
+		{"", english, "en"},
+		{"", german, "de"},
+		{"", "", ""},
+		{"en", "", "en"},
+	}
+	detector := NewLanguageDetector()
+	for _, tt := range tests {
+		if got, _ := detector.verify(tt.declared, tt.text); got != tt.want {
+			t.Errorf("verify(%q, %.20q...) = %q, want %q", tt.declared, tt.text, got, tt.want)
+		}
+	}
+}
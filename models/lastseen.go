@@ -0,0 +1,473 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/chuxorg/chux-datastore/db"
+	dbl "github.com/chuxorg/chux-datastore/logging"
+	"github.com/chuxorg/chux-models/errors"
+	"github.com/chuxorg/chux-models/logging"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CrawlRun is one crawl of a company's site. Products saved during
+// the run are given its RunID, see NewProductWithCrawlRun.
+type CrawlRun struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	RunID         string             `bson:"runId" json:"runId"`
+	CompanyName   string             `bson:"companyName" json:"companyName"`
+	StartedAt     CustomTime         `bson:"startedAt" json:"startedAt"`
+	FinishedAt    CustomTime         `bson:"finishedAt,omitempty" json:"finishedAt,omitempty"`
+	DateCreated   CustomTime         `bson:"dateCreated,omitempty" json:"dateCreated,omitempty"`
+	DateModified  CustomTime         `bson:"dateModified,omitempty" json:"dateModified,omitempty"`
+	isNew         bool               `bson:"-" json:"-"`
+	isDeleted     bool               `bson:"-" json:"-"`
+	isDirty       bool               `bson:"-" json:"-"`
+	originalState *CrawlRun          `bson:"-" json:"-"`
+	timeConfig    *CustomTimeConfig  `bson:"-" json:"-"`
+	Logger        *logging.Logger    `bson:"-" json:"-"`
+}
+
+func NewCrawlRun(options ...func(*CrawlRun)) *CrawlRun {
+
+	r := &CrawlRun{}
+
+	for _, option := range options {
+		option(r)
+	}
+	dbLogger := dbl.NewLogger(dbl.LogLevelDebug)
+	mongoDB = db.New(
+		db.WithURI(r.GetURI()),
+		db.WithDatabaseName(r.GetDatabaseName()),
+		db.WithCollectionName(r.GetCollectionName()),
+		db.WithTimeout(30),
+		db.WithLogger(*dbLogger),
+	)
+
+	r.isNew = true
+	r.isDeleted = false
+	r.isDirty = false
+	return r
+}
+
+func NewCrawlRunWithLogger(logger logging.Logger) func(*CrawlRun) {
+	return func(r *CrawlRun) {
+		r.Logger = &logger
+	}
+}
+
+// Sets how the CrawlRun's CustomTimes are written to JSON and stored in Mongo,
+// without it they use the DefaultCustomTimeConfig
+func NewCrawlRunWithTimeConfig(config CustomTimeConfig) func(*CrawlRun) {
+	return func(r *CrawlRun) {
+		r.timeConfig = &config
+	}
+}
+
+func (r *CrawlRun) GetCollectionName() string {
+	logging := r.Logger
+	logging.Debug("CrawlRun.GetCollectionName() was called")
+	return "crawlRuns"
+}
+
+func (r *CrawlRun) GetDatabaseName() string {
+	logging := r.Logger
+	logging.Debug("CrawlRun.GetDatabaseName() was called")
+	return os.Getenv("MONGO_DATABASE")
+}
+
+func (r *CrawlRun) GetURI() string {
+	logging := r.Logger
+	logging.Debug("CrawlRun.GetURI() was called")
+	username := os.Getenv("MONGO_USER_NAME")
+	password := os.Getenv("MONGO_PASSWORD")
+
+	uri := os.Getenv("MONGO_URI")
+	mongoURI := fmt.Sprintf(uri, username, password)
+	masked := fmt.Sprintf(uri, "********", "********")
+	logging.Info("Mongo URI: %s", masked)
+	return mongoURI
+}
+
+func (r *CrawlRun) GetID() primitive.ObjectID {
+	logging := r.Logger
+	logging.Debug("CrawlRun.GetID() was called")
+	return r.ID
+}
+
+func (r *CrawlRun) SetID(id primitive.ObjectID) {
+	logging := r.Logger
+	logging.Debug("CrawlRun.SetID() was called")
+	r.ID = id
+}
+
+// If the Model has changes, will return true
+func (r *CrawlRun) IsDirty() bool {
+	logging := r.Logger
+	logging.Debug("CrawlRun.IsDirty() was called")
+	if r.originalState == nil {
+		return false
+	}
+
+	originalBytes, err := r.originalState.Serialize()
+	if err != nil {
+		return false
+	}
+
+	currentBytes, err := r.Serialize()
+	if err != nil {
+		return false
+	}
+
+	r.isDirty = string(originalBytes) != string(currentBytes)
+	logging.Info("CrawlRun.IsDirty() isDirty: %t", r.isDirty)
+	return r.isDirty
+}
+
+// When the Model is first created,
+// the model is considered New. After the model is
+// Saved or Loaded it is no longer New
+func (r *CrawlRun) IsNew() bool {
+	logging := r.Logger
+	logging.Debug("CrawlRun.IsNew() was called")
+	return r.isNew
+}
+
+// Saves the Model to a Data Store
+func (r *CrawlRun) Save() error {
+	logging := r.Logger
+	logging.Debug("CrawlRun.Save() was called")
+	applyTimeConfig(r.timeConfig, r)
+	if r.isNew {
+		logging.Debug("CrawlRun.Save() CrawlRun is new")
+		// -- Set the date created to now
+		r.DateCreated.Now()
+		if r.RunID == "" {
+			r.RunID = primitive.NewObjectID().Hex()
+		}
+		if r.StartedAt.IsZero() {
+			r.StartedAt.Now()
+		}
+		//-- Upsert document, a CrawlRun is unique by its RunID
+		err := mongoDB.Upsert(r, "runId")
+		if err != nil {
+			logging.Error("CrawlRun.Save() Error creating CrawlRun in MongoDB: %s", err.Error())
+			return errors.NewChuxModelsError("CrawlRun.Save() Error creating CrawlRun in MongoDB", err)
+		}
+	} else if r.IsDirty() && !r.isDeleted {
+		logging.Debug("CrawlRun.Save() CrawlRun is dirty")
+		// Ensure the ID is a valid hex string representation of an ObjectID
+		_, err := primitive.ObjectIDFromHex(r.ID.Hex())
+		if err != nil {
+			logging.Error("CrawlRun.Save() invalid ObjectID: %s", err.Error())
+			return errors.NewChuxModelsError("CrawlRun.Save() invalid ObjectID", err)
+		}
+		// -- Set the date modified to now
+		r.DateModified.Now()
+		//--update this document
+		err = mongoDB.Update(r, r.ID.Hex())
+		if err != nil {
+			logging.Error("CrawlRun.Save() Error updating CrawlRun in MongoDB: %s", err.Error())
+			return errors.NewChuxModelsError("CrawlRun.Save() Error updating CrawlRun in MongoDB", err)
+		}
+	} else if r.isDeleted && !r.isNew {
+		logging.Info("CrawlRun.Save() CrawlRun is deleted")
+		//--delete the document
+		err := mongoDB.Delete(r, r.ID.Hex())
+		if err != nil {
+			logging.Error("CrawlRun.Save() Error deleting CrawlRun in MongoDB: %s", err.Error())
+			return errors.NewChuxModelsError("CrawlRun.Save() Error deleting CrawlRun in MongoDB", err)
+		}
+	}
+
+	// If the CrawlRun has been deleted, then this is a new CrawlRun
+	r.isNew = r.isDeleted
+	r.isDirty = r.IsDirty()
+	r.isDeleted = false
+
+	if r.isNew {
+		r.originalState = nil
+	} else {
+		//--reset state
+		serialized, err := r.Serialize()
+		if err != nil {
+			logging.Error("CrawlRun.Save() Error serializing CrawlRun: %s", err.Error())
+			return errors.NewChuxModelsError("CrawlRun.Save() Error serializing CrawlRun.", err)
+		}
+		r.SetState(serialized)
+	}
+
+	logging.Info("CrawlRun.Save() CrawlRun saved successfully")
+	return nil
+}
+
+// Loads a Model from MongoDB by id
+func (r *CrawlRun) Load(id string) (interface{}, error) {
+	logging := r.Logger
+	logging.Debug("CrawlRun.Load() was called")
+
+	retVal, err := mongoDB.GetByID(r, id)
+	if err != nil {
+		logging.Error("CrawlRun.Load() Error loading CrawlRun from MongoDB: %s", err.Error())
+		return nil, errors.NewChuxModelsError("CrawlRun.Load() Error loading CrawlRun from MongoDB", err)
+	}
+	run, ok := retVal.(*CrawlRun)
+	if !ok {
+		logging.Error("CrawlRun.Load() unable to cast retVal to *CrawlRun")
+		return nil, errors.NewChuxModelsError("CrawlRun.Load() unable to cast retVal to *CrawlRun", nil)
+	}
+	err = run.markLoaded()
+	if err != nil {
+		logging.Error("CrawlRun.Load() Error setting state: %s", err.Error())
+		return nil, errors.NewChuxModelsError("CrawlRun.Load() Error setting state", err)
+	}
+	logging.Info("CrawlRun.Load() CrawlRun loaded successfully")
+	return retVal, nil
+}
+
+func (r *CrawlRun) Query(args ...interface{}) ([]db.IMongoDocument, error) {
+	logging := r.Logger
+	logging.Debug("CrawlRun.Query() was called")
+
+	results, err := mongoDB.Query(r, args...)
+	if err != nil {
+		logging.Error("CrawlRun.Query() Error occurred querying CrawlRuns: %s", err.Error())
+		return nil, errors.NewChuxModelsError("CrawlRun.Query() Error occurred querying CrawlRuns", err)
+	}
+	logging.Info("CrawlRun.Query() CrawlRuns queried successfully")
+	return results, nil
+}
+
+// Marks a Model for deletion from the Data Store
+// when Save() is called, the Model will be deleted
+func (r *CrawlRun) Delete() error {
+	logging := r.Logger
+	logging.Debug("CrawlRun.Delete() was called")
+	r.isDeleted = true
+	return nil
+}
+
+// Sets the internal state of the model.
+func (r *CrawlRun) SetState(json string) error {
+	logging := r.Logger
+	logging.Debug("CrawlRun.SetState() was called")
+	// Store the current state as the original state
+	original := &CrawlRun{}
+	*original = *r
+	r.originalState = original
+
+	// Deserialize the new state
+	return r.Deserialize([]byte(json))
+}
+
+// Marks a CrawlRun returned by Query() as loaded so that
+// changes made to it are persisted by Save()
+func (r *CrawlRun) markLoaded() error {
+	serialized, err := r.Serialize()
+	if err != nil {
+		return errors.NewChuxModelsError("CrawlRun.markLoaded() Error serializing CrawlRun", err)
+	}
+	r.SetState(serialized)
+	r.isNew = false
+	r.isDirty = false
+	r.isDeleted = false
+	return nil
+}
+
+// Sets the internal state of the model of a new CrawlRun
+// from a JSON String.
+func (r *CrawlRun) Parse(json string) error {
+	logging := r.Logger
+	logging.Debug("CrawlRun.Parse() was called")
+	err := r.SetState(json)
+	if err != nil {
+		logging.Error("CrawlRun.Parse() error setting state")
+		return errors.NewChuxModelsError("CrawlRun.Parse() Error setting state", err)
+	}
+	r.isNew = true // this is a new model
+	return nil
+}
+
+func (r *CrawlRun) Search(args ...interface{}) ([]interface{}, error) {
+	logging := r.Logger
+	logging.Debug("CrawlRun.Search() was called")
+	return nil, nil
+}
+
+func (r *CrawlRun) Serialize() (string, error) {
+	logging := r.Logger
+	logging.Debug("CrawlRun.Serialize() was called")
+	applyTimeConfig(r.timeConfig, r)
+	bytes, err := json.Marshal(r)
+	if err != nil {
+		logging.Error("CrawlRun.Serialize() error occurred: %s", err.Error())
+		return "", errors.NewChuxModelsError("CrawlRun.Serialize() error occurred", err)
+	}
+	return string(bytes), nil
+}
+
+func (r *CrawlRun) Deserialize(jsonData []byte) error {
+	logging := r.Logger
+	logging.Debug("CrawlRun.Deserialize() was called")
+	applyTimeConfig(r.timeConfig, r)
+	err := json.Unmarshal(jsonData, r)
+	if err != nil {
+		logging.Error("CrawlRun.Deserialize() error occurred: %s", err.Error())
+		return errors.NewChuxModelsError("CrawlRun.Deserialize() error occurred", err)
+	}
+	return nil
+}
+
+// StartCrawlRun stores a new CrawlRun of a company
+func StartCrawlRun(logging logging.Logger, companyName string) (*CrawlRun, error) {
+	run := NewCrawlRun(NewCrawlRunWithLogger(logging))
+	run.CompanyName = companyName
+	if err := run.Save(); err != nil {
+		return nil, err
+	}
+	logging.Info("StartCrawlRun() Started crawl run %s of %s", run.RunID, companyName)
+	return run, nil
+}
+
+// Finish records the time the CrawlRun finished
+func (r *CrawlRun) Finish() error {
+	logging := r.Logger
+	logging.Debug("CrawlRun.Finish() was called")
+	r.FinishedAt.Now()
+	return r.Save()
+}
+
+// DiscontinuePolicy sets when an unseen Product is marked Discontinued.
+// A limit of 0 is not applied.
+type DiscontinuePolicy struct {
+	// Days since the Product was last seen
+	MaxDaysUnseen int `json:"maxDaysUnseen"`
+	// Crawl runs of the Product's company that did not see it
+	MaxRunsMissed int `json:"maxRunsMissed"`
+}
+
+// ReconcileResult counts the Products a reconciliation changed
+type ReconcileResult struct {
+	Checked      int `json:"checked"`
+	Discontinued int `json:"discontinued"`
+	Failed       int `json:"failed"`
+}
+
+// Sets FirstSeen, LastSeen and SeenCount of a Product that is about to be
+// upserted, carrying them over from the stored Product with the same
+// canonical URL. A Discontinued Product that is seen again is reinstated.
+func (p *Product) markSeen() error {
+	logging := p.Logger
+	logging.Debug("Product.markSeen() was called")
+	p.LastSeen.Now()
+	now := p.LastSeen.Time
+	if p.crawlRun != nil {
+		p.LastCrawlRun = p.crawlRun.RunID
+	}
+
+	docs, err := mongoDB.Query(p, "canonicalUrl", p.CanonicalURL)
+	if err != nil {
+		logging.Error("Product.markSeen() Error querying Product: %s", err.Error())
+		return errors.NewChuxModelsError("Product.markSeen() Error querying Product", err)
+	}
+	if len(docs) == 0 {
+		p.FirstSeen.Time = now
+		p.SeenCount = 1
+		return nil
+	}
+
+	stored := docs[0].(*Product)
+	p.FirstSeen = stored.FirstSeen
+	if p.FirstSeen.IsZero() {
+		p.FirstSeen = stored.DateCreated
+	}
+	p.SeenCount = stored.SeenCount + 1
+	if stored.Discontinued {
+		logging.Info("Product.markSeen() Product %s reappeared and is reinstated", p.CanonicalURL)
+	}
+	p.Discontinued = false
+	p.DiscontinuedAt = CustomTime{}
+	return nil
+}
+
+// ShouldDiscontinue returns true when the Product has not been seen for
+// longer than the policy allows. runs are the CrawlRuns of its company,
+// only finished runs count as missed. Runs that are still in progress,
+// or were aborted and never finished, may not have reached the Product.
+func (p *Product) ShouldDiscontinue(policy DiscontinuePolicy, runs []*CrawlRun, now time.Time) bool {
+	if p.Discontinued || p.LastSeen.IsZero() {
+		return false
+	}
+	if policy.MaxDaysUnseen > 0 && now.Sub(p.LastSeen.Time) > time.Duration(policy.MaxDaysUnseen)*24*time.Hour {
+		return true
+	}
+	if policy.MaxRunsMissed > 0 {
+		missed := 0
+		for _, run := range runs {
+			if !run.FinishedAt.IsZero() && run.RunID != p.LastCrawlRun && run.StartedAt.After(p.LastSeen.Time) {
+				missed++
+			}
+		}
+		if missed >= policy.MaxRunsMissed {
+			return true
+		}
+	}
+	return false
+}
+
+// ReconcileDiscontinued marks the Products of a company Discontinued
+// when they have not been seen within the policy. Products that are
+// seen again are reinstated when they are saved.
+func ReconcileDiscontinued(logging logging.Logger, companyName string, policy DiscontinuePolicy) (*ReconcileResult, error) {
+	run := NewCrawlRun(NewCrawlRunWithLogger(logging))
+	runDocs, err := run.Query("companyName", companyName)
+	if err != nil {
+		return nil, err
+	}
+	// -- Only finished runs, a run in progress has not reached every Product yet
+	runs := make([]*CrawlRun, 0, len(runDocs))
+	for _, doc := range runDocs {
+		if found := doc.(*CrawlRun); !found.FinishedAt.IsZero() {
+			runs = append(runs, found)
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].StartedAt.Before(runs[j].StartedAt.Time)
+	})
+
+	prd := NewProduct(NewProductWithLogger(logging))
+	docs, err := prd.Query("companyName", companyName, "discontinued", bson.M{"$ne": true})
+	if err != nil {
+		return nil, err
+	}
+	result := &ReconcileResult{}
+	now := time.Now()
+	for _, doc := range docs {
+		product := doc.(*Product)
+		product.Logger = &logging
+		result.Checked++
+		if !product.ShouldDiscontinue(policy, runs, now) {
+			continue
+		}
+		if err := product.markLoaded(); err != nil {
+			result.Failed++
+			continue
+		}
+		product.Discontinued = true
+		product.DiscontinuedAt.Now()
+		if err := product.Save(); err != nil {
+			logging.Error("ReconcileDiscontinued() Error saving Product %s: %s", product.ID.Hex(), err.Error())
+			result.Failed++
+			continue
+		}
+		result.Discontinued++
+	}
+	logging.Info("ReconcileDiscontinued() Checked %d products of %s, %d discontinued, %d failed",
+		result.Checked, companyName, result.Discontinued, result.Failed)
+	return result, nil
+}
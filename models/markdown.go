@@ -0,0 +1,479 @@
+package models
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Text that would start a heading, quote, list or thematic break at the start of a line
+var markdownLineStartRegex = regexp.MustCompile(`^(#|>|-|\+|=|\d+[.)])`)
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `[`, `\[`, `]`, `\]`, `<`, `\<`,
+)
+
+// A block of Markdown and whether it is a list
+type markdownBlock struct {
+	text string
+	list bool
+}
+
+// Converts HTML to Markdown, resolving links and images against base
+type markdownConverter struct {
+	base *url.URL
+}
+
+// HTMLToMarkdown converts an HTML fragment to CommonMark. Headings,
+// emphasis, lists, blockquotes, links, images, tables and code are
+// converted, relative links and images are resolved against baseURL.
+// Tables are written as pipe tables.
+func HTMLToMarkdown(fragment string, baseURL string) string {
+	if strings.TrimSpace(fragment) == "" {
+		return ""
+	}
+	nodes, err := parseHTMLFragment(fragment)
+	if err != nil {
+		return strings.TrimSpace(fragment)
+	}
+	m := &markdownConverter{}
+	if base, err := url.Parse(strings.TrimSpace(baseURL)); err == nil && base.IsAbs() {
+		m.base = base
+	}
+	return joinMarkdownBlocks(m.blocks(nodes), false)
+}
+
+// Joins blocks with a blank line between them. In a tight list item
+// a list that follows text starts on the next line instead.
+func joinMarkdownBlocks(blocks []markdownBlock, tight bool) string {
+	var b strings.Builder
+	for i, block := range blocks {
+		if i > 0 {
+			if tight && block.list && !blocks[i-1].list {
+				b.WriteString("\n")
+			} else {
+				b.WriteString("\n\n")
+			}
+		}
+		b.WriteString(block.text)
+	}
+	return b.String()
+}
+
+func childNodes(n *html.Node) []*html.Node {
+	var children []*html.Node
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		children = append(children, child)
+	}
+	return children
+}
+
+func isMarkdownBlock(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	switch n.DataAtom {
+	case atom.Li, atom.Dt, atom.Dd, atom.Figcaption:
+		return true
+	}
+	return blockElements[n.DataAtom]
+}
+
+// Converts nodes to blocks, runs of text and inline elements between
+// block elements become paragraphs
+func (m *markdownConverter) blocks(nodes []*html.Node) []markdownBlock {
+	var blocks []markdownBlock
+	var inline strings.Builder
+	flush := func() {
+		if paragraph := markdownParagraph(inline.String()); paragraph != "" {
+			blocks = append(blocks, markdownBlock{text: paragraph})
+		}
+		inline.Reset()
+	}
+	for _, n := range nodes {
+		if !isMarkdownBlock(n) {
+			inline.WriteString(m.inline(n))
+			continue
+		}
+		flush()
+		if droppedElements[n.DataAtom] {
+			continue
+		}
+		switch n.DataAtom {
+		case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+			if text := markdownLine(m.inlineChildren(n)); text != "" {
+				level := int(n.Data[1] - '0')
+				blocks = append(blocks, markdownBlock{text: strings.Repeat("#", level) + " " + text})
+			}
+		case atom.Hr:
+			blocks = append(blocks, markdownBlock{text: "---"})
+		case atom.Pre:
+			blocks = append(blocks, markdownBlock{text: codeBlock(n)})
+		case atom.Ul, atom.Ol:
+			if list := m.list(n); list != "" {
+				blocks = append(blocks, markdownBlock{text: list, list: true})
+			}
+		case atom.Blockquote:
+			if quote := joinMarkdownBlocks(m.blocks(childNodes(n)), false); quote != "" {
+				blocks = append(blocks, markdownBlock{text: prefixLines(quote, "> ", ">")})
+			}
+		case atom.Table:
+			blocks = append(blocks, m.table(n)...)
+		default:
+			blocks = append(blocks, m.blocks(childNodes(n))...)
+		}
+	}
+	flush()
+	return blocks
+}
+
+func (m *markdownConverter) inlineChildren(n *html.Node) string {
+	var b strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		b.WriteString(m.inline(child))
+	}
+	return b.String()
+}
+
+func (m *markdownConverter) inline(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return markdownEscaper.Replace(collapseSpace(n.Data))
+	case html.ElementNode:
+	default:
+		return ""
+	}
+	if droppedElements[n.DataAtom] {
+		return ""
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		return "\\\n"
+	case atom.Img:
+		return m.image(n)
+	case atom.A:
+		return m.link(n)
+	case atom.Code, atom.Kbd, atom.Samp, atom.Tt:
+		return codeSpan(nodeText(n))
+	case atom.Strong, atom.B:
+		return emphasis(m.inlineChildren(n), "**")
+	case atom.Em, atom.I:
+		return emphasis(m.inlineChildren(n), "*")
+	}
+	if isMarkdownBlock(n) {
+		// -- A block inside an inline element, e.g. a div in a link, is kept on the line
+		return " " + m.inlineChildren(n) + " "
+	}
+	return m.inlineChildren(n)
+}
+
+// Resolves a link or image URL against the base URL, returning false
+// when it is not safe to render
+func (m *markdownConverter) resolve(ref string, link bool) (string, bool) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return "", false
+	}
+	parsed, err := url.Parse(ref)
+	if err != nil {
+		return "", false
+	}
+	if m.base != nil {
+		parsed = m.base.ResolveReference(parsed)
+	}
+	return safeURL(parsed.String(), link)
+}
+
+func (m *markdownConverter) link(n *html.Node) string {
+	inner := m.inlineChildren(n)
+	text := strings.TrimSpace(inner)
+	href, ok := m.resolve(attrValue(n, "href"), true)
+	if !ok || text == "" {
+		return inner
+	}
+	return keepSpace(inner, "["+text+"]("+markdownDestination(href)+markdownTitle(attrValue(n, "title"))+")")
+}
+
+func (m *markdownConverter) image(n *html.Node) string {
+	if isTrackingPixel(n) {
+		return ""
+	}
+	src, ok := m.resolve(attrValue(n, "src"), false)
+	if !ok {
+		return ""
+	}
+	alt := markdownEscaper.Replace(strings.TrimSpace(collapseSpace(attrValue(n, "alt"))))
+	return "![" + alt + "](" + markdownDestination(src) + markdownTitle(attrValue(n, "title")) + ")"
+}
+
+// Writes a list, items are indented under their marker so that
+// their paragraphs, code and nested lists stay inside them
+func (m *markdownConverter) list(n *html.Node) string {
+	var items []string
+	for _, child := range childNodes(n) {
+		if child.Type != html.ElementNode {
+			continue
+		}
+		if child.DataAtom != atom.Li {
+			// -- A list nested directly in a list belongs to the item before it
+			content := joinMarkdownBlocks(m.blocks([]*html.Node{child}), false)
+			if content == "" {
+				continue
+			}
+			if len(items) == 0 {
+				items = append(items, content)
+			} else {
+				indent := strings.Repeat(" ", strings.Index(items[len(items)-1], " ")+1)
+				items[len(items)-1] += "\n" + prefixLines(content, indent, "")
+			}
+			continue
+		}
+		marker := "-"
+		if n.DataAtom == atom.Ol {
+			marker = listMarker(child)
+		}
+		content := joinMarkdownBlocks(m.blocks(childNodes(child)), true)
+		indent := strings.Repeat(" ", len(marker)+1)
+		lines := strings.SplitN(content, "\n", 2)
+		item := marker + " " + lines[0]
+		if len(lines) > 1 {
+			item += "\n" + prefixLines(lines[1], indent, "")
+		}
+		items = append(items, strings.TrimRight(item, " "))
+	}
+	return strings.Join(items, "\n")
+}
+
+// Writes a table as a pipe table with its first row as the header.
+// A caption is written as a paragraph before the table.
+func (m *markdownConverter) table(n *html.Node) []markdownBlock {
+	var blocks []markdownBlock
+	var rows [][]string
+	var addRows func(*html.Node)
+	addRows = func(parent *html.Node) {
+		for _, child := range childNodes(parent) {
+			if child.Type != html.ElementNode {
+				continue
+			}
+			switch child.DataAtom {
+			case atom.Caption:
+				if caption := markdownParagraph(m.inlineChildren(child)); caption != "" {
+					blocks = append(blocks, markdownBlock{text: caption})
+				}
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				addRows(child)
+			case atom.Tr:
+				rows = append(rows, m.tableRow(child))
+			}
+		}
+	}
+	addRows(n)
+
+	columns := 0
+	for _, row := range rows {
+		columns = maxInt(columns, len(row))
+	}
+	if columns == 0 {
+		return blocks
+	}
+	var b strings.Builder
+	for i, row := range rows {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		for len(row) < columns {
+			row = append(row, "")
+		}
+		b.WriteString("| " + strings.Join(row, " | ") + " |")
+		if i == 0 {
+			b.WriteString("\n|" + strings.Repeat(" --- |", columns))
+		}
+	}
+	return append(blocks, markdownBlock{text: b.String()})
+}
+
+// The most columns a table row is written with, the limit HTML
+// puts on a colspan. Scraped spans can be any number.
+const maxTableColumns = 1000
+
+func (m *markdownConverter) tableRow(tr *html.Node) []string {
+	var cells []string
+	for _, cell := range childNodes(tr) {
+		if len(cells) >= maxTableColumns {
+			break
+		}
+		if cell.Type != html.ElementNode || (cell.DataAtom != atom.Td && cell.DataAtom != atom.Th) {
+			continue
+		}
+		text := strings.ReplaceAll(markdownLine(m.inlineChildren(cell)), "|", `\|`)
+		cells = append(cells, text)
+		// -- Spanned columns are left empty so the columns stay aligned
+		if span, err := strconv.Atoi(attrValue(cell, "colspan")); err == nil {
+			for ; span > 1 && len(cells) < maxTableColumns; span-- {
+				cells = append(cells, "")
+			}
+		}
+	}
+	return cells
+}
+
+// Writes a fenced code block, the fence is longer than any run of
+// backticks in the code and the language is read from a language-
+// or lang- class on the pre or code element
+func codeBlock(pre *html.Node) string {
+	code := strings.TrimRight(nodeText(pre), "\n")
+	language := codeLanguage(pre)
+	for child := pre.FirstChild; child != nil && language == ""; child = child.NextSibling {
+		if child.Type == html.ElementNode && child.DataAtom == atom.Code {
+			language = codeLanguage(child)
+		}
+	}
+	fence := strings.Repeat("`", maxInt(3, longestRun(code, '`')+1))
+	return fence + language + "\n" + code + "\n" + fence
+}
+
+func codeLanguage(n *html.Node) string {
+	for _, class := range strings.Fields(attrValue(n, "class")) {
+		for _, prefix := range []string{"language-", "lang-"} {
+			if strings.HasPrefix(class, prefix) && len(class) > len(prefix) {
+				return strings.TrimPrefix(class, prefix)
+			}
+		}
+	}
+	return ""
+}
+
+// Writes a code span, padded with spaces when the code starts or ends
+// with a backtick so that the fence can be told apart from the code
+func codeSpan(code string) string {
+	code = strings.ReplaceAll(code, "\n", " ")
+	if strings.TrimSpace(code) == "" {
+		return ""
+	}
+	fence := strings.Repeat("`", longestRun(code, '`')+1)
+	pad := ""
+	if strings.HasPrefix(code, "`") || strings.HasSuffix(code, "`") {
+		pad = " "
+	}
+	return fence + pad + code + pad + fence
+}
+
+// Wraps text in an emphasis marker, spaces around the text are kept
+// outside of the markers since CommonMark does not allow them inside
+func emphasis(inner string, marker string) string {
+	text := strings.TrimSpace(inner)
+	if text == "" {
+		return inner
+	}
+	return keepSpace(inner, marker+text+marker)
+}
+
+// Returns s with the leading and trailing space of inner
+func keepSpace(inner string, s string) string {
+	if strings.HasPrefix(inner, " ") {
+		s = " " + s
+	}
+	if strings.HasSuffix(inner, " ") {
+		s += " "
+	}
+	return s
+}
+
+// Link destinations with spaces or parentheses are percent encoded
+// so that they do not end the link
+func markdownDestination(u string) string {
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29", "<", "%3C", ">", "%3E").Replace(u)
+}
+
+func markdownTitle(title string) string {
+	title = strings.TrimSpace(collapseSpace(title))
+	if title == "" {
+		return ""
+	}
+	return ` "` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(title) + `"`
+}
+
+// Tidies the lines of a paragraph and escapes text at the start of
+// a line that would otherwise be read as a heading, quote or list
+func markdownParagraph(s string) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\\\n") {
+		// -- Hard breaks at the start or end of a paragraph have nothing to break
+		line = strings.Join(strings.Fields(line), " ")
+		if line == "" {
+			continue
+		}
+		lines = append(lines, markdownLineStartRegex.ReplaceAllStringFunc(line, escapeLineStart))
+	}
+	return strings.Join(lines, "\\\n")
+}
+
+func escapeLineStart(s string) string {
+	last := len(s) - 1
+	if last == 0 {
+		return `\` + s
+	}
+	return s[:last] + `\` + s[last:]
+}
+
+// Writes a paragraph on a single line, for headings and table cells
+func markdownLine(s string) string {
+	return markdownParagraph(strings.ReplaceAll(s, "\\\n", " "))
+}
+
+func prefixLines(s string, prefix string, blankPrefix string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = blankPrefix
+		} else {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Collapses runs of whitespace to a single space
+func collapseSpace(s string) string {
+	if s == "" {
+		return ""
+	}
+	collapsed := strings.Join(strings.Fields(s), " ")
+	if isHTMLSpace(s[0]) {
+		collapsed = " " + collapsed
+	}
+	if isHTMLSpace(s[len(s)-1]) && collapsed != " " {
+		collapsed += " "
+	}
+	return collapsed
+}
+
+func longestRun(s string, c byte) int {
+	longest, run := 0, 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == c {
+			run++
+			longest = maxInt(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return longest
+}
+
+// Markdown converts the Article's ArticleBodyHTML to CommonMark with
+// its links and images resolved against the Article's URL
+func (a *Article) Markdown() string {
+	logging := a.Logger
+	logging.Debug("Article.Markdown() was called")
+	base := a.URL
+	if base == "" {
+		base = a.CanonicalURL
+	}
+	return HTMLToMarkdown(a.ArticleBodyHTML, base)
+}
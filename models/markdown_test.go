@@ -0,0 +1,47 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHTMLToMarkdown(t *testing.T) {
+	tests := []struct {
+		fragment string
+		want     string
+	}{
+		{`<h2>Title</h2><p>Some <em>emphasis</em> and <strong>bold</strong>.</p>`, "## Title\n\nSome *emphasis* and **bold**."},
+		{`<ul><li>one</li><li>two</li></ul><ol start="3"><li>three</li></ol>`, "- one\n- two\n\n3. three"},
+		{`<ul><li>a<ul><li>nested</li></ul></li></ul>`, "- a\n  - nested"},
+		{`<p><a href="/story">link</a> <img src="img.png" alt="pic"></p>`, "[link](https://example.com/story) ![pic](https://example.com/news/img.png)"},
+		{`<blockquote><p>quote</p></blockquote><pre><code class="language-go">x := 1</code></pre>`, "> quote\n\n```go\nx := 1\n```"},
+		{`<table><tr><th>A</th><th>B</th></tr><tr><td>1</td><td>2|3</td></tr></table>`, "| A | B |\n| --- | --- |\n| 1 | 2\\|3 |"},
+		{`<p>5 * 3 = 15 and a_b</p><p># not heading</p>`, "5 \\* 3 = 15 and a\\_b\n\n\\# not heading"},
+		{`<p>line<br>break</p><hr><p>after</p>`, "line\\\nbreak\n\n---\n\nafter"},
+		{"  ", ""},
+	}
+	for _, tt := range tests {
+		if got := HTMLToMarkdown(tt.fragment, "https://example.com/news/"); got != tt.want {
+			t.Errorf("HTMLToMarkdown(%q) = %q, want %q", tt.fragment, got, tt.want)
+		}
+	}
+}
+
+func TestHTMLToMarkdownColspan(t *testing.T) {
+	tests := []struct {
+		fragment string
+		columns  int
+	}{
+		{`<table><tr><td colspan="2">a</td><td>b</td></tr></table>`, 3},
+		{`<table><tr><td colspan="1000000000">a</td></tr></table>`, maxTableColumns},
+		{`<table><tr><td colspan="999">a</td><td colspan="999">b</td></tr></table>`, maxTableColumns},
+		{`<table><tr><td colspan="-5">a</td></tr></table>`, 1},
+	}
+	for _, tt := range tests {
+		got := HTMLToMarkdown(tt.fragment, "")
+		header := strings.SplitN(got, "\n", 2)[0]
+		if columns := strings.Count(header, " |"); columns != tt.columns {
+			t.Errorf("HTMLToMarkdown(%q) has %d columns, want %d", tt.fragment, columns, tt.columns)
+		}
+	}
+}
@@ -0,0 +1,579 @@
+package models
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/chuxorg/chux-models/errors"
+	"github.com/chuxorg/chux-models/logging"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The ways two Products can be matched, strongest first
+const (
+	MatchMethodGTIN   = "gtin"
+	MatchMethodMPN    = "brand+mpn"
+	MatchMethodName   = "name"
+	MatchMethodManual = "manual"
+	MatchMethodNone   = ""
+)
+
+// ProductMatch is the result of comparing two Products
+type ProductMatch struct {
+	Method     string
+	Confidence float64
+}
+
+// ProductMatcher links Products of different companies that represent
+// the same item. Products are matched by normalized GTIN, then by
+// brand plus MPN, then by name similarity within the same brand.
+type ProductMatcher struct {
+	// The minimum NameSimilarity for two names to match
+	NameThreshold float64
+	Logger        *logging.Logger
+}
+
+// Creates a NewProductMatcher with Options
+func NewProductMatcher(options ...func(*ProductMatcher)) *ProductMatcher {
+	m := &ProductMatcher{
+		NameThreshold: 0.85,
+	}
+	for _, option := range options {
+		option(m)
+	}
+	return m
+}
+
+func NewProductMatcherWithLogger(logger logging.Logger) func(*ProductMatcher) {
+	return func(m *ProductMatcher) {
+		m.Logger = &logger
+	}
+}
+
+func NewProductMatcherWithNameThreshold(threshold float64) func(*ProductMatcher) {
+	return func(m *ProductMatcher) {
+		m.NameThreshold = threshold
+	}
+}
+
+// Match compares two Products and returns how, and how confidently, they match.
+// Method is MatchMethodNone when they do not match.
+func (m *ProductMatcher) Match(a, b *Product) ProductMatch {
+	gtinsA := a.NormalizedGTINs()
+	for _, gtinB := range b.NormalizedGTINs() {
+		for _, gtinA := range gtinsA {
+			if gtinA == gtinB {
+				return ProductMatch{Method: MatchMethodGTIN, Confidence: 1}
+			}
+		}
+	}
+	// -- Conflicting GTINs mean different items even if the names agree
+	if len(gtinsA) > 0 && len(b.NormalizedGTINs()) > 0 {
+		return ProductMatch{Method: MatchMethodNone}
+	}
+
+	brandA := NormalizeText(a.Brand)
+	brandB := NormalizeText(b.Brand)
+	if brandA != "" && brandA == brandB {
+		mpnA := NormalizeMPN(a.MPN)
+		if mpnA != "" && mpnA == NormalizeMPN(b.MPN) {
+			return ProductMatch{Method: MatchMethodMPN, Confidence: 0.95}
+		}
+	}
+
+	if brandA != "" && brandB != "" && brandA != brandB {
+		return ProductMatch{Method: MatchMethodNone}
+	}
+	score := NameSimilarity(a.Name, b.Name)
+	if score >= m.NameThreshold {
+		return ProductMatch{Method: MatchMethodName, Confidence: 0.9 * score}
+	}
+	return ProductMatch{Method: MatchMethodNone}
+}
+
+// Cluster groups products into ProductClusters. Existing clusters are
+// reused when they share members so cluster ids stay stable, and their
+// confirmed and excluded Products are honored: confirmed Products always
+// stay together and a Product split from a cluster never rejoins it.
+// products is expected to hold every Product to cluster, members of
+// existing clusters that are not in products are dropped.
+func (m *ProductMatcher) Cluster(products []*Product, existing []*ProductCluster) []*ProductCluster {
+	logging := m.Logger
+	logging.Debug("ProductMatcher.Cluster() called")
+
+	index := make(map[primitive.ObjectID]int, len(products))
+	for i, product := range products {
+		index[product.ID] = i
+	}
+
+	set := newDisjointSet(len(products))
+	edges := make([]ProductMatch, len(products))
+	link := func(i, j int, match ProductMatch) {
+		if !set.union(i, j) {
+			return
+		}
+		for _, k := range []int{i, j} {
+			if match.Confidence > edges[k].Confidence {
+				edges[k] = match
+			}
+		}
+	}
+
+	// -- A split Product can never share a cluster with the
+	// -- Products of the cluster it was split from
+	for _, cluster := range existing {
+		for _, excluded := range cluster.ExcludedIDs {
+			e, ok := index[excluded]
+			if !ok {
+				continue
+			}
+			for _, member := range cluster.MemberIDs {
+				if mi, ok := index[member]; ok {
+					set.cannotLink(e, mi)
+				}
+			}
+		}
+	}
+
+	// -- Confirmed Products are joined first so they always stay together
+	for _, cluster := range existing {
+		first := -1
+		for _, confirmed := range cluster.ConfirmedIDs {
+			ci, ok := index[confirmed]
+			if !ok {
+				continue
+			}
+			if first < 0 {
+				first = ci
+				edges[ci] = ProductMatch{Method: MatchMethodManual, Confidence: 1}
+				continue
+			}
+			link(first, ci, ProductMatch{Method: MatchMethodManual, Confidence: 1})
+		}
+	}
+
+	// -- Pass 1: normalized GTIN
+	byGTIN := make(map[string]int)
+	for i, product := range products {
+		for _, gtin := range product.NormalizedGTINs() {
+			if j, ok := byGTIN[gtin]; ok {
+				link(j, i, ProductMatch{Method: MatchMethodGTIN, Confidence: 1})
+			} else {
+				byGTIN[gtin] = i
+			}
+		}
+	}
+
+	// -- Pass 2: brand plus MPN
+	byMPN := make(map[string]int)
+	for i, product := range products {
+		brand := NormalizeText(product.Brand)
+		mpn := NormalizeMPN(product.MPN)
+		if brand == "" || mpn == "" {
+			continue
+		}
+		key := brand + "|" + mpn
+		if j, ok := byMPN[key]; ok {
+			if m.Match(products[j], product).Method == MatchMethodMPN {
+				link(j, i, ProductMatch{Method: MatchMethodMPN, Confidence: 0.95})
+			}
+		} else {
+			byMPN[key] = i
+		}
+	}
+
+	// -- Pass 3: fuzzy name within a block of the same brand,
+	// -- or the same first word of the name when there is no brand
+	blocks := make(map[string][]int)
+	for i, product := range products {
+		key := NormalizeText(product.Brand)
+		if key == "" {
+			tokens := Tokenize(product.Name)
+			if len(tokens) == 0 {
+				continue
+			}
+			key = "~" + tokens[0]
+		}
+		blocks[key] = append(blocks[key], i)
+	}
+	for _, block := range blocks {
+		for _, pair := range m.namePairs(block, products) {
+			i, j := pair[0], pair[1]
+			if set.find(i) == set.find(j) {
+				continue
+			}
+			match := m.Match(products[i], products[j])
+			if match.Method == MatchMethodName {
+				link(i, j, match)
+			}
+		}
+	}
+
+	// -- Build the clusters from the connected components
+	components := make(map[int][]int)
+	for i := range products {
+		root := set.find(i)
+		components[root] = append(components[root], i)
+	}
+	roots := make([]int, 0, len(components))
+	for root := range components {
+		roots = append(roots, root)
+	}
+	sort.Ints(roots)
+
+	used := make(map[*ProductCluster]bool)
+	clusters := make([]*ProductCluster, 0, len(components))
+	for _, root := range roots {
+		component := components[root]
+		cluster := m.reuseCluster(component, products, existing, used)
+		cluster.MemberIDs = make([]primitive.ObjectID, 0, len(component))
+		cluster.Members = make([]ClusterMember, 0, len(component))
+		members := make([]*Product, 0, len(component))
+		for _, i := range component {
+			product := products[i]
+			edge := edges[i]
+			if edge.Method == MatchMethodNone {
+				// -- A Product alone in its cluster matches itself
+				edge = ProductMatch{Method: MatchMethodNone, Confidence: 1}
+			}
+			cluster.MemberIDs = append(cluster.MemberIDs, product.ID)
+			cluster.Members = append(cluster.Members, ClusterMember{
+				ProductID:   product.ID,
+				CompanyName: product.CompanyName,
+				Method:      edge.Method,
+				Confidence:  edge.Confidence,
+			})
+			members = append(members, product)
+		}
+		cluster.Merged = MergeProducts(members)
+		clusters = append(clusters, cluster)
+	}
+	logging.Info("ProductMatcher.Cluster() Grouped %d products into %d clusters", len(products), len(clusters))
+	return clusters
+}
+
+// Returns the pairs of Products in a block whose names can be
+// NameThreshold alike. NameSimilarity weighs the Jaccard similarity of
+// the name tokens by 0.6 and the edit distance by 0.4, so such names
+// share at least (NameThreshold-0.4)/0.6 of their tokens. Token sets that
+// alike share a token among their rarest ones, their prefix, so only
+// Products whose prefixes share a token are paired.
+func (m *ProductMatcher) namePairs(block []int, products []*Product) [][2]int {
+	// -- Less a little so rounding never shortens a prefix
+	minJaccard := (m.NameThreshold-0.4)/0.6 - 1e-9
+
+	tokens := make(map[int][]string, len(block))
+	frequency := make(map[string]int)
+	for _, i := range block {
+		normalized := NormalizeText(products[i].Name)
+		if normalized == "" {
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, token := range stemTokens(strings.Split(normalized, " ")) {
+			if !seen[token] {
+				seen[token] = true
+				tokens[i] = append(tokens[i], token)
+				frequency[token]++
+			}
+		}
+	}
+
+	// -- Without a useful bound every pair has to be compared
+	if minJaccard <= 0 {
+		var pairs [][2]int
+		for x := 0; x < len(block); x++ {
+			for y := x + 1; y < len(block); y++ {
+				if tokens[block[x]] != nil && tokens[block[y]] != nil {
+					pairs = append(pairs, [2]int{block[x], block[y]})
+				}
+			}
+		}
+		return pairs
+	}
+
+	byToken := make(map[string][]int)
+	paired := make(map[[2]int]bool)
+	var pairs [][2]int
+	for _, i := range block {
+		set := tokens[i]
+		if set == nil {
+			continue
+		}
+		sort.Slice(set, func(a, b int) bool {
+			if frequency[set[a]] == frequency[set[b]] {
+				return set[a] < set[b]
+			}
+			return frequency[set[a]] < frequency[set[b]]
+		})
+		prefix := len(set) - int(math.Ceil(minJaccard*float64(len(set)))) + 1
+		if prefix < 1 {
+			prefix = 1
+		}
+		for _, token := range set[:minInt(prefix, len(set))] {
+			for _, j := range byToken[token] {
+				pair := [2]int{j, i}
+				if !paired[pair] {
+					paired[pair] = true
+					pairs = append(pairs, pair)
+				}
+			}
+			byToken[token] = append(byToken[token], i)
+		}
+	}
+	return pairs
+}
+
+// Returns the existing cluster sharing the most members with the
+// component, or a new cluster when none does.
+func (m *ProductMatcher) reuseCluster(component []int, products []*Product, existing []*ProductCluster, used map[*ProductCluster]bool) *ProductCluster {
+	var best *ProductCluster
+	bestOverlap := 0
+	for _, cluster := range existing {
+		if used[cluster] {
+			continue
+		}
+		overlap := 0
+		for _, i := range component {
+			if cluster.HasMember(products[i].ID) {
+				overlap++
+			}
+		}
+		if overlap > bestOverlap {
+			best = cluster
+			bestOverlap = overlap
+		}
+	}
+	if best == nil {
+		best = &ProductCluster{Logger: m.Logger, isNew: true}
+	}
+	used[best] = true
+	return best
+}
+
+// MergeProducts builds the best-of view of a group of Products.
+// Text fields come from the most probable extraction, the brand and MPN
+// are the most common values and images, GTINs and offers are combined.
+func MergeProducts(products []*Product) ClusterView {
+	view := ClusterView{}
+	if len(products) == 0 {
+		return view
+	}
+
+	ranked := make([]*Product, len(products))
+	copy(ranked, products)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Probability > ranked[j].Probability
+	})
+
+	brands := make(map[string]int)
+	mpns := make(map[string]int)
+	gtins := make(map[string]bool)
+	images := make(map[string]bool)
+	companies := make(map[string]bool)
+	var ratingTotal float64
+	var ratingCount int
+	for _, product := range ranked {
+		if view.Name == "" {
+			view.Name = product.Name
+		}
+		if view.MainImage == "" {
+			view.MainImage = product.MainImage
+		}
+		if len(product.Description) > len(view.Description) {
+			view.Description = product.Description
+		}
+		if product.Brand != "" {
+			brands[product.Brand]++
+		}
+		if product.MPN != "" {
+			mpns[product.MPN]++
+		}
+		for _, gtin := range product.NormalizedGTINs() {
+			if !gtins[gtin] {
+				gtins[gtin] = true
+				view.GTINs = append(view.GTINs, gtin)
+			}
+		}
+		for _, image := range append([]string{product.MainImage}, product.Images...) {
+			if image != "" && !images[image] {
+				images[image] = true
+				view.Images = append(view.Images, image)
+			}
+		}
+		if product.CompanyName != "" && !companies[product.CompanyName] {
+			companies[product.CompanyName] = true
+			view.CompanyNames = append(view.CompanyNames, product.CompanyName)
+		}
+		for _, offer := range product.Offers {
+			view.Offers = append(view.Offers, ClusterOffer{
+				ProductID:   product.ID,
+				CompanyName: product.CompanyName,
+				URL:         product.URL,
+				Offer:       offer,
+			})
+		}
+		rating := product.AggregateRating
+		if rating.BestRating > 0 && rating.ReviewCount > 0 {
+			ratingTotal += rating.RatingValue / rating.BestRating * 5 * float64(rating.ReviewCount)
+			ratingCount += rating.ReviewCount
+		}
+	}
+	view.Brand = mostCommon(brands)
+	view.MPN = mostCommon(mpns)
+	if ratingCount > 0 {
+		view.AggregateRating = AggregateRating{
+			RatingValue: ratingTotal / float64(ratingCount),
+			BestRating:  5,
+			ReviewCount: ratingCount,
+		}
+	}
+	return view
+}
+
+// Returns the key with the highest count, ties go to the shortest
+// then alphabetically first key so the result is stable.
+func mostCommon(counts map[string]int) string {
+	best := ""
+	bestCount := 0
+	for value, count := range counts {
+		if count > bestCount ||
+			(count == bestCount && (len(value) < len(best) || (len(value) == len(best) && strings.Compare(value, best) < 0))) {
+			best = value
+			bestCount = count
+		}
+	}
+	return best
+}
+
+// MatchProducts clusters every stored Product and saves the resulting
+// ProductClusters, reusing and honoring manual changes to existing ones.
+func MatchProducts(logging logging.Logger, matcher *ProductMatcher) error {
+	logging.Debug("MatchProducts() called")
+	if matcher.Logger == nil {
+		matcher.Logger = &logging
+	}
+
+	prd := NewProduct()
+	prd.Logger = &logging
+	docs, err := prd.Query()
+	if err != nil {
+		logging.Error("MatchProducts() Error querying products: %s", err.Error())
+		return errors.NewChuxModelsError("MatchProducts() Error querying products", err)
+	}
+	products := make([]*Product, 0, len(docs))
+	for _, doc := range docs {
+		products = append(products, doc.(*Product))
+	}
+
+	pc := NewProductCluster()
+	pc.Logger = &logging
+	docs, err = pc.Query()
+	if err != nil {
+		logging.Error("MatchProducts() Error querying product clusters: %s", err.Error())
+		return errors.NewChuxModelsError("MatchProducts() Error querying product clusters", err)
+	}
+	existing := make([]*ProductCluster, 0, len(docs))
+	for _, doc := range docs {
+		cluster := doc.(*ProductCluster)
+		cluster.Logger = &logging
+		err = cluster.markLoaded()
+		if err != nil {
+			return errors.NewChuxModelsError("MatchProducts() Error loading product cluster", err)
+		}
+		existing = append(existing, cluster)
+	}
+
+	clusters := matcher.Cluster(products, existing)
+	kept := make(map[*ProductCluster]bool, len(clusters))
+	for _, cluster := range clusters {
+		kept[cluster] = true
+		err = cluster.Save()
+		if err != nil {
+			logging.Error("MatchProducts() Error saving product cluster: %s", err.Error())
+			return errors.NewChuxModelsError("MatchProducts() Error saving product cluster", err)
+		}
+	}
+
+	// -- Remove clusters whose members were all merged elsewhere
+	for _, cluster := range existing {
+		if kept[cluster] {
+			continue
+		}
+		cluster.Delete()
+		err = cluster.Save()
+		if err != nil {
+			logging.Error("MatchProducts() Error deleting product cluster: %s", err.Error())
+			return errors.NewChuxModelsError("MatchProducts() Error deleting product cluster", err)
+		}
+	}
+	logging.Info("MatchProducts() Saved %d product clusters", len(clusters))
+	return nil
+}
+
+// disjointSet is a union-find structure with cannot-link constraints
+type disjointSet struct {
+	parent  []int
+	members map[int][]int
+	apart   map[int]map[int]bool
+}
+
+func newDisjointSet(size int) *disjointSet {
+	s := &disjointSet{
+		parent:  make([]int, size),
+		members: make(map[int][]int, size),
+		apart:   make(map[int]map[int]bool),
+	}
+	for i := range s.parent {
+		s.parent[i] = i
+		s.members[i] = []int{i}
+	}
+	return s
+}
+
+func (s *disjointSet) find(i int) int {
+	for s.parent[i] != i {
+		s.parent[i] = s.parent[s.parent[i]]
+		i = s.parent[i]
+	}
+	return i
+}
+
+// Records that a and b must never end up in the same set
+func (s *disjointSet) cannotLink(a, b int) {
+	if s.apart[a] == nil {
+		s.apart[a] = make(map[int]bool)
+	}
+	if s.apart[b] == nil {
+		s.apart[b] = make(map[int]bool)
+	}
+	s.apart[a][b] = true
+	s.apart[b][a] = true
+}
+
+// Joins the sets of a and b. Returns false when they were already
+// joined or joining them would break a cannot-link constraint.
+func (s *disjointSet) union(a, b int) bool {
+	rootA := s.find(a)
+	rootB := s.find(b)
+	if rootA == rootB {
+		return false
+	}
+	if len(s.apart) > 0 {
+		for _, x := range s.members[rootA] {
+			for y := range s.apart[x] {
+				if s.find(y) == rootB {
+					return false
+				}
+			}
+		}
+	}
+	if len(s.members[rootA]) < len(s.members[rootB]) {
+		rootA, rootB = rootB, rootA
+	}
+	s.parent[rootB] = rootA
+	s.members[rootA] = append(s.members[rootA], s.members[rootB]...)
+	delete(s.members, rootB)
+	return true
+}
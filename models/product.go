@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/chuxorg/chux-datastore/db"
 	dbl "github.com/chuxorg/chux-datastore/logging"
@@ -20,28 +21,43 @@ type GTIN struct {
 }
 
 type Product struct {
-	ID                   primitive.ObjectID   `bson:"_id,omitempty" json:"_id,omitempty"`
-	URL                  string               `bson:"url" json:"url"`
-	CanonicalURL         string               `bson:"canonicalUrl" json:"canonicalUrl"`
-	CompanyName          string               `bson:"companyName" json:"companyName"`
-	Probability          float64              `bson:"probability" json:"probability"`
-	Name                 string               `bson:"name" json:"name"`
-	Offers               []Offer              `bson:"offers" json:"offers"`
-	SKU                  string               `bson:"sku" json:"sku"`
-	MPN                  string               `bson:"mpn,omitempty" json:"mpn,omitempty"`
-	Brand                string               `bson:"brand,omitempty" json:"brand,omitempty"`
-	Breadcrumbs          []Breadcrumb         `bson:"breadcrumbs" json:"breadcrumbs"`
-	MainImage            string               `bson:"mainImage" json:"mainImage"`
-	Images               []string             `bson:"images" json:"images"`
-	Description          string               `bson:"description" json:"description"`
-	DescriptionHTML      string               `bson:"descriptionHtml" json:"descriptionHtml"`
+	ID                 primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	URL                string             `bson:"url" json:"url"`
+	CanonicalURL       string             `bson:"canonicalUrl" json:"canonicalUrl"`
+	CompanyName        string             `bson:"companyName" json:"companyName"`
+	Probability        float64            `bson:"probability" json:"probability"`
+	Name               string             `bson:"name" json:"name"`
+	Offers             []Offer            `bson:"offers" json:"offers"`
+	SKU                string             `bson:"sku" json:"sku"`
+	MPN                string             `bson:"mpn,omitempty" json:"mpn,omitempty"`
+	Brand              string             `bson:"brand,omitempty" json:"brand,omitempty"`
+	BrandRaw           string             `bson:"brandRaw,omitempty" json:"brandRaw,omitempty"`
+	BrandID            primitive.ObjectID `bson:"brandId" json:"brandId,omitempty"`
+	Breadcrumbs        []Breadcrumb       `bson:"breadcrumbs" json:"breadcrumbs"`
+	MainImage          string             `bson:"mainImage" json:"mainImage"`
+	Images             []string           `bson:"images" json:"images"`
+	Description        string             `bson:"description" json:"description"`
+	DescriptionHTML    string             `bson:"descriptionHtml" json:"descriptionHtml"`
+	InLanguage         string             `bson:"inLanguage,omitempty" json:"inLanguage,omitempty"`
+	LanguageConfidence float64            `bson:"languageConfidence,omitempty" json:"languageConfidence,omitempty"`
+	// Written when empty too, so that re-extracting no Tags clears the stored ones
+	Keywords             []Keyword            `bson:"keywords" json:"keywords,omitempty"`
+	Tags                 []string             `bson:"tags" json:"tags,omitempty"`
 	AdditionalProperties []AdditionalProperty `bson:"additionalProperty" json:"additionalProperty"`
+	Attributes           []Attribute          `bson:"attributes,omitempty" json:"attributes,omitempty"`
 	AggregateRating      AggregateRating      `bson:"aggregateRating" json:"aggregateRating"`
+	Quality              *QualityScore        `bson:"quality,omitempty" json:"quality,omitempty"`
 	GTINs                []GTIN               `bson:"gtins,omitempty" json:"gtin,omitempty"`
 	Color                string               `bson:"color,omitempty" json:"color,omitempty"`
 	Style                string               `bson:"style,omitempty" json:"style,omitempty"`
 	DateCreated          CustomTime           `bson:"dateCreated,omitempty" json:"dateCreated,omitempty"`
 	DateModified         CustomTime           `bson:"dateModified,omitempty" json:"dateModified,omitempty"`
+	FirstSeen            CustomTime           `bson:"firstSeen,omitempty" json:"firstSeen,omitempty"`
+	LastSeen             CustomTime           `bson:"lastSeen,omitempty" json:"lastSeen,omitempty"`
+	SeenCount            int                  `bson:"seenCount" json:"seenCount"`
+	LastCrawlRun         string               `bson:"lastCrawlRun,omitempty" json:"lastCrawlRun,omitempty"`
+	Discontinued         bool                 `bson:"discontinued" json:"discontinued"`
+	DiscontinuedAt       CustomTime           `bson:"discontinuedAt,omitempty" json:"discontinuedAt,omitempty"`
 	isNew                bool                 `bson:"-" json:"-"`
 	isDeleted            bool                 `bson:"-" json:"-"`
 	isDirty              bool                 `bson:"-" json:"-"`
@@ -50,6 +66,14 @@ type Product struct {
 	ImagesProcessed      bool                 `bson:"imagesProcessed" json:"imagesProcessed"`
 	FilesProcessed       bool                 `bson:"filesProcessed" json:"filesProcessed"`
 	originalState        *Product             `bson:"-" json:"-"`
+	brandNormalizer      *BrandNormalizer     `bson:"-" json:"-"`
+	qualityScorer        *QualityScorer       `bson:"-" json:"-"`
+	quarantineGate       *QuarantineGate      `bson:"-" json:"-"`
+	deadLetters          bool                 `bson:"-" json:"-"`
+	crawlRun             *CrawlRun            `bson:"-" json:"-"`
+	languageDetector     *LanguageDetector    `bson:"-" json:"-"`
+	keywordExtractor     *KeywordExtractor    `bson:"-" json:"-"`
+	timeConfig           *CustomTimeConfig    `bson:"-" json:"-"`
 	Logger               *logging.Logger      `bson:"-" json:"-"`
 }
 
@@ -81,6 +105,66 @@ func NewProductWithLogger(logger logging.Logger) func(*Product) {
 	}
 }
 
+// Sets how the Product's CustomTimes are written to JSON and stored in Mongo,
+// without it they use the DefaultCustomTimeConfig
+func NewProductWithTimeConfig(config CustomTimeConfig) func(*Product) {
+	return func(p *Product) {
+		p.timeConfig = &config
+	}
+}
+
+// Resolves the Product's Brand with the BrandNormalizer on every Save()
+func NewProductWithBrandNormalizer(normalizer *BrandNormalizer) func(*Product) {
+	return func(p *Product) {
+		p.brandNormalizer = normalizer
+	}
+}
+
+// Scores the Product's Quality with the QualityScorer on every Save()
+// instead of the default one
+func NewProductWithQualityScorer(scorer *QualityScorer) func(*Product) {
+	return func(p *Product) {
+		p.qualityScorer = scorer
+	}
+}
+
+// Detects the Product's InLanguage with the LanguageDetector on
+// every Save() instead of the default one
+func NewProductWithLanguageDetector(detector *LanguageDetector) func(*Product) {
+	return func(p *Product) {
+		p.languageDetector = detector
+	}
+}
+
+// Sets the Product's Keywords and Tags with the KeywordExtractor on every Save()
+func NewProductWithKeywordExtractor(extractor *KeywordExtractor) func(*Product) {
+	return func(p *Product) {
+		p.keywordExtractor = extractor
+	}
+}
+
+// New Products that do not pass the QuarantineGate are
+// quarantined by Save() instead of being saved
+func NewProductWithQuarantineGate(gate *QuarantineGate) func(*Product) {
+	return func(p *Product) {
+		p.quarantineGate = gate
+	}
+}
+
+// Records the CrawlRun that saw the Product on Save()
+func NewProductWithCrawlRun(run *CrawlRun) func(*Product) {
+	return func(p *Product) {
+		p.crawlRun = run
+	}
+}
+
+// Records failed Parse() and Save() calls as DeadLetters
+func NewProductWithDeadLetters(enabled bool) func(*Product) {
+	return func(p *Product) {
+		p.deadLetters = enabled
+	}
+}
+
 func (p *Product) GetCollectionName() string {
 	logging := p.Logger
 	logging.Debug("Product.GetCollectionName() was called")
@@ -149,10 +233,33 @@ func (p *Product) IsNew() bool {
 	return p.isNew
 }
 
-// Saves the Model to a Data Store
+// Saves the Model to a Data Store. When dead letters are enabled
+// a failed Save() is recorded as a DeadLetter.
 func (p *Product) Save() error {
+	err := p.save()
+	if err != nil && p.deadLetters && !isQuarantined(err) {
+		payload, _ := p.Serialize()
+		recordDeadLetter(p.Logger, DeadLetterModelProduct, DeadLetterOperationSave, payload, err)
+	}
+	return err
+}
+
+func (p *Product) save() error {
 	logging := p.Logger
 	logging.Debug("Product.Save() was called")
+	applyTimeConfig(p.timeConfig, p)
+	if !p.isDeleted {
+		p.normalize()
+	}
+	// -- Set when the Product was stored but its ProductObservation was not
+	var observationErr error
+	// -- Set when the Product was stored but the counts of its Tags were not
+	var tagErr error
+	if p.isNew && p.quarantineGate != nil {
+		if reasons := p.quarantineGate.Reasons(p.Probability, p.Validate()); len(reasons) > 0 {
+			return p.quarantine(reasons)
+		}
+	}
 	if p.isNew {
 		logging.Debug("Product.Save() Product is new")
 		companyName, err := ExtractCompanyName(p.CanonicalURL)
@@ -168,6 +275,21 @@ func (p *Product) Save() error {
 		p.IsCategorized = false
 		// Mark product as processed
 		p.FilesProcessed = true
+		// -- A new Product was scraped, so it has been seen again
+		err = p.markSeen()
+		if err != nil {
+			return err
+		}
+
+		// -- The Product may have been scraped and tagged before
+		var previousTags []string
+		if len(p.Tags) > 0 || p.keywordExtractor != nil {
+			previousTags, err = p.storedTags()
+			if err != nil {
+				logging.Error("Product.Save() Error reading stored Tags: %s", err.Error())
+				return errors.NewChuxModelsError("Product.Save() Error reading stored Tags", err)
+			}
+		}
 
 		//-- Upsert document
 		err = mongoDB.Upsert(p, "canonicalUrl")
@@ -175,6 +297,9 @@ func (p *Product) Save() error {
 			logging.Error("Product.Save() Error creating/updating Product in MongoDB: %s", err.Error())
 			return errors.NewChuxModelsError("Product.Save() Error creating/updating Product in MongoDB", err)
 		}
+		// -- Compare against the last stored observation, the Product may have been scraped before
+		observationErr = p.recordObservation(nil, false)
+		tagErr = p.countTags(previousTags, p.Tags)
 
 	} else if p.IsDirty() && !p.isDeleted {
 		logging.Debug("Product.Save() Product is dirty")
@@ -192,6 +317,8 @@ func (p *Product) Save() error {
 			logging.Error("Product.Save() Error updating Product in MongoDB: %s", err.Error())
 			return errors.NewChuxModelsError("Product.Save() Error updating Product in MongoDB", err)
 		}
+		observationErr = p.recordObservation(p.originalState.Offers, true)
+		tagErr = p.countTags(p.originalState.Tags, p.Tags)
 	} else if p.isDeleted && !p.isNew {
 		logging.Info("Product.Save() Product is deleted")
 		//--delete the document
@@ -201,6 +328,7 @@ func (p *Product) Save() error {
 			logging.Error("Product.Save() Error deleting Product in MongoDB: %s", err.Error())
 			return errors.NewChuxModelsError("Product.Save() Error deleting Product in MongoDB", err)
 		}
+		tagErr = p.countTags(p.Tags, nil)
 	}
 
 	// If the Product has been deleted, then this is a new Product
@@ -225,10 +353,61 @@ func (p *Product) Save() error {
 		p.SetState(serialized)
 	}
 
+	if observationErr != nil {
+		logging.Error("Product.Save() Error recording ProductObservation: %s", observationErr.Error())
+		return errors.NewChuxModelsError("Product.Save() Product was saved but its ProductObservation was not", observationErr)
+	}
+	if tagErr != nil {
+		logging.Error("Product.Save() Error counting Tags: %s", tagErr.Error())
+		return errors.NewChuxModelsError("Product.Save() Product was saved but the counts of its Tags were not", tagErr)
+	}
+
 	logging.Info("Product.Save() Product saved successfully")
 	return nil
 }
 
+// Stores the Product as a QuarantinedItem instead of saving it
+func (p *Product) quarantine(reasons []string) error {
+	logging := p.Logger
+	logging.Info("Product.Save() Product %s is quarantined: %s", p.CanonicalURL, strings.Join(reasons, "; "))
+	serialized, err := p.Serialize()
+	if err != nil {
+		return err
+	}
+	companyName, _ := ExtractCompanyName(p.CanonicalURL)
+	err = quarantine(p.Logger, QuarantineKindProduct, p.CanonicalURL, companyName, p.Probability, reasons, serialized)
+	if err != nil {
+		logging.Error("Product.Save() Error quarantining Product: %s", err.Error())
+		return errors.NewChuxModelsError("Product.Save() Error quarantining Product", err)
+	}
+	return errors.NewChuxModelsError("Product.Save() Product was quarantined: "+strings.Join(reasons, "; "), ErrQuarantined)
+}
+
+// Sets the fields derived from the scraped data before a save
+func (p *Product) normalize() {
+	p.SanitizeHTML()
+	p.NormalizeAttributes()
+	p.NormalizeAvailability()
+	p.ComputeUnitPrices()
+	p.AggregateRating.NormalizedValue = NormalizeRating(p.AggregateRating.RatingValue, p.AggregateRating.BestRating)
+	if p.brandNormalizer != nil {
+		p.NormalizeBrand(p.brandNormalizer)
+	}
+	detector := p.languageDetector
+	if detector == nil {
+		detector = NewLanguageDetector()
+	}
+	p.DetectLanguage(detector)
+	if p.keywordExtractor != nil {
+		p.ExtractTags(p.keywordExtractor)
+	}
+	if p.qualityScorer != nil {
+		p.ScoreQualityWith(p.qualityScorer)
+	} else {
+		p.ScoreQuality()
+	}
+}
+
 // Loads a Model from MongoDB by id
 func (p *Product) Load(id string) (interface{}, error) {
 	logging := p.Logger
@@ -302,15 +481,55 @@ func (p *Product) SetState(json string) error {
 	// Store the current state as the original state
 	original := &Product{}
 	*original = *p
+	// -- Deserialize reuses the backing array of Offers, so the original needs its own copy
+	original.Offers = cloneOffers(p.Offers)
 	p.originalState = original
 
 	// Deserialize the new state
 	return p.Deserialize([]byte(json))
 }
 
+// Copies Offers along with the UnitPrices they point to
+func cloneOffers(offers []Offer) []Offer {
+	if offers == nil {
+		return nil
+	}
+	cloned := make([]Offer, len(offers))
+	for i, offer := range offers {
+		if offer.UnitPrice != nil {
+			unitPrice := *offer.UnitPrice
+			offer.UnitPrice = &unitPrice
+		}
+		cloned[i] = offer
+	}
+	return cloned
+}
+
+// Marks a Product returned by Query() as loaded so that
+// changes made to it are persisted by Save()
+func (p *Product) markLoaded() error {
+	serialized, err := p.Serialize()
+	if err != nil {
+		return errors.NewChuxModelsError("Product.markLoaded() Error serializing Product", err)
+	}
+	p.SetState(serialized)
+	p.isNew = false
+	p.isDirty = false
+	p.isDeleted = false
+	return nil
+}
+
 // Sets the internal state of the model of a new Product
 // from a JSON String.
 func (p *Product) Parse(json string) error {
+	err := p.parse(json)
+	if err != nil && p.deadLetters {
+		recordDeadLetter(p.Logger, DeadLetterModelProduct, DeadLetterOperationParse, json, err)
+	}
+	return err
+}
+
+func (p *Product) parse(json string) error {
 	logging := p.Logger
 	logging.Debug("Product.Parse() was called")
 	err := p.SetState(json)
@@ -322,6 +541,31 @@ func (p *Product) Parse(json string) error {
 	return nil
 }
 
+// TaxonomyPath resolves the Google Product Taxonomy path of the Product
+// through the Category referenced by its CategoryID.
+func (p *Product) TaxonomyPath(t *Taxonomy) ([]string, error) {
+	logging := p.Logger
+	logging.Debug("Product.TaxonomyPath() was called")
+	if p.CategoryID.IsZero() {
+		logging.Info("Product.TaxonomyPath() Product is not categorized")
+		return nil, errors.NewChuxModelsError("Product.TaxonomyPath() Product is not categorized", nil)
+	}
+	category := NewCategory()
+	category.Logger = p.Logger
+	_, err := category.Load(p.CategoryID.Hex())
+	if err != nil {
+		logging.Error("Product.TaxonomyPath() Error loading Category: %s", err.Error())
+		return nil, errors.NewChuxModelsError("Product.TaxonomyPath() Error loading Category", err)
+	}
+	node, ok := t.Node(category.TaxonomyID)
+	if !ok {
+		msg := fmt.Sprintf("Product.TaxonomyPath() Category %s is not mapped to the taxonomy", category.Name)
+		logging.Info(msg)
+		return nil, errors.NewChuxModelsError(msg, nil)
+	}
+	return node.Path, nil
+}
+
 func (p *Product) Search(args ...interface{}) ([]interface{}, error) {
 	logging := p.Logger
 	logging.Debug("Product.Search() was called")
@@ -331,6 +575,7 @@ func (p *Product) Search(args ...interface{}) ([]interface{}, error) {
 func (p *Product) Serialize() (string, error) {
 	logging := p.Logger
 	logging.Debug("Product.Serialize() was called")
+	applyTimeConfig(p.timeConfig, p)
 	bytes, err := json.Marshal(p)
 	if err != nil {
 		logging.Error("Product.Serialize() error ocurred ", err)
@@ -342,6 +587,7 @@ func (p *Product) Serialize() (string, error) {
 func (p *Product) Deserialize(jsonData []byte) error {
 	logging := p.Logger
 	logging.Debug("Product.Deserialize() was called")
+	applyTimeConfig(p.timeConfig, p)
 	err := json.Unmarshal(jsonData, p)
 	if err != nil {
 		logging.Error("Product.Deserialize() error occurred ", err)
@@ -0,0 +1,409 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/chuxorg/chux-datastore/db"
+	dbl "github.com/chuxorg/chux-datastore/logging"
+	"github.com/chuxorg/chux-models/errors"
+	"github.com/chuxorg/chux-models/logging"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Describes how a Product was matched into a ProductCluster
+type ClusterMember struct {
+	ProductID   primitive.ObjectID `bson:"productId" json:"productId"`
+	CompanyName string             `bson:"companyName" json:"companyName"`
+	Method      string             `bson:"method" json:"method"`
+	Confidence  float64            `bson:"confidence" json:"confidence"`
+}
+
+// An Offer of a clustered Product along with where it came from
+type ClusterOffer struct {
+	ProductID   primitive.ObjectID `bson:"productId" json:"productId"`
+	CompanyName string             `bson:"companyName" json:"companyName"`
+	URL         string             `bson:"url" json:"url"`
+	Offer       Offer              `bson:"offer" json:"offer"`
+}
+
+// The best-of view of all Products in a ProductCluster
+type ClusterView struct {
+	Name            string          `bson:"name" json:"name"`
+	Brand           string          `bson:"brand,omitempty" json:"brand,omitempty"`
+	MPN             string          `bson:"mpn,omitempty" json:"mpn,omitempty"`
+	GTINs           []string        `bson:"gtins,omitempty" json:"gtins,omitempty"`
+	MainImage       string          `bson:"mainImage" json:"mainImage"`
+	Images          []string        `bson:"images" json:"images"`
+	Description     string          `bson:"description" json:"description"`
+	CompanyNames    []string        `bson:"companyNames" json:"companyNames"`
+	Offers          []ClusterOffer  `bson:"offers" json:"offers"`
+	AggregateRating AggregateRating `bson:"aggregateRating" json:"aggregateRating"`
+}
+
+// ProductCluster links the Product documents of many companies
+// that represent the same canonical item.
+type ProductCluster struct {
+	ID            primitive.ObjectID   `bson:"_id,omitempty" json:"_id,omitempty"`
+	MemberIDs     []primitive.ObjectID `bson:"memberIds" json:"memberIds"`
+	Members       []ClusterMember      `bson:"members" json:"members"`
+	ConfirmedIDs  []primitive.ObjectID `bson:"confirmedIds" json:"confirmedIds"`
+	ExcludedIDs   []primitive.ObjectID `bson:"excludedIds" json:"excludedIds"`
+	Merged        ClusterView          `bson:"merged" json:"merged"`
+	DateCreated   CustomTime           `bson:"dateCreated,omitempty" json:"dateCreated,omitempty"`
+	DateModified  CustomTime           `bson:"dateModified,omitempty" json:"dateModified,omitempty"`
+	isNew         bool                 `bson:"-" json:"-"`
+	isDeleted     bool                 `bson:"-" json:"-"`
+	isDirty       bool                 `bson:"-" json:"-"`
+	originalState *ProductCluster      `bson:"-" json:"-"`
+	timeConfig    *CustomTimeConfig    `bson:"-" json:"-"`
+	Logger        *logging.Logger      `bson:"-" json:"-"`
+}
+
+func NewProductCluster(options ...func(*ProductCluster)) *ProductCluster {
+
+	pc := &ProductCluster{}
+
+	for _, option := range options {
+		option(pc)
+	}
+	dbLogger := dbl.NewLogger(dbl.LogLevelDebug)
+	mongoDB = db.New(
+		db.WithURI(pc.GetURI()),
+		db.WithDatabaseName(pc.GetDatabaseName()),
+		db.WithCollectionName(pc.GetCollectionName()),
+		db.WithTimeout(30),
+		db.WithLogger(*dbLogger),
+	)
+
+	pc.isNew = true
+	pc.isDeleted = false
+	pc.isDirty = false
+	return pc
+}
+
+func NewProductClusterWithLogger(logger logging.Logger) func(*ProductCluster) {
+	return func(pc *ProductCluster) {
+		pc.Logger = &logger
+	}
+}
+
+// Sets how the ProductCluster's CustomTimes are written to JSON and stored in Mongo,
+// without it they use the DefaultCustomTimeConfig
+func NewProductClusterWithTimeConfig(config CustomTimeConfig) func(*ProductCluster) {
+	return func(pc *ProductCluster) {
+		pc.timeConfig = &config
+	}
+}
+
+func (pc *ProductCluster) GetCollectionName() string {
+	logging := pc.Logger
+	logging.Debug("ProductCluster.GetCollectionName() was called")
+	return "productClusters"
+}
+
+func (pc *ProductCluster) GetDatabaseName() string {
+	logging := pc.Logger
+	logging.Debug("ProductCluster.GetDatabaseName() was called")
+	return os.Getenv("MONGO_DATABASE")
+}
+
+func (pc *ProductCluster) GetURI() string {
+	logging := pc.Logger
+	logging.Debug("ProductCluster.GetURI() was called")
+	username := os.Getenv("MONGO_USER_NAME")
+	password := os.Getenv("MONGO_PASSWORD")
+
+	uri := os.Getenv("MONGO_URI")
+	mongoURI := fmt.Sprintf(uri, username, password)
+	masked := fmt.Sprintf(uri, "********", "********")
+	logging.Info("Mongo URI: %s", masked)
+	return mongoURI
+}
+
+func (pc *ProductCluster) GetID() primitive.ObjectID {
+	logging := pc.Logger
+	logging.Debug("ProductCluster.GetID() was called")
+	return pc.ID
+}
+
+func (pc *ProductCluster) SetID(id primitive.ObjectID) {
+	logging := pc.Logger
+	logging.Debug("ProductCluster.SetID() was called")
+	pc.ID = id
+}
+
+// If the Model has changes, will return true
+func (pc *ProductCluster) IsDirty() bool {
+	logging := pc.Logger
+	logging.Debug("ProductCluster.IsDirty() was called")
+	if pc.originalState == nil {
+		return false
+	}
+
+	originalBytes, err := pc.originalState.Serialize()
+	if err != nil {
+		return false
+	}
+
+	currentBytes, err := pc.Serialize()
+	if err != nil {
+		return false
+	}
+
+	pc.isDirty = string(originalBytes) != string(currentBytes)
+	logging.Info("ProductCluster.IsDirty() isDirty: %t", pc.isDirty)
+	return pc.isDirty
+}
+
+// When the Model is first created,
+// the model is considered New. After the model is
+// Saved or Loaded it is no longer New
+func (pc *ProductCluster) IsNew() bool {
+	logging := pc.Logger
+	logging.Debug("ProductCluster.IsNew() was called")
+	return pc.isNew
+}
+
+// Saves the Model to a Data Store
+func (pc *ProductCluster) Save() error {
+	logging := pc.Logger
+	logging.Debug("ProductCluster.Save() was called")
+	applyTimeConfig(pc.timeConfig, pc)
+	if pc.isNew {
+		logging.Debug("ProductCluster.Save() ProductCluster is new")
+		// -- Set the date created to now
+		pc.DateCreated.Now()
+		// -- Upserting by _id needs the ID up front, MongoDB will not change the _id of an upserted document
+		if pc.ID.IsZero() {
+			pc.ID = primitive.NewObjectID()
+		}
+		//-- Upsert document
+		err := mongoDB.Upsert(pc)
+		if err != nil {
+			logging.Error("ProductCluster.Save() Error creating ProductCluster in MongoDB: %s", err.Error())
+			return errors.NewChuxModelsError("ProductCluster.Save() Error creating ProductCluster in MongoDB", err)
+		}
+	} else if pc.IsDirty() && !pc.isDeleted {
+		logging.Debug("ProductCluster.Save() ProductCluster is dirty")
+		// Ensure the ID is a valid hex string representation of an ObjectID
+		_, err := primitive.ObjectIDFromHex(pc.ID.Hex())
+		if err != nil {
+			logging.Error("ProductCluster.Save() invalid ObjectID: %s", err.Error())
+			return errors.NewChuxModelsError("ProductCluster.Save() invalid ObjectID", err)
+		}
+		// -- Set the date modified to now
+		pc.DateModified.Now()
+		//--update this document
+		err = mongoDB.Update(pc, pc.ID.Hex())
+		if err != nil {
+			logging.Error("ProductCluster.Save() Error updating ProductCluster in MongoDB: %s", err.Error())
+			return errors.NewChuxModelsError("ProductCluster.Save() Error updating ProductCluster in MongoDB", err)
+		}
+	} else if pc.isDeleted && !pc.isNew {
+		logging.Info("ProductCluster.Save() ProductCluster is deleted")
+		//--delete the document
+		err := mongoDB.Delete(pc, pc.ID.Hex())
+		if err != nil {
+			logging.Error("ProductCluster.Save() Error deleting ProductCluster in MongoDB: %s", err.Error())
+			return errors.NewChuxModelsError("ProductCluster.Save() Error deleting ProductCluster in MongoDB", err)
+		}
+	}
+
+	// If the ProductCluster has been deleted, then this is a new ProductCluster
+	pc.isNew = pc.isDeleted
+	pc.isDirty = pc.IsDirty()
+	pc.isDeleted = false
+
+	if pc.isNew {
+		pc.originalState = nil
+	} else {
+		//--reset state
+		serialized, err := pc.Serialize()
+		if err != nil {
+			logging.Error("ProductCluster.Save() Error serializing ProductCluster: %s", err.Error())
+			return errors.NewChuxModelsError("ProductCluster.Save() Error serializing ProductCluster.", err)
+		}
+		pc.SetState(serialized)
+	}
+
+	logging.Info("ProductCluster.Save() ProductCluster saved successfully")
+	return nil
+}
+
+// Loads a Model from MongoDB by id
+func (pc *ProductCluster) Load(id string) (interface{}, error) {
+	logging := pc.Logger
+	logging.Debug("ProductCluster.Load() was called")
+
+	retVal, err := mongoDB.GetByID(pc, id)
+	if err != nil {
+		logging.Error("ProductCluster.Load() Error loading ProductCluster from MongoDB: %s", err.Error())
+		return nil, errors.NewChuxModelsError("ProductCluster.Load() Error loading ProductCluster from MongoDB", err)
+	}
+	cluster, ok := retVal.(*ProductCluster)
+	if !ok {
+		logging.Error("ProductCluster.Load() unable to cast retVal to *ProductCluster")
+		return nil, errors.NewChuxModelsError("ProductCluster.Load() unable to cast retVal to *ProductCluster", nil)
+	}
+	err = cluster.markLoaded()
+	if err != nil {
+		logging.Error("ProductCluster.Load() Error setting state: %s", err.Error())
+		return nil, errors.NewChuxModelsError("ProductCluster.Load() Error setting state", err)
+	}
+	logging.Info("ProductCluster.Load() ProductCluster loaded successfully")
+	return retVal, nil
+}
+
+func (pc *ProductCluster) Query(args ...interface{}) ([]db.IMongoDocument, error) {
+	logging := pc.Logger
+	logging.Debug("ProductCluster.Query() was called")
+
+	results, err := mongoDB.Query(pc, args...)
+	if err != nil {
+		logging.Error("ProductCluster.Query() Error occurred querying ProductClusters: %s", err.Error())
+		return nil, errors.NewChuxModelsError("ProductCluster.Query() Error occurred querying ProductClusters", err)
+	}
+	logging.Info("ProductCluster.Query() ProductClusters queried successfully")
+	return results, nil
+}
+
+// Marks a Model for deletion from the Data Store
+// when Save() is called, the Model will be deleted
+func (pc *ProductCluster) Delete() error {
+	logging := pc.Logger
+	logging.Debug("ProductCluster.Delete() was called")
+	pc.isDeleted = true
+	return nil
+}
+
+// Sets the internal state of the model.
+func (pc *ProductCluster) SetState(json string) error {
+	logging := pc.Logger
+	logging.Debug("ProductCluster.SetState() was called")
+	// Store the current state as the original state
+	original := &ProductCluster{}
+	*original = *pc
+	pc.originalState = original
+
+	// Deserialize the new state
+	return pc.Deserialize([]byte(json))
+}
+
+// Marks a ProductCluster returned by Query() as loaded so that
+// changes made to it are persisted by Save()
+func (pc *ProductCluster) markLoaded() error {
+	serialized, err := pc.Serialize()
+	if err != nil {
+		return errors.NewChuxModelsError("ProductCluster.markLoaded() Error serializing ProductCluster", err)
+	}
+	pc.SetState(serialized)
+	pc.isNew = false
+	pc.isDirty = false
+	pc.isDeleted = false
+	return nil
+}
+
+// Sets the internal state of the model of a new ProductCluster
+// from a JSON String.
+func (pc *ProductCluster) Parse(json string) error {
+	logging := pc.Logger
+	logging.Debug("ProductCluster.Parse() was called")
+	err := pc.SetState(json)
+	if err != nil {
+		logging.Error("ProductCluster.Parse() error setting state")
+		return errors.NewChuxModelsError("ProductCluster.Parse() Error setting state", err)
+	}
+	pc.isNew = true // this is a new model
+	return nil
+}
+
+func (pc *ProductCluster) Search(args ...interface{}) ([]interface{}, error) {
+	logging := pc.Logger
+	logging.Debug("ProductCluster.Search() was called")
+	return nil, nil
+}
+
+func (pc *ProductCluster) Serialize() (string, error) {
+	logging := pc.Logger
+	logging.Debug("ProductCluster.Serialize() was called")
+	applyTimeConfig(pc.timeConfig, pc)
+	bytes, err := json.Marshal(pc)
+	if err != nil {
+		logging.Error("ProductCluster.Serialize() error occurred: %s", err.Error())
+		return "", errors.NewChuxModelsError("ProductCluster.Serialize() error occurred", err)
+	}
+	return string(bytes), nil
+}
+
+func (pc *ProductCluster) Deserialize(jsonData []byte) error {
+	logging := pc.Logger
+	logging.Debug("ProductCluster.Deserialize() was called")
+	applyTimeConfig(pc.timeConfig, pc)
+	err := json.Unmarshal(jsonData, pc)
+	if err != nil {
+		logging.Error("ProductCluster.Deserialize() error occurred: %s", err.Error())
+		return errors.NewChuxModelsError("ProductCluster.Deserialize() error occurred", err)
+	}
+	return nil
+}
+
+// HasMember returns true when the Product is a member of the ProductCluster
+func (pc *ProductCluster) HasMember(productID primitive.ObjectID) bool {
+	return containsObjectID(pc.MemberIDs, productID)
+}
+
+// Confirm manually pins a Product to the ProductCluster. Confirmed
+// Products stay together on every later matching run.
+func (pc *ProductCluster) Confirm(productID primitive.ObjectID) {
+	logging := pc.Logger
+	logging.Debug("ProductCluster.Confirm() was called")
+	pc.ExcludedIDs = removeObjectID(pc.ExcludedIDs, productID)
+	if !containsObjectID(pc.ConfirmedIDs, productID) {
+		pc.ConfirmedIDs = append(pc.ConfirmedIDs, productID)
+	}
+	if !pc.HasMember(productID) {
+		pc.MemberIDs = append(pc.MemberIDs, productID)
+		pc.Members = append(pc.Members, ClusterMember{ProductID: productID, Method: MatchMethodManual, Confidence: 1})
+	}
+}
+
+// Split manually removes a Product from the ProductCluster. The Product
+// is never matched back into this ProductCluster on later matching runs.
+func (pc *ProductCluster) Split(productID primitive.ObjectID) {
+	logging := pc.Logger
+	logging.Debug("ProductCluster.Split() was called")
+	pc.ConfirmedIDs = removeObjectID(pc.ConfirmedIDs, productID)
+	pc.MemberIDs = removeObjectID(pc.MemberIDs, productID)
+	// -- New slices, the originalState shares the backing arrays of the loaded ones
+	members := make([]ClusterMember, 0, len(pc.Members))
+	for _, member := range pc.Members {
+		if member.ProductID != productID {
+			members = append(members, member)
+		}
+	}
+	pc.Members = members
+	if !containsObjectID(pc.ExcludedIDs, productID) {
+		pc.ExcludedIDs = append(pc.ExcludedIDs, productID)
+	}
+}
+
+func containsObjectID(ids []primitive.ObjectID, id primitive.ObjectID) bool {
+	for _, existing := range ids {
+		if existing == id {
+			return true
+		}
+	}
+	return false
+}
+
+func removeObjectID(ids []primitive.ObjectID, id primitive.ObjectID) []primitive.ObjectID {
+	kept := make([]primitive.ObjectID, 0, len(ids))
+	for _, existing := range ids {
+		if existing != id {
+			kept = append(kept, existing)
+		}
+	}
+	return kept
+}
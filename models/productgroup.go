@@ -0,0 +1,381 @@
+package models
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/chuxorg/chux-models/errors"
+	"github.com/chuxorg/chux-models/logging"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The ways variants can be grouped into a ProductGroup
+const (
+	GroupedByNameStem  = "nameStem"
+	GroupedByMPNFamily = "mpnFamily"
+	GroupedByProperty  = "additionalProperty"
+)
+
+// AdditionalProperty names, normalized, that describe a variant axis
+// mapped to the axis they belong to.
+var variantAxisNames = map[string]string{
+	"color":    "color",
+	"colour":   "color",
+	"size":     "size",
+	"style":    "style",
+	"width":    "width",
+	"length":   "length",
+	"fit":      "fit",
+	"material": "material",
+	"pattern":  "pattern",
+	"finish":   "finish",
+	"flavor":   "flavor",
+	"flavour":  "flavor",
+	"scent":    "scent",
+	"capacity": "capacity",
+	"storage":  "capacity",
+}
+
+// AdditionalProperty names, normalized, whose value identifies the parent
+// product shared by all of its variants.
+var variantParentNames = map[string]bool{
+	"item group id": true,
+	"parent sku":    true,
+	"parent id":     true,
+	"style number":  true,
+	"model":         true,
+	"model number":  true,
+}
+
+// Apparel sizes in the order they should be displayed
+var apparelSizes = []string{"xxs", "xs", "s", "m", "l", "xl", "xxl", "xxxl", "2xl", "3xl", "4xl"}
+
+// VariantAxis is a dimension a shopper selects, e.g. "size" with values S, M and L
+type VariantAxis struct {
+	Name   string   `bson:"name" json:"name"`
+	Values []string `bson:"values" json:"values"`
+}
+
+// ProductVariant is a single selectable Product of a ProductGroup
+type ProductVariant struct {
+	ProductID primitive.ObjectID `bson:"productId" json:"productId"`
+	URL       string             `bson:"url" json:"url"`
+	Name      string             `bson:"name" json:"name"`
+	MainImage string             `bson:"mainImage" json:"mainImage"`
+	Options   map[string]string  `bson:"options" json:"options"`
+	Offers    []Offer            `bson:"offers" json:"offers"`
+}
+
+// ProductGroup is one parent product of a company with its
+// selectable variants, e.g. a shirt in several colors and sizes.
+type ProductGroup struct {
+	Name        string           `bson:"name" json:"name"`
+	Brand       string           `bson:"brand,omitempty" json:"brand,omitempty"`
+	CompanyName string           `bson:"companyName" json:"companyName"`
+	GroupedBy   string           `bson:"groupedBy,omitempty" json:"groupedBy,omitempty"`
+	Axes        []VariantAxis    `bson:"axes" json:"axes"`
+	Variants    []ProductVariant `bson:"variants" json:"variants"`
+}
+
+// Variant returns the variant matching all of the given options
+func (g *ProductGroup) Variant(options map[string]string) (*ProductVariant, bool) {
+	for i := range g.Variants {
+		variant := &g.Variants[i]
+		matches := true
+		for axis, value := range options {
+			if !strings.EqualFold(variant.Options[axis], value) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return variant, true
+		}
+	}
+	return nil, false
+}
+
+// VariantOptions returns the variant options of the Product keyed by axis,
+// taken from Color, Style and the variant AdditionalProperties.
+func (p *Product) VariantOptions() map[string]string {
+	options := make(map[string]string)
+	if p.Color != "" {
+		options["color"] = strings.TrimSpace(p.Color)
+	}
+	if p.Style != "" {
+		options["style"] = strings.TrimSpace(p.Style)
+	}
+	for _, property := range p.AdditionalProperties {
+		axis, ok := variantAxisNames[NormalizeText(property.Name)]
+		if !ok || strings.TrimSpace(property.Value) == "" {
+			continue
+		}
+		if _, exists := options[axis]; !exists {
+			options[axis] = strings.TrimSpace(property.Value)
+		}
+	}
+	// -- Fall back to an apparel size at the end of the name, e.g. "Tee - XL".
+	// The whole last word must be the size, the "s" of "Levi's" is not one.
+	if _, ok := options["size"]; !ok {
+		fields := strings.Fields(p.Name)
+		if len(fields) > 1 {
+			last := strings.Trim(fields[len(fields)-1], "()[],-")
+			if isApparelSize(strings.ToLower(last)) {
+				options["size"] = last
+			}
+		}
+	}
+	return options
+}
+
+// GroupVariants groups Products into ProductGroups. Only Products of the
+// same company and brand are grouped, and they are joined when they share
+// a name once variant values are removed, an MPN family or a parent
+// AdditionalProperty such as "Item Group ID". Every Product ends up in
+// exactly one group, Products without variants form a group of their own.
+func GroupVariants(products []*Product) []*ProductGroup {
+	set := newDisjointSet(len(products))
+	groupedBy := make(map[int]string)
+	options := make([]map[string]string, len(products))
+	for i, product := range products {
+		options[i] = product.VariantOptions()
+	}
+
+	join := func(keys map[string][]int, method string) {
+		for _, members := range keys {
+			for _, i := range members[1:] {
+				rootA, rootB := set.find(members[0]), set.find(i)
+				if !set.union(members[0], i) {
+					continue
+				}
+				// -- Keep the strongest method that joined either side
+				joinedBy := method
+				if by, ok := groupedBy[rootA]; ok {
+					joinedBy = by
+				} else if by, ok := groupedBy[rootB]; ok {
+					joinedBy = by
+				}
+				groupedBy[set.find(i)] = joinedBy
+			}
+		}
+	}
+
+	blockKey := func(p *Product) string {
+		return p.CompanyName + "|" + NormalizeText(p.Brand)
+	}
+
+	byParent := make(map[string][]int)
+	byFamily := make(map[string][]int)
+	byStem := make(map[string][]int)
+	for i, product := range products {
+		block := blockKey(product)
+		for _, property := range product.AdditionalProperties {
+			if variantParentNames[NormalizeText(property.Name)] && strings.TrimSpace(property.Value) != "" {
+				key := block + "|" + NormalizeText(property.Name) + "=" + NormalizeText(property.Value)
+				byParent[key] = append(byParent[key], i)
+			}
+		}
+		if family := mpnFamily(product.MPN); family != "" {
+			byFamily[block+"|"+family] = append(byFamily[block+"|"+family], i)
+		}
+		// -- A name stem only groups Products that actually have variant options
+		if len(options[i]) > 0 {
+			if stem := NormalizeText(variantNameStem(product.Name, options[i])); stem != "" {
+				byStem[block+"|"+stem] = append(byStem[block+"|"+stem], i)
+			}
+		}
+	}
+	join(byParent, GroupedByProperty)
+	join(byFamily, GroupedByMPNFamily)
+	join(byStem, GroupedByNameStem)
+
+	components := make(map[int][]int)
+	roots := make([]int, 0)
+	for i := range products {
+		root := set.find(i)
+		if _, ok := components[root]; !ok {
+			roots = append(roots, root)
+		}
+		components[root] = append(components[root], i)
+	}
+
+	groups := make([]*ProductGroup, 0, len(roots))
+	for _, root := range roots {
+		members := components[root]
+		first := products[members[0]]
+		group := &ProductGroup{
+			Name:        variantNameStem(first.Name, options[members[0]]),
+			Brand:       first.Brand,
+			CompanyName: first.CompanyName,
+		}
+		if len(members) > 1 {
+			group.GroupedBy = groupedBy[root]
+		}
+
+		values := make(map[string]map[string]bool)
+		for _, i := range members {
+			for axis, value := range options[i] {
+				if values[axis] == nil {
+					values[axis] = make(map[string]bool)
+				}
+				values[axis][value] = true
+			}
+		}
+		// -- Only options that differ between variants are axes
+		axes := make(map[string]bool)
+		for axis, distinct := range values {
+			if len(distinct) < 2 {
+				continue
+			}
+			axes[axis] = true
+			axisValues := make([]string, 0, len(distinct))
+			for value := range distinct {
+				axisValues = append(axisValues, value)
+			}
+			sortVariantValues(axis, axisValues)
+			group.Axes = append(group.Axes, VariantAxis{Name: axis, Values: axisValues})
+		}
+		sort.Slice(group.Axes, func(i, j int) bool { return group.Axes[i].Name < group.Axes[j].Name })
+
+		for _, i := range members {
+			product := products[i]
+			variantOptions := make(map[string]string)
+			for axis, value := range options[i] {
+				if axes[axis] {
+					variantOptions[axis] = value
+				}
+			}
+			group.Variants = append(group.Variants, ProductVariant{
+				ProductID: product.ID,
+				URL:       product.URL,
+				Name:      product.Name,
+				MainImage: product.MainImage,
+				Options:   variantOptions,
+				Offers:    product.Offers,
+			})
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// GroupCompanyVariants loads the Products of a company and groups their variants
+func GroupCompanyVariants(logging logging.Logger, companyName string) ([]*ProductGroup, error) {
+	prd := NewProduct()
+	prd.Logger = &logging
+	docs, err := prd.Query("companyName", companyName)
+	if err != nil {
+		logging.Error("GroupCompanyVariants() Error querying products: %s", err.Error())
+		return nil, errors.NewChuxModelsError("GroupCompanyVariants() Error querying products", err)
+	}
+	products := make([]*Product, 0, len(docs))
+	for _, doc := range docs {
+		products = append(products, doc.(*Product))
+	}
+	groups := GroupVariants(products)
+	logging.Info("GroupCompanyVariants() Grouped %d products of %s into %d groups", len(products), companyName, len(groups))
+	return groups, nil
+}
+
+// Removes the variant option values from a name,
+// e.g. "Classic Tee - Red, XL" becomes "Classic Tee".
+func variantNameStem(name string, options map[string]string) string {
+	drop := make(map[string]bool)
+	for _, value := range options {
+		for _, token := range Tokenize(value) {
+			drop[token] = true
+		}
+	}
+	kept := make([]string, 0)
+	for _, word := range strings.Fields(name) {
+		tokens := Tokenize(word)
+		removable := len(tokens) > 0
+		for _, token := range tokens {
+			if !drop[token] {
+				removable = false
+				break
+			}
+		}
+		if !removable {
+			kept = append(kept, word)
+		}
+	}
+	stem := strings.Join(kept, " ")
+	return strings.TrimRight(stem, " -,/|:(")
+}
+
+// Returns the MPN without its trailing variant segments, e.g.
+// "AB1234-BLK-M" becomes "AB1234". Returns "" when the MPN has no family.
+func mpnFamily(mpn string) string {
+	segments := strings.FieldsFunc(strings.ToUpper(mpn), func(r rune) bool {
+		return r == '-' || r == '/' || r == '_' || r == ' ' || r == '.'
+	})
+	if len(segments) < 2 {
+		return ""
+	}
+	end := len(segments)
+	for end > 1 && len(segments[end-1]) <= 3 {
+		end--
+	}
+	if end == len(segments) || len(strings.Join(segments[:end], "")) < 4 {
+		return ""
+	}
+	return strings.Join(segments[:end], "-")
+}
+
+func isApparelSize(token string) bool {
+	for _, size := range apparelSizes {
+		if token == size {
+			return true
+		}
+	}
+	return false
+}
+
+// Parses the number at the start of a value, e.g. 10.5 from "10.5 W"
+func leadingNumber(value string) (float64, bool) {
+	value = strings.TrimSpace(value)
+	end := 0
+	for end < len(value) && (value[end] >= '0' && value[end] <= '9' || value[end] == '.') {
+		end++
+	}
+	if end == 0 {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(value[:end], 64)
+	return n, err == nil
+}
+
+// Sorts sizes from small to large and other values alphabetically
+func sortVariantValues(axis string, values []string) {
+	rank := func(value string) (int, float64, bool) {
+		normalized := NormalizeText(value)
+		for i, size := range apparelSizes {
+			if normalized == size {
+				return 0, float64(i), true
+			}
+		}
+		if n, ok := leadingNumber(value); ok {
+			return 1, n, true
+		}
+		return 2, 0, false
+	}
+	sort.SliceStable(values, func(i, j int) bool {
+		if axis == "size" || axis == "width" || axis == "length" || axis == "capacity" {
+			ci, ni, oki := rank(values[i])
+			cj, nj, okj := rank(values[j])
+			if oki && okj {
+				if ci != cj {
+					return ci < cj
+				}
+				if ni != nj {
+					return ni < nj
+				}
+			} else if oki != okj {
+				return oki
+			}
+		}
+		return strings.ToLower(values[i]) < strings.ToLower(values[j])
+	})
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestVariantOptionsSizeFromName(t *testing.T) {
+	tests := []struct {
+		name string
+		want map[string]string
+	}{
+		{"Classic Tee - XL", map[string]string{"size": "XL"}},
+		{"Classic Tee (m)", map[string]string{"size": "m"}},
+		{"Trucker Jacket Levi's", map[string]string{}},
+		{"Men's Boots", map[string]string{}},
+		{"XL", map[string]string{}},
+	}
+	for _, tt := range tests {
+		product := &Product{Name: tt.name}
+		if got := product.VariantOptions(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("VariantOptions() of %q = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
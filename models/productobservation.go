@@ -0,0 +1,338 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/chuxorg/chux-datastore/db"
+	dbl "github.com/chuxorg/chux-datastore/logging"
+	"github.com/chuxorg/chux-models/errors"
+	"github.com/chuxorg/chux-models/logging"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ObservedOffer is an Offer as it was at the time of a ProductObservation
+type ObservedOffer struct {
+	Price        string       `bson:"price" json:"price"`
+	PriceValue   float64      `bson:"priceValue,omitempty" json:"priceValue,omitempty"`
+	Currency     string       `bson:"currency" json:"currency"`
+	Availability Availability `bson:"availability" json:"availability"`
+}
+
+// ProductObservation records the Offers of a Product at a point in time.
+// Observations are append only, one is stored each time a Product is
+// saved with Offers that differ from the last ones seen. Observations
+// are keyed by CanonicalURL since that is what Products are upserted by.
+type ProductObservation struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	ProductID    primitive.ObjectID `bson:"productId,omitempty" json:"productId,omitempty"`
+	CanonicalURL string             `bson:"canonicalUrl" json:"canonicalUrl"`
+	CompanyName  string             `bson:"companyName" json:"companyName"`
+	ObservedAt   CustomTime         `bson:"observedAt" json:"observedAt"`
+	Offers       []ObservedOffer    `bson:"offers" json:"offers"`
+	// Availability of the Product as a whole, InStock when any Offer can be bought
+	Availability Availability      `bson:"availability" json:"availability"`
+	LowestPrice  float64           `bson:"lowestPrice,omitempty" json:"lowestPrice,omitempty"`
+	Currency     string            `bson:"currency,omitempty" json:"currency,omitempty"`
+	isNew        bool              `bson:"-" json:"-"`
+	timeConfig   *CustomTimeConfig `bson:"-" json:"-"`
+	Logger       *logging.Logger   `bson:"-" json:"-"`
+}
+
+func NewProductObservation(options ...func(*ProductObservation)) *ProductObservation {
+
+	o := &ProductObservation{}
+
+	for _, option := range options {
+		option(o)
+	}
+	dbLogger := dbl.NewLogger(dbl.LogLevelDebug)
+	mongoDB = db.New(
+		db.WithURI(o.GetURI()),
+		db.WithDatabaseName(o.GetDatabaseName()),
+		db.WithCollectionName(o.GetCollectionName()),
+		db.WithTimeout(30),
+		db.WithLogger(*dbLogger),
+	)
+
+	o.isNew = true
+	return o
+}
+
+func NewProductObservationWithLogger(logger logging.Logger) func(*ProductObservation) {
+	return func(o *ProductObservation) {
+		o.Logger = &logger
+	}
+}
+
+// Sets how the ProductObservation's CustomTimes are written to JSON and stored in Mongo,
+// without it they use the DefaultCustomTimeConfig
+func NewProductObservationWithTimeConfig(config CustomTimeConfig) func(*ProductObservation) {
+	return func(o *ProductObservation) {
+		o.timeConfig = &config
+	}
+}
+
+func (o *ProductObservation) GetCollectionName() string {
+	logging := o.Logger
+	logging.Debug("ProductObservation.GetCollectionName() was called")
+	return "productObservations"
+}
+
+func (o *ProductObservation) GetDatabaseName() string {
+	logging := o.Logger
+	logging.Debug("ProductObservation.GetDatabaseName() was called")
+	return os.Getenv("MONGO_DATABASE")
+}
+
+func (o *ProductObservation) GetURI() string {
+	logging := o.Logger
+	logging.Debug("ProductObservation.GetURI() was called")
+	username := os.Getenv("MONGO_USER_NAME")
+	password := os.Getenv("MONGO_PASSWORD")
+
+	uri := os.Getenv("MONGO_URI")
+	mongoURI := fmt.Sprintf(uri, username, password)
+	masked := fmt.Sprintf(uri, "********", "********")
+	logging.Info("Mongo URI: %s", masked)
+	return mongoURI
+}
+
+func (o *ProductObservation) GetID() primitive.ObjectID {
+	logging := o.Logger
+	logging.Debug("ProductObservation.GetID() was called")
+	return o.ID
+}
+
+func (o *ProductObservation) SetID(id primitive.ObjectID) {
+	logging := o.Logger
+	logging.Debug("ProductObservation.SetID() was called")
+	o.ID = id
+}
+
+// Observations are never changed once stored
+func (o *ProductObservation) IsDirty() bool {
+	return false
+}
+
+// When the Model is first created,
+// the model is considered New. After the model is
+// Saved or Loaded it is no longer New
+func (o *ProductObservation) IsNew() bool {
+	logging := o.Logger
+	logging.Debug("ProductObservation.IsNew() was called")
+	return o.isNew
+}
+
+// Saves a new ProductObservation to the Data Store. Stored
+// observations are immutable and saving them again is an error.
+func (o *ProductObservation) Save() error {
+	logging := o.Logger
+	logging.Debug("ProductObservation.Save() was called")
+	applyTimeConfig(o.timeConfig, o)
+	if !o.isNew {
+		logging.Error("ProductObservation.Save() ProductObservation %s is already stored", o.ID.Hex())
+		return errors.NewChuxModelsError("ProductObservation.Save() ProductObservations are append only", nil)
+	}
+	if o.ObservedAt.IsZero() {
+		o.ObservedAt.Now()
+	}
+	// -- Observations are only ever inserted, so give it an ID up front
+	if o.ID.IsZero() {
+		o.ID = primitive.NewObjectID()
+	}
+	err := mongoDB.Upsert(o)
+	if err != nil {
+		logging.Error("ProductObservation.Save() Error creating ProductObservation in MongoDB: %s", err.Error())
+		return errors.NewChuxModelsError("ProductObservation.Save() Error creating ProductObservation in MongoDB", err)
+	}
+	o.isNew = false
+	logging.Info("ProductObservation.Save() ProductObservation saved successfully")
+	return nil
+}
+
+// Loads a Model from MongoDB by id
+func (o *ProductObservation) Load(id string) (interface{}, error) {
+	logging := o.Logger
+	logging.Debug("ProductObservation.Load() was called")
+
+	retVal, err := mongoDB.GetByID(o, id)
+	if err != nil {
+		logging.Error("ProductObservation.Load() Error loading ProductObservation from MongoDB: %s", err.Error())
+		return nil, errors.NewChuxModelsError("ProductObservation.Load() Error loading ProductObservation from MongoDB", err)
+	}
+	observation, ok := retVal.(*ProductObservation)
+	if !ok {
+		logging.Error("ProductObservation.Load() unable to cast retVal to *ProductObservation")
+		return nil, errors.NewChuxModelsError("ProductObservation.Load() unable to cast retVal to *ProductObservation", nil)
+	}
+	observation.isNew = false
+	o.isNew = false
+	logging.Info("ProductObservation.Load() ProductObservation loaded successfully")
+	return retVal, nil
+}
+
+func (o *ProductObservation) Query(args ...interface{}) ([]db.IMongoDocument, error) {
+	logging := o.Logger
+	logging.Debug("ProductObservation.Query() was called")
+
+	results, err := mongoDB.Query(o, args...)
+	if err != nil {
+		logging.Error("ProductObservation.Query() Error occurred querying ProductObservations: %s", err.Error())
+		return nil, errors.NewChuxModelsError("ProductObservation.Query() Error occurred querying ProductObservations", err)
+	}
+	logging.Info("ProductObservation.Query() ProductObservations queried successfully")
+	return results, nil
+}
+
+// Observations are append only and cannot be deleted
+func (o *ProductObservation) Delete() error {
+	logging := o.Logger
+	logging.Debug("ProductObservation.Delete() was called")
+	return errors.NewChuxModelsError("ProductObservation.Delete() ProductObservations are append only", nil)
+}
+
+// Sets the internal state of the model of a new ProductObservation
+// from a JSON String.
+func (o *ProductObservation) Parse(json string) error {
+	logging := o.Logger
+	logging.Debug("ProductObservation.Parse() was called")
+	err := o.Deserialize([]byte(json))
+	if err != nil {
+		logging.Error("ProductObservation.Parse() error setting state")
+		return errors.NewChuxModelsError("ProductObservation.Parse() Error setting state", err)
+	}
+	o.isNew = true // this is a new model
+	return nil
+}
+
+func (o *ProductObservation) Search(args ...interface{}) ([]interface{}, error) {
+	logging := o.Logger
+	logging.Debug("ProductObservation.Search() was called")
+	return nil, nil
+}
+
+func (o *ProductObservation) Serialize() (string, error) {
+	logging := o.Logger
+	logging.Debug("ProductObservation.Serialize() was called")
+	applyTimeConfig(o.timeConfig, o)
+	bytes, err := json.Marshal(o)
+	if err != nil {
+		logging.Error("ProductObservation.Serialize() error occurred: %s", err.Error())
+		return "", errors.NewChuxModelsError("ProductObservation.Serialize() error occurred", err)
+	}
+	return string(bytes), nil
+}
+
+func (o *ProductObservation) Deserialize(jsonData []byte) error {
+	logging := o.Logger
+	logging.Debug("ProductObservation.Deserialize() was called")
+	applyTimeConfig(o.timeConfig, o)
+	err := json.Unmarshal(jsonData, o)
+	if err != nil {
+		logging.Error("ProductObservation.Deserialize() error occurred: %s", err.Error())
+		return errors.NewChuxModelsError("ProductObservation.Deserialize() error occurred", err)
+	}
+	return nil
+}
+
+// SameOffers returns true when the observation saw the same prices and
+// availability as offers, in the same order.
+func (o *ProductObservation) SameOffers(offers []Offer) bool {
+	if len(o.Offers) != len(offers) {
+		return false
+	}
+	for i, offer := range offers {
+		observed := o.Offers[i]
+		if observed.Price != offer.Price || observed.Currency != offer.Currency ||
+			observed.Availability != offer.Status() {
+			return false
+		}
+	}
+	return true
+}
+
+// Observe returns a new ProductObservation of the Product's current Offers
+func (p *Product) Observe() *ProductObservation {
+	observation := &ProductObservation{
+		ProductID:    p.ID,
+		CanonicalURL: p.CanonicalURL,
+		CompanyName:  p.CompanyName,
+		Availability: AvailabilityUnknown,
+		Logger:       p.Logger,
+		isNew:        true,
+	}
+	observation.ObservedAt.Now()
+	for _, offer := range p.Offers {
+		observed := ObservedOffer{
+			Price:        offer.Price,
+			Currency:     offer.Currency,
+			Availability: offer.Status(),
+		}
+		if value, err := ParsePrice(offer.Price); err == nil {
+			observed.PriceValue = value
+		}
+		observation.Offers = append(observation.Offers, observed)
+
+		// -- The Product is available when any Offer is, otherwise it
+		// takes the status of the first Offer whose status is known
+		switch {
+		case observed.Availability.IsAvailable():
+			observation.Availability = AvailabilityInStock
+		case observation.Availability == AvailabilityUnknown:
+			observation.Availability = observed.Availability
+		}
+	}
+
+	// -- The lowest price of the Offers that can be bought, or of all
+	// Offers when none can
+	for _, observed := range observation.Offers {
+		if observed.PriceValue <= 0 {
+			continue
+		}
+		if observation.Availability.IsAvailable() && !observed.Availability.IsAvailable() {
+			continue
+		}
+		if observation.LowestPrice == 0 || observed.PriceValue < observation.LowestPrice {
+			observation.LowestPrice = observed.PriceValue
+			observation.Currency = observed.Currency
+		}
+	}
+	return observation
+}
+
+// Records a ProductObservation when the Product's Offers differ from the
+// last observation. Called by Save() once the Product has been stored.
+func (p *Product) recordObservation(previous []Offer, known bool) error {
+	logging := p.Logger
+	logging.Debug("Product.recordObservation() was called")
+	observation := p.Observe()
+	if known {
+		if observation.SameOffers(previous) {
+			return nil
+		}
+	} else {
+		observations, err := queryProductObservations(logging, p.CanonicalURL)
+		if err != nil {
+			return err
+		}
+		if len(observations) > 0 && observations[len(observations)-1].SameOffers(p.Offers) {
+			return nil
+		}
+	}
+	return observation.Save()
+}
+
+// LastProductObservation returns the most recent ProductObservation
+// of a Product, or nil when the Product has never been observed.
+func LastProductObservation(logging logging.Logger, canonicalURL string) (*ProductObservation, error) {
+	observations, err := queryProductObservations(&logging, canonicalURL)
+	if err != nil {
+		return nil, err
+	}
+	if len(observations) == 0 {
+		return nil, nil
+	}
+	return observations[len(observations)-1], nil
+}
@@ -0,0 +1,191 @@
+package models
+
+import (
+	"sort"
+	"time"
+
+	"github.com/chuxorg/chux-models/logging"
+)
+
+// StockOut is a period during which a Product could not be bought
+type StockOut struct {
+	Start    time.Time     `json:"start"`
+	End      time.Time     `json:"end"`
+	Duration time.Duration `json:"duration"`
+	// True when the Product was still out of stock at the end of the window
+	Ongoing bool `json:"ongoing"`
+}
+
+// RestockEvent is a Product becoming available again after a StockOut
+type RestockEvent struct {
+	At            time.Time     `json:"at"`
+	OutOfStockFor time.Duration `json:"outOfStockFor"`
+	Price         float64       `json:"price,omitempty"`
+	Currency      string        `json:"currency,omitempty"`
+}
+
+// ProductTimeline is the ProductObservations of a Product, oldest first
+type ProductTimeline struct {
+	CanonicalURL string                `json:"canonicalUrl"`
+	Observations []*ProductObservation `json:"observations"`
+}
+
+// NewProductTimeline orders the observations of a Product by time
+func NewProductTimeline(canonicalURL string, observations []*ProductObservation) *ProductTimeline {
+	sorted := make([]*ProductObservation, len(observations))
+	copy(sorted, observations)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].ObservedAt.Before(sorted[j].ObservedAt.Time)
+	})
+	return &ProductTimeline{CanonicalURL: canonicalURL, Observations: sorted}
+}
+
+// LoadProductTimeline loads the ProductObservations of the Product with canonicalURL
+func LoadProductTimeline(logging logging.Logger, canonicalURL string) (*ProductTimeline, error) {
+	observations, err := queryProductObservations(&logging, canonicalURL)
+	if err != nil {
+		return nil, err
+	}
+	return NewProductTimeline(canonicalURL, observations), nil
+}
+
+// Timeline loads the ProductObservations of the Product
+func (p *Product) Timeline() (*ProductTimeline, error) {
+	logging := p.Logger
+	logging.Debug("Product.Timeline() was called")
+	observations, err := queryProductObservations(logging, p.CanonicalURL)
+	if err != nil {
+		return nil, err
+	}
+	return NewProductTimeline(p.CanonicalURL, observations), nil
+}
+
+// Queries the ProductObservations of a Product, oldest first
+func queryProductObservations(logging *logging.Logger, canonicalURL string) ([]*ProductObservation, error) {
+	obs := NewProductObservation()
+	obs.Logger = logging
+	docs, err := obs.Query("canonicalUrl", canonicalURL)
+	if err != nil {
+		return nil, err
+	}
+	observations := make([]*ProductObservation, 0, len(docs))
+	for _, doc := range docs {
+		observations = append(observations, doc.(*ProductObservation))
+	}
+	sort.SliceStable(observations, func(i, j int) bool {
+		return observations[i].ObservedAt.Before(observations[j].ObservedAt.Time)
+	})
+	return observations, nil
+}
+
+// A change in whether the Product could be bought
+type availabilityChange struct {
+	at          time.Time
+	available   bool
+	observation *ProductObservation
+}
+
+// Reduces the observations to the points where the Product became
+// available or unavailable. Observations with an unknown availability
+// leave the state as it was.
+func (t *ProductTimeline) changes() []availabilityChange {
+	var changes []availabilityChange
+	for _, observation := range t.Observations {
+		if observation.Availability == AvailabilityUnknown || observation.Availability == "" {
+			continue
+		}
+		available := observation.Availability.IsAvailable()
+		if len(changes) > 0 && changes[len(changes)-1].available == available {
+			continue
+		}
+		changes = append(changes, availabilityChange{
+			at:          observation.ObservedAt.Time,
+			available:   available,
+			observation: observation,
+		})
+	}
+	return changes
+}
+
+// StockOuts returns the periods between from and to during which the
+// Product was out of stock. Periods are clipped to the window.
+func (t *ProductTimeline) StockOuts(from, to time.Time) []StockOut {
+	var stockOuts []StockOut
+	changes := t.changes()
+	for i, change := range changes {
+		if change.available {
+			continue
+		}
+		start, end, ongoing := change.at, to, true
+		if i+1 < len(changes) {
+			end, ongoing = changes[i+1].at, false
+		}
+		if !end.After(from) || !start.Before(to) {
+			continue
+		}
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end, ongoing = to, true
+		}
+		stockOuts = append(stockOuts, StockOut{
+			Start:    start,
+			End:      end,
+			Duration: end.Sub(start),
+			Ongoing:  ongoing,
+		})
+	}
+	return stockOuts
+}
+
+// Restocks returns the times between from and to at which the
+// Product became available again after being out of stock.
+func (t *ProductTimeline) Restocks(from, to time.Time) []RestockEvent {
+	var restocks []RestockEvent
+	changes := t.changes()
+	for i := 1; i < len(changes); i++ {
+		change := changes[i]
+		if !change.available || change.at.Before(from) || change.at.After(to) {
+			continue
+		}
+		restocks = append(restocks, RestockEvent{
+			At:            change.at,
+			OutOfStockFor: change.at.Sub(changes[i-1].at),
+			Price:         change.observation.LowestPrice,
+			Currency:      change.observation.Currency,
+		})
+	}
+	return restocks
+}
+
+// Uptime returns the percentage of time between from and to that the
+// Product was available. Time before the first observation with a
+// known availability is not counted.
+func (t *ProductTimeline) Uptime(from, to time.Time) float64 {
+	changes := t.changes()
+	var known, available time.Duration
+	for i, change := range changes {
+		start, end := change.at, to
+		if i+1 < len(changes) {
+			end = changes[i+1].at
+		}
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if !end.After(start) {
+			continue
+		}
+		known += end.Sub(start)
+		if change.available {
+			available += end.Sub(start)
+		}
+	}
+	if known == 0 {
+		return 0
+	}
+	return roundTo(float64(available)/float64(known)*100, 2)
+}
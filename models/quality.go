@@ -0,0 +1,257 @@
+package models
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/chuxorg/chux-models/errors"
+	"github.com/chuxorg/chux-models/logging"
+)
+
+// QualityWeights sets how much each part of the score counts.
+// Weights are relative, they do not need to add up to 1.
+type QualityWeights struct {
+	// Share of the expected fields that are filled in
+	Completeness float64 `json:"completeness"`
+	// Valid GTINs, or at least an MPN or SKU
+	Identifiers float64 `json:"identifiers"`
+	// The extractor's Probability
+	Probability float64 `json:"probability"`
+	// Length of the Description
+	Description float64 `json:"description"`
+}
+
+// DefaultQualityWeights are used when a QualityScorer has no weights set
+var DefaultQualityWeights = QualityWeights{
+	Completeness: 0.4,
+	Identifiers:  0.2,
+	Probability:  0.2,
+	Description:  0.2,
+}
+
+// QualityScore is a Product's data quality from 0 to 100
+// and the parts it was computed from, each from 0 to 1.
+type QualityScore struct {
+	Score        float64  `bson:"score" json:"score"`
+	Completeness float64  `bson:"completeness" json:"completeness"`
+	Identifiers  float64  `bson:"identifiers" json:"identifiers"`
+	Probability  float64  `bson:"probability" json:"probability"`
+	Description  float64  `bson:"description" json:"description"`
+	Missing      []string `bson:"missing,omitempty" json:"missing,omitempty"`
+	Issues       []string `bson:"issues,omitempty" json:"issues,omitempty"`
+}
+
+// CompanyQuality is the data quality of the Products of one company
+type CompanyQuality struct {
+	CompanyName  string         `json:"companyName"`
+	Products     int            `json:"products"`
+	AverageScore float64        `json:"averageScore"`
+	MinScore     float64        `json:"minScore"`
+	MaxScore     float64        `json:"maxScore"`
+	Missing      map[string]int `json:"missing"`
+	Issues       map[string]int `json:"issues"`
+}
+
+// QualityScorer scores the data quality of Products
+type QualityScorer struct {
+	Weights QualityWeights
+	// Number of words at which a Description scores in full
+	DescriptionWords int
+	Logger           *logging.Logger
+}
+
+// Creates a NewQualityScorer with Options.
+// By default the DefaultQualityWeights are used and
+// a Description of 50 words scores in full.
+func NewQualityScorer(options ...func(*QualityScorer)) *QualityScorer {
+	s := &QualityScorer{
+		Weights:          DefaultQualityWeights,
+		DescriptionWords: 50,
+	}
+	for _, option := range options {
+		option(s)
+	}
+	if s.DescriptionWords < 1 {
+		s.DescriptionWords = 1
+	}
+	return s
+}
+
+func NewQualityScorerWithLogger(logger logging.Logger) func(*QualityScorer) {
+	return func(s *QualityScorer) {
+		s.Logger = &logger
+	}
+}
+
+// Sets the weights of the parts of the score
+func NewQualityScorerWithWeights(weights QualityWeights) func(*QualityScorer) {
+	return func(s *QualityScorer) {
+		s.Weights = weights
+	}
+}
+
+// Sets the number of words at which a Description scores in full
+func NewQualityScorerWithDescriptionWords(words int) func(*QualityScorer) {
+	return func(s *QualityScorer) {
+		s.DescriptionWords = words
+	}
+}
+
+// The fields a complete Product has and how to tell they are filled in
+var qualityFields = []struct {
+	name   string
+	filled func(p *Product) bool
+}{
+	{"name", func(p *Product) bool { return strings.TrimSpace(p.Name) != "" }},
+	{"mainImage", func(p *Product) bool { return strings.TrimSpace(p.MainImage) != "" }},
+	{"images", func(p *Product) bool { return len(p.Images) > 0 }},
+	{"brand", func(p *Product) bool { return strings.TrimSpace(p.Brand) != "" }},
+	{"offers", func(p *Product) bool { return hasPricedOffer(p.Offers) }},
+	{"gtins", func(p *Product) bool { return len(p.GTINs) > 0 }},
+	{"description", func(p *Product) bool { return strings.TrimSpace(p.Description) != "" }},
+	{"breadcrumbs", func(p *Product) bool { return len(p.Breadcrumbs) > 0 }},
+	{"additionalProperty", func(p *Product) bool { return len(p.AdditionalProperties) > 0 }},
+}
+
+func hasPricedOffer(offers []Offer) bool {
+	for _, offer := range offers {
+		if _, err := ParsePrice(offer.Price); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Score computes the QualityScore of a Product
+func (s *QualityScorer) Score(p *Product) QualityScore {
+	score := QualityScore{}
+
+	filled := 0
+	for _, field := range qualityFields {
+		if field.filled(p) {
+			filled++
+		} else {
+			score.Missing = append(score.Missing, field.name)
+		}
+	}
+	score.Completeness = float64(filled) / float64(len(qualityFields))
+
+	gtins := p.NormalizedGTINs()
+	switch {
+	case len(gtins) > 0:
+		score.Identifiers = 1
+	case strings.TrimSpace(p.MPN) != "" || strings.TrimSpace(p.SKU) != "":
+		score.Identifiers = 0.5
+	}
+	if len(gtins) < len(p.GTINs) {
+		score.Issues = append(score.Issues, "invalidGtin")
+	}
+
+	score.Probability = p.Probability
+	if score.Probability < 0 {
+		score.Probability = 0
+	} else if score.Probability > 1 {
+		score.Probability = 1
+	}
+
+	words := len(strings.Fields(p.Description))
+	score.Description = float64(minInt(words, s.DescriptionWords)) / float64(s.DescriptionWords)
+	if words > 0 && words < s.DescriptionWords/5 {
+		score.Issues = append(score.Issues, "shortDescription")
+	}
+
+	weights := s.Weights
+	total := weights.Completeness + weights.Identifiers + weights.Probability + weights.Description
+	if total <= 0 {
+		weights = DefaultQualityWeights
+		total = 1
+	}
+	weighted := weights.Completeness*score.Completeness +
+		weights.Identifiers*score.Identifiers +
+		weights.Probability*score.Probability +
+		weights.Description*score.Description
+	score.Score = roundTo(weighted/total*100, 2)
+	return score
+}
+
+// ScoreQuality sets the Product's Quality with the default QualityScorer
+func (p *Product) ScoreQuality() {
+	p.ScoreQualityWith(NewQualityScorer())
+}
+
+// ScoreQualityWith sets the Product's Quality with scorer
+func (p *Product) ScoreQualityWith(scorer *QualityScorer) {
+	logging := p.Logger
+	logging.Debug("Product.ScoreQualityWith() was called")
+	score := scorer.Score(p)
+	p.Quality = &score
+}
+
+// QualityReport aggregates the QualityScores of Products by CompanyName,
+// lowest average score first, so the sources that need the most
+// extractor work are at the top.
+func (s *QualityScorer) QualityReport(products []*Product) []CompanyQuality {
+	byCompany := make(map[string]*CompanyQuality)
+	for _, product := range products {
+		score := s.Score(product)
+		company, ok := byCompany[product.CompanyName]
+		if !ok {
+			company = &CompanyQuality{
+				CompanyName: product.CompanyName,
+				MinScore:    score.Score,
+				MaxScore:    score.Score,
+				Missing:     make(map[string]int),
+				Issues:      make(map[string]int),
+			}
+			byCompany[product.CompanyName] = company
+		}
+		company.Products++
+		company.AverageScore += score.Score
+		if score.Score < company.MinScore {
+			company.MinScore = score.Score
+		}
+		if score.Score > company.MaxScore {
+			company.MaxScore = score.Score
+		}
+		for _, field := range score.Missing {
+			company.Missing[field]++
+		}
+		for _, issue := range score.Issues {
+			company.Issues[issue]++
+		}
+	}
+
+	report := make([]CompanyQuality, 0, len(byCompany))
+	for _, company := range byCompany {
+		company.AverageScore = roundTo(company.AverageScore/float64(company.Products), 2)
+		report = append(report, *company)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].AverageScore != report[j].AverageScore {
+			return report[i].AverageScore < report[j].AverageScore
+		}
+		return report[i].CompanyName < report[j].CompanyName
+	})
+	return report
+}
+
+// LoadQualityReport scores all stored Products and aggregates
+// the scores by CompanyName.
+func (s *QualityScorer) LoadQualityReport() ([]CompanyQuality, error) {
+	logging := s.Logger
+	logging.Debug("QualityScorer.LoadQualityReport() was called")
+	prd := NewProduct()
+	prd.Logger = s.Logger
+	docs, err := prd.Query()
+	if err != nil {
+		logging.Error("QualityScorer.LoadQualityReport() Error querying products: %s", err.Error())
+		return nil, errors.NewChuxModelsError("QualityScorer.LoadQualityReport() Error querying products", err)
+	}
+	products := make([]*Product, 0, len(docs))
+	for _, doc := range docs {
+		products = append(products, doc.(*Product))
+	}
+	report := s.QualityReport(products)
+	logging.Info("QualityScorer.LoadQualityReport() Scored %d products from %d companies", len(products), len(report))
+	return report, nil
+}
@@ -0,0 +1,525 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/chuxorg/chux-datastore/db"
+	dbl "github.com/chuxorg/chux-datastore/logging"
+	"github.com/chuxorg/chux-models/errors"
+	"github.com/chuxorg/chux-models/logging"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The kinds of documents that can be quarantined
+const (
+	QuarantineKindProduct = "product"
+	QuarantineKindArticle = "article"
+)
+
+// ErrQuarantined is the inner error of the error returned by Save()
+// when a QuarantineGate routes a document into quarantine instead of
+// its collection. Check for it with errors.Is.
+var ErrQuarantined = errors.NewChuxModelsError("the document was quarantined", nil)
+
+// QuarantinedItem is a Product or Article that was held back from its
+// collection by a QuarantineGate, along with the reasons it was rejected.
+// The document is kept as JSON so it can be fixed and promoted.
+type QuarantinedItem struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	Kind          string             `bson:"kind" json:"kind"`
+	CanonicalURL  string             `bson:"canonicalUrl" json:"canonicalUrl"`
+	CompanyName   string             `bson:"companyName,omitempty" json:"companyName,omitempty"`
+	Probability   float64            `bson:"probability" json:"probability"`
+	Reasons       []string           `bson:"reasons" json:"reasons"`
+	Document      string             `bson:"document" json:"document"`
+	DateCreated   CustomTime         `bson:"dateCreated,omitempty" json:"dateCreated,omitempty"`
+	DateModified  CustomTime         `bson:"dateModified,omitempty" json:"dateModified,omitempty"`
+	isNew         bool               `bson:"-" json:"-"`
+	isDeleted     bool               `bson:"-" json:"-"`
+	isDirty       bool               `bson:"-" json:"-"`
+	originalState *QuarantinedItem   `bson:"-" json:"-"`
+	timeConfig    *CustomTimeConfig  `bson:"-" json:"-"`
+	Logger        *logging.Logger    `bson:"-" json:"-"`
+}
+
+func NewQuarantinedItem(options ...func(*QuarantinedItem)) *QuarantinedItem {
+
+	q := &QuarantinedItem{}
+
+	for _, option := range options {
+		option(q)
+	}
+	dbLogger := dbl.NewLogger(dbl.LogLevelDebug)
+	mongoDB = db.New(
+		db.WithURI(q.GetURI()),
+		db.WithDatabaseName(q.GetDatabaseName()),
+		db.WithCollectionName(q.GetCollectionName()),
+		db.WithTimeout(30),
+		db.WithLogger(*dbLogger),
+	)
+
+	q.isNew = true
+	q.isDeleted = false
+	q.isDirty = false
+	return q
+}
+
+func NewQuarantinedItemWithLogger(logger logging.Logger) func(*QuarantinedItem) {
+	return func(q *QuarantinedItem) {
+		q.Logger = &logger
+	}
+}
+
+// Sets how the QuarantinedItem's CustomTimes are written to JSON and stored in Mongo,
+// without it they use the DefaultCustomTimeConfig
+func NewQuarantinedItemWithTimeConfig(config CustomTimeConfig) func(*QuarantinedItem) {
+	return func(q *QuarantinedItem) {
+		q.timeConfig = &config
+	}
+}
+
+func (q *QuarantinedItem) GetCollectionName() string {
+	logging := q.Logger
+	logging.Debug("QuarantinedItem.GetCollectionName() was called")
+	return "quarantine"
+}
+
+func (q *QuarantinedItem) GetDatabaseName() string {
+	logging := q.Logger
+	logging.Debug("QuarantinedItem.GetDatabaseName() was called")
+	return os.Getenv("MONGO_DATABASE")
+}
+
+func (q *QuarantinedItem) GetURI() string {
+	logging := q.Logger
+	logging.Debug("QuarantinedItem.GetURI() was called")
+	username := os.Getenv("MONGO_USER_NAME")
+	password := os.Getenv("MONGO_PASSWORD")
+
+	uri := os.Getenv("MONGO_URI")
+	mongoURI := fmt.Sprintf(uri, username, password)
+	masked := fmt.Sprintf(uri, "********", "********")
+	logging.Info("Mongo URI: %s", masked)
+	return mongoURI
+}
+
+func (q *QuarantinedItem) GetID() primitive.ObjectID {
+	logging := q.Logger
+	logging.Debug("QuarantinedItem.GetID() was called")
+	return q.ID
+}
+
+func (q *QuarantinedItem) SetID(id primitive.ObjectID) {
+	logging := q.Logger
+	logging.Debug("QuarantinedItem.SetID() was called")
+	q.ID = id
+}
+
+// If the Model has changes, will return true
+func (q *QuarantinedItem) IsDirty() bool {
+	logging := q.Logger
+	logging.Debug("QuarantinedItem.IsDirty() was called")
+	if q.originalState == nil {
+		return false
+	}
+
+	originalBytes, err := q.originalState.Serialize()
+	if err != nil {
+		return false
+	}
+
+	currentBytes, err := q.Serialize()
+	if err != nil {
+		return false
+	}
+
+	q.isDirty = string(originalBytes) != string(currentBytes)
+	logging.Info("QuarantinedItem.IsDirty() isDirty: %t", q.isDirty)
+	return q.isDirty
+}
+
+// When the Model is first created,
+// the model is considered New. After the model is
+// Saved or Loaded it is no longer New
+func (q *QuarantinedItem) IsNew() bool {
+	logging := q.Logger
+	logging.Debug("QuarantinedItem.IsNew() was called")
+	return q.isNew
+}
+
+// Saves the Model to a Data Store
+func (q *QuarantinedItem) Save() error {
+	logging := q.Logger
+	logging.Debug("QuarantinedItem.Save() was called")
+	applyTimeConfig(q.timeConfig, q)
+	if q.isNew {
+		logging.Debug("QuarantinedItem.Save() QuarantinedItem is new")
+		// -- Set the date created to now
+		q.DateCreated.Now()
+		//-- Upsert document, a document quarantined again replaces the earlier item
+		err := mongoDB.Upsert(q, "kind", "canonicalUrl")
+		if err != nil {
+			logging.Error("QuarantinedItem.Save() Error creating QuarantinedItem in MongoDB: %s", err.Error())
+			return errors.NewChuxModelsError("QuarantinedItem.Save() Error creating QuarantinedItem in MongoDB", err)
+		}
+	} else if q.IsDirty() && !q.isDeleted {
+		logging.Debug("QuarantinedItem.Save() QuarantinedItem is dirty")
+		// Ensure the ID is a valid hex string representation of an ObjectID
+		_, err := primitive.ObjectIDFromHex(q.ID.Hex())
+		if err != nil {
+			logging.Error("QuarantinedItem.Save() invalid ObjectID: %s", err.Error())
+			return errors.NewChuxModelsError("QuarantinedItem.Save() invalid ObjectID", err)
+		}
+		// -- Set the date modified to now
+		q.DateModified.Now()
+		//--update this document
+		err = mongoDB.Update(q, q.ID.Hex())
+		if err != nil {
+			logging.Error("QuarantinedItem.Save() Error updating QuarantinedItem in MongoDB: %s", err.Error())
+			return errors.NewChuxModelsError("QuarantinedItem.Save() Error updating QuarantinedItem in MongoDB", err)
+		}
+	} else if q.isDeleted && !q.isNew {
+		logging.Info("QuarantinedItem.Save() QuarantinedItem is deleted")
+		//--delete the document
+		err := mongoDB.Delete(q, q.ID.Hex())
+		if err != nil {
+			logging.Error("QuarantinedItem.Save() Error deleting QuarantinedItem in MongoDB: %s", err.Error())
+			return errors.NewChuxModelsError("QuarantinedItem.Save() Error deleting QuarantinedItem in MongoDB", err)
+		}
+	}
+
+	// If the QuarantinedItem has been deleted, then this is a new QuarantinedItem
+	q.isNew = q.isDeleted
+	q.isDirty = q.IsDirty()
+	q.isDeleted = false
+
+	if q.isNew {
+		q.originalState = nil
+	} else {
+		//--reset state
+		serialized, err := q.Serialize()
+		if err != nil {
+			logging.Error("QuarantinedItem.Save() Error serializing QuarantinedItem: %s", err.Error())
+			return errors.NewChuxModelsError("QuarantinedItem.Save() Error serializing QuarantinedItem.", err)
+		}
+		q.SetState(serialized)
+	}
+
+	logging.Info("QuarantinedItem.Save() QuarantinedItem saved successfully")
+	return nil
+}
+
+// Loads a Model from MongoDB by id
+func (q *QuarantinedItem) Load(id string) (interface{}, error) {
+	logging := q.Logger
+	logging.Debug("QuarantinedItem.Load() was called")
+
+	retVal, err := mongoDB.GetByID(q, id)
+	if err != nil {
+		logging.Error("QuarantinedItem.Load() Error loading QuarantinedItem from MongoDB: %s", err.Error())
+		return nil, errors.NewChuxModelsError("QuarantinedItem.Load() Error loading QuarantinedItem from MongoDB", err)
+	}
+	item, ok := retVal.(*QuarantinedItem)
+	if !ok {
+		logging.Error("QuarantinedItem.Load() unable to cast retVal to *QuarantinedItem")
+		return nil, errors.NewChuxModelsError("QuarantinedItem.Load() unable to cast retVal to *QuarantinedItem", nil)
+	}
+	err = item.markLoaded()
+	if err != nil {
+		logging.Error("QuarantinedItem.Load() Error setting state: %s", err.Error())
+		return nil, errors.NewChuxModelsError("QuarantinedItem.Load() Error setting state", err)
+	}
+	logging.Info("QuarantinedItem.Load() QuarantinedItem loaded successfully")
+	return retVal, nil
+}
+
+func (q *QuarantinedItem) Query(args ...interface{}) ([]db.IMongoDocument, error) {
+	logging := q.Logger
+	logging.Debug("QuarantinedItem.Query() was called")
+
+	results, err := mongoDB.Query(q, args...)
+	if err != nil {
+		logging.Error("QuarantinedItem.Query() Error occurred querying QuarantinedItems: %s", err.Error())
+		return nil, errors.NewChuxModelsError("QuarantinedItem.Query() Error occurred querying QuarantinedItems", err)
+	}
+	logging.Info("QuarantinedItem.Query() QuarantinedItems queried successfully")
+	return results, nil
+}
+
+// Marks a Model for deletion from the Data Store
+// when Save() is called, the Model will be deleted
+func (q *QuarantinedItem) Delete() error {
+	logging := q.Logger
+	logging.Debug("QuarantinedItem.Delete() was called")
+	q.isDeleted = true
+	return nil
+}
+
+// Sets the internal state of the model.
+func (q *QuarantinedItem) SetState(json string) error {
+	logging := q.Logger
+	logging.Debug("QuarantinedItem.SetState() was called")
+	// Store the current state as the original state
+	original := &QuarantinedItem{}
+	*original = *q
+	q.originalState = original
+
+	// Deserialize the new state
+	return q.Deserialize([]byte(json))
+}
+
+// Marks a QuarantinedItem returned by Query() as loaded so that
+// changes made to it are persisted by Save()
+func (q *QuarantinedItem) markLoaded() error {
+	serialized, err := q.Serialize()
+	if err != nil {
+		return errors.NewChuxModelsError("QuarantinedItem.markLoaded() Error serializing QuarantinedItem", err)
+	}
+	q.SetState(serialized)
+	q.isNew = false
+	q.isDirty = false
+	q.isDeleted = false
+	return nil
+}
+
+// Sets the internal state of the model of a new QuarantinedItem
+// from a JSON String.
+func (q *QuarantinedItem) Parse(json string) error {
+	logging := q.Logger
+	logging.Debug("QuarantinedItem.Parse() was called")
+	err := q.SetState(json)
+	if err != nil {
+		logging.Error("QuarantinedItem.Parse() error setting state")
+		return errors.NewChuxModelsError("QuarantinedItem.Parse() Error setting state", err)
+	}
+	q.isNew = true // this is a new model
+	return nil
+}
+
+func (q *QuarantinedItem) Search(args ...interface{}) ([]interface{}, error) {
+	logging := q.Logger
+	logging.Debug("QuarantinedItem.Search() was called")
+	return nil, nil
+}
+
+func (q *QuarantinedItem) Serialize() (string, error) {
+	logging := q.Logger
+	logging.Debug("QuarantinedItem.Serialize() was called")
+	applyTimeConfig(q.timeConfig, q)
+	bytes, err := json.Marshal(q)
+	if err != nil {
+		logging.Error("QuarantinedItem.Serialize() error occurred: %s", err.Error())
+		return "", errors.NewChuxModelsError("QuarantinedItem.Serialize() error occurred", err)
+	}
+	return string(bytes), nil
+}
+
+func (q *QuarantinedItem) Deserialize(jsonData []byte) error {
+	logging := q.Logger
+	logging.Debug("QuarantinedItem.Deserialize() was called")
+	applyTimeConfig(q.timeConfig, q)
+	err := json.Unmarshal(jsonData, q)
+	if err != nil {
+		logging.Error("QuarantinedItem.Deserialize() error occurred: %s", err.Error())
+		return errors.NewChuxModelsError("QuarantinedItem.Deserialize() error occurred", err)
+	}
+	return nil
+}
+
+// QuarantineGate decides whether a document is saved to its collection
+// or quarantined. Documents are quarantined when their Probability is
+// below MinProbability or, with RequireValid set, when they fail Validate().
+type QuarantineGate struct {
+	MinProbability float64
+	RequireValid   bool
+	Logger         *logging.Logger
+}
+
+// Creates a NewQuarantineGate with Options.
+// By default documents below a Probability of 0.5 or that fail validation are quarantined.
+func NewQuarantineGate(options ...func(*QuarantineGate)) *QuarantineGate {
+	g := &QuarantineGate{
+		MinProbability: 0.5,
+		RequireValid:   true,
+	}
+	for _, option := range options {
+		option(g)
+	}
+	return g
+}
+
+func NewQuarantineGateWithLogger(logger logging.Logger) func(*QuarantineGate) {
+	return func(g *QuarantineGate) {
+		g.Logger = &logger
+	}
+}
+
+// Sets the Probability below which documents are quarantined
+func NewQuarantineGateWithMinProbability(probability float64) func(*QuarantineGate) {
+	return func(g *QuarantineGate) {
+		g.MinProbability = probability
+	}
+}
+
+// Sets whether documents that fail validation are quarantined
+func NewQuarantineGateWithRequireValid(requireValid bool) func(*QuarantineGate) {
+	return func(g *QuarantineGate) {
+		g.RequireValid = requireValid
+	}
+}
+
+// Reasons returns why a document with probability and the validation
+// problems found in it should be quarantined. None means it may be saved.
+func (g *QuarantineGate) Reasons(probability float64, problems []string) []string {
+	var reasons []string
+	if probability < g.MinProbability {
+		reasons = append(reasons, fmt.Sprintf("probability %.2f is below %.2f", probability, g.MinProbability))
+	}
+	if g.RequireValid {
+		reasons = append(reasons, problems...)
+	}
+	return reasons
+}
+
+// Stores a document that did not pass a QuarantineGate
+func quarantine(logging *logging.Logger, kind, canonicalURL, companyName string, probability float64, reasons []string, document string) error {
+	item := &QuarantinedItem{
+		Kind:         kind,
+		CanonicalURL: canonicalURL,
+		CompanyName:  companyName,
+		Probability:  probability,
+		Reasons:      reasons,
+		Document:     document,
+		Logger:       logging,
+		isNew:        true,
+	}
+	return item.Save()
+}
+
+// ListQuarantined returns the QuarantinedItems of a kind,
+// or of every kind when kind is empty.
+func ListQuarantined(logging logging.Logger, kind string) ([]*QuarantinedItem, error) {
+	item := NewQuarantinedItem(NewQuarantinedItemWithLogger(logging))
+	var args []interface{}
+	if kind != "" {
+		args = append(args, "kind", kind)
+	}
+	docs, err := item.Query(args...)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*QuarantinedItem, 0, len(docs))
+	for _, doc := range docs {
+		quarantined := doc.(*QuarantinedItem)
+		quarantined.Logger = &logging
+		if err := quarantined.markLoaded(); err != nil {
+			return nil, err
+		}
+		items = append(items, quarantined)
+	}
+	logging.Info("ListQuarantined() Found %d quarantined items", len(items))
+	return items, nil
+}
+
+// InspectQuarantined loads a QuarantinedItem by id
+func InspectQuarantined(logging logging.Logger, id string) (*QuarantinedItem, error) {
+	item := NewQuarantinedItem(NewQuarantinedItemWithLogger(logging))
+	retVal, err := item.Load(id)
+	if err != nil {
+		return nil, err
+	}
+	quarantined := retVal.(*QuarantinedItem)
+	quarantined.Logger = &logging
+	return quarantined, nil
+}
+
+// Product decodes the quarantined document as a new Product
+func (q *QuarantinedItem) Product() (*Product, error) {
+	if q.Kind != QuarantineKindProduct {
+		msg := fmt.Sprintf("QuarantinedItem.Product() item is a %s", q.Kind)
+		return nil, errors.NewChuxModelsError(msg, nil)
+	}
+	product := &Product{Logger: q.Logger}
+	if err := product.Parse(q.Document); err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+// Article decodes the quarantined document as a new Article
+func (q *QuarantinedItem) Article() (*Article, error) {
+	if q.Kind != QuarantineKindArticle {
+		msg := fmt.Sprintf("QuarantinedItem.Article() item is a %s", q.Kind)
+		return nil, errors.NewChuxModelsError(msg, nil)
+	}
+	article := &Article{Logger: q.Logger}
+	if err := article.Parse(q.Document); err != nil {
+		return nil, err
+	}
+	return article, nil
+}
+
+// Fix replaces the quarantined document with a corrected JSON document and
+// recomputes the reasons it is held back with gate. Save() persists the fix.
+func (q *QuarantinedItem) Fix(document string, gate *QuarantineGate) error {
+	logging := q.Logger
+	logging.Debug("QuarantinedItem.Fix() was called")
+	q.Document = document
+	switch q.Kind {
+	case QuarantineKindProduct:
+		product, err := q.Product()
+		if err != nil {
+			return err
+		}
+		q.CanonicalURL = product.CanonicalURL
+		q.Probability = product.Probability
+		q.Reasons = gate.Reasons(product.Probability, product.Validate())
+	case QuarantineKindArticle:
+		article, err := q.Article()
+		if err != nil {
+			return err
+		}
+		q.CanonicalURL = article.CanonicalURL
+		q.Probability = article.Probability
+		q.Reasons = gate.Reasons(article.Probability, article.Validate())
+	default:
+		msg := fmt.Sprintf("QuarantinedItem.Fix() unknown kind %s", q.Kind)
+		return errors.NewChuxModelsError(msg, nil)
+	}
+	return nil
+}
+
+// Promote saves the quarantined document into its collection, whatever
+// its remaining reasons, and removes the QuarantinedItem.
+func (q *QuarantinedItem) Promote() error {
+	logging := q.Logger
+	logging.Debug("QuarantinedItem.Promote() was called")
+	var err error
+	switch q.Kind {
+	case QuarantineKindProduct:
+		var product *Product
+		if product, err = q.Product(); err == nil {
+			err = product.Save()
+		}
+	case QuarantineKindArticle:
+		var article *Article
+		if article, err = q.Article(); err == nil {
+			err = article.Save()
+		}
+	default:
+		err = errors.NewChuxModelsError(fmt.Sprintf("unknown kind %s", q.Kind), nil)
+	}
+	if err != nil {
+		logging.Error("QuarantinedItem.Promote() Error saving %s: %s", q.Kind, err.Error())
+		return errors.NewChuxModelsError("QuarantinedItem.Promote() Error saving the quarantined document", err)
+	}
+	q.Delete()
+	err = q.Save()
+	if err != nil {
+		return errors.NewChuxModelsError("QuarantinedItem.Promote() Document was saved but the QuarantinedItem was not removed", err)
+	}
+	logging.Info("QuarantinedItem.Promote() Promoted %s %s", q.Kind, q.CanonicalURL)
+	return nil
+}
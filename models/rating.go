@@ -0,0 +1,107 @@
+package models
+
+import (
+	"github.com/chuxorg/chux-models/errors"
+)
+
+// The scale ratings are normalized to
+const RatingScale = 5.0
+
+// NormalizeRating converts a rating given out of best to the 0 to 5
+// scale. When best is unknown it is inferred from the rating, as sites
+// rate out of 5, 10 or 100.
+func NormalizeRating(value, best float64) float64 {
+	if value <= 0 {
+		return 0
+	}
+	if best <= 0 {
+		switch {
+		case value <= 5:
+			best = 5
+		case value <= 10:
+			best = 10
+		default:
+			best = 100
+		}
+	}
+	normalized := value / best * RatingScale
+	if normalized > RatingScale {
+		normalized = RatingScale
+	}
+	return roundTo(normalized, 2)
+}
+
+// Normalized returns the AggregateRating on the 0 to 5 scale
+func (a AggregateRating) Normalized() AggregateRating {
+	value := NormalizeRating(a.RatingValue, a.BestRating)
+	return AggregateRating{
+		RatingValue:     value,
+		BestRating:      RatingScale,
+		ReviewCount:     a.ReviewCount,
+		NormalizedValue: value,
+	}
+}
+
+// AggregateReviews computes an AggregateRating on the 0 to 5 scale
+// from reviews. Reviews without a rating are not counted.
+func AggregateReviews(reviews []*Review) AggregateRating {
+	total := 0.0
+	count := 0
+	for _, review := range reviews {
+		rating := NormalizeRating(review.Rating, review.BestRating)
+		if rating <= 0 {
+			continue
+		}
+		total += rating
+		count++
+	}
+	if count == 0 {
+		return AggregateRating{BestRating: RatingScale}
+	}
+	value := roundTo(total/float64(count), 2)
+	return AggregateRating{
+		RatingValue:     value,
+		BestRating:      RatingScale,
+		ReviewCount:     count,
+		NormalizedValue: value,
+	}
+}
+
+// Reviews loads the stored Reviews of the Product
+func (p *Product) Reviews() ([]*Review, error) {
+	logging := p.Logger
+	logging.Debug("Product.Reviews() was called")
+	if p.ID.IsZero() {
+		return nil, errors.NewChuxModelsError("Product.Reviews() Product has not been saved", nil)
+	}
+	rev := NewReview()
+	rev.Logger = p.Logger
+	docs, err := rev.Query("productId", p.ID)
+	if err != nil {
+		logging.Error("Product.Reviews() Error querying reviews: %s", err.Error())
+		return nil, errors.NewChuxModelsError("Product.Reviews() Error querying reviews", err)
+	}
+	reviews := make([]*Review, 0, len(docs))
+	for _, doc := range docs {
+		reviews = append(reviews, doc.(*Review))
+	}
+	return reviews, nil
+}
+
+// RecomputeAggregateRating replaces the Product's AggregateRating with
+// one computed from its stored Reviews. The Product must be saved for the
+// change to be persisted.
+func (p *Product) RecomputeAggregateRating() error {
+	logging := p.Logger
+	logging.Debug("Product.RecomputeAggregateRating() was called")
+	reviews, err := p.Reviews()
+	if err != nil {
+		return err
+	}
+	if len(reviews) == 0 {
+		logging.Info("Product.RecomputeAggregateRating() Product has no reviews, keeping the scraped rating")
+		return nil
+	}
+	p.AggregateRating = AggregateReviews(reviews)
+	return nil
+}
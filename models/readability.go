@@ -0,0 +1,205 @@
+package models
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// The average silent reading speed of adults, used for reading times
+var WordsPerMinute = 238
+
+// Lines of text, sentences never run across a line break
+var lineBreakRegex = regexp.MustCompile(`\s*\n\s*`)
+
+// Words that end with a period without ending the sentence
+var sentenceAbbreviations = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "dr": true, "prof": true, "sr": true, "jr": true,
+	"st": true, "mt": true, "vs": true, "etc": true, "inc": true, "ltd": true, "co": true,
+	"corp": true, "no": true, "nos": true, "fig": true, "vol": true, "approx": true, "dept": true,
+	"e.g": true, "i.e": true, "u.s": true, "u.k": true, "a.m": true, "p.m": true,
+	"jan": true, "feb": true, "mar": true, "apr": true, "jun": true, "jul": true, "aug": true,
+	"sep": true, "sept": true, "oct": true, "nov": true, "dec": true,
+}
+
+// Closing punctuation that belongs to the sentence it follows
+const sentenceClosers = `.!?"')]”’»`
+
+// ReadingStats are the length and readability of a text. The
+// Flesch-Kincaid scores are calibrated for English text.
+type ReadingStats struct {
+	Words     int `bson:"words" json:"words"`
+	Sentences int `bson:"sentences" json:"sentences"`
+	Syllables int `bson:"syllables" json:"syllables"`
+	// Minutes to read the text at WordsPerMinute, rounded up
+	ReadingMinutes int `bson:"readingMinutes" json:"readingMinutes"`
+	// The U.S. school grade needed to understand the text
+	FleschKincaidGrade float64 `bson:"fleschKincaidGrade" json:"fleschKincaidGrade"`
+	// From 0, very hard to read, to 100, very easy to read
+	FleschReadingEase float64 `bson:"fleschReadingEase" json:"fleschReadingEase"`
+}
+
+// ReadingTime is the time to read the text at WordsPerMinute
+func (s ReadingStats) ReadingTime() time.Duration {
+	return time.Duration(float64(s.Words) / float64(WordsPerMinute) * float64(time.Minute)).Round(time.Second)
+}
+
+// ComputeReadingStats counts the words, sentences and syllables
+// of a plain text and scores its readability with Flesch-Kincaid.
+func ComputeReadingStats(text string) ReadingStats {
+	stats := ReadingStats{}
+	for _, sentence := range SplitSentences(text) {
+		words := sentenceWords(sentence)
+		if len(words) == 0 {
+			continue
+		}
+		stats.Sentences++
+		stats.Words += len(words)
+		for _, word := range words {
+			stats.Syllables += countSyllables(word)
+		}
+	}
+	if stats.Words == 0 {
+		return stats
+	}
+
+	stats.ReadingMinutes = int(math.Ceil(float64(stats.Words) / float64(WordsPerMinute)))
+	wordsPerSentence := float64(stats.Words) / float64(stats.Sentences)
+	syllablesPerWord := float64(stats.Syllables) / float64(stats.Words)
+	grade := 0.39*wordsPerSentence + 11.8*syllablesPerWord - 15.59
+	ease := 206.835 - 1.015*wordsPerSentence - 84.6*syllablesPerWord
+	stats.FleschKincaidGrade = math.Round(math.Max(grade, 0)*10) / 10
+	stats.FleschReadingEase = math.Round(math.Max(math.Min(ease, 100), 0)*10) / 10
+	return stats
+}
+
+// SplitSentences splits a plain text into sentences. A sentence ends
+// at a line break or at a ".", "!" or "?" that is followed by a space
+// and is not part of an abbreviation, an initial or a lower case
+// continuation, or at a CJK full stop.
+func SplitSentences(text string) []string {
+	var sentences []string
+	add := func(runes []rune) {
+		if sentence := strings.TrimSpace(string(runes)); sentence != "" {
+			sentences = append(sentences, sentence)
+		}
+	}
+	for _, line := range lineBreakRegex.Split(text, -1) {
+		runes := []rune(strings.Join(strings.Fields(line), " "))
+		start := 0
+		for i := 0; i < len(runes); i++ {
+			r := runes[i]
+			if r == '。' || r == '！' || r == '？' {
+				add(runes[start : i+1])
+				start = i + 1
+				continue
+			}
+			if r != '.' && r != '!' && r != '?' {
+				continue
+			}
+			end := i + 1
+			for end < len(runes) && strings.ContainsRune(sentenceClosers, runes[end]) {
+				end++
+			}
+			// -- Numbers, URLs, abbreviations and initials do not end a sentence
+			atBoundary := end == len(runes) || runes[end] == ' '
+			if !atBoundary || (r == '.' && isAbbreviation(runes[start:i])) {
+				i = end - 1
+				continue
+			}
+			if end+1 < len(runes) && unicode.IsLower(runes[end+1]) {
+				i = end - 1
+				continue
+			}
+			add(runes[start:end])
+			start = end
+			i = end - 1
+		}
+		add(runes[start:])
+	}
+	return sentences
+}
+
+// Whether the word before a period is an abbreviation or an initial
+func isAbbreviation(before []rune) bool {
+	text := string(before)
+	if space := strings.LastIndex(text, " "); space >= 0 {
+		text = text[space+1:]
+	}
+	word := strings.ToLower(strings.TrimLeft(text, `"'(“‘«`))
+	if sentenceAbbreviations[word] {
+		return true
+	}
+	letters := []rune(word)
+	return len(letters) == 1 && unicode.IsLetter(letters[0])
+}
+
+// Returns the words of a sentence, CJK characters are counted as
+// words since those scripts do not separate words with spaces
+func sentenceWords(sentence string) []string {
+	var words []string
+	for _, field := range strings.Fields(sentence) {
+		var word strings.Builder
+		for _, r := range field {
+			if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana) {
+				if word.Len() > 0 {
+					words = append(words, word.String())
+					word.Reset()
+				}
+				words = append(words, string(r))
+				continue
+			}
+			if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\'' || r == '-' {
+				word.WriteRune(r)
+			}
+		}
+		if w := strings.Trim(word.String(), "'-"); w != "" {
+			words = append(words, w)
+		}
+	}
+	return words
+}
+
+// Estimates the syllables of an English word by counting groups
+// of vowels, leaving out a silent "e" or "ed" at the end
+func countSyllables(word string) int {
+	var b strings.Builder
+	for _, r := range foldReplacer.Replace(strings.ToLower(word)) {
+		if r >= 'a' && r <= 'z' {
+			b.WriteRune(r)
+		}
+	}
+	letters := b.String()
+	if len(letters) <= 3 {
+		return 1
+	}
+
+	count := 0
+	previousVowel := false
+	for i := 0; i < len(letters); i++ {
+		vowel := strings.IndexByte("aeiouy", letters[i]) >= 0
+		if vowel && !previousVowel {
+			count++
+		}
+		previousVowel = vowel
+	}
+	switch {
+	case strings.HasSuffix(letters, "e") && !strings.HasSuffix(letters, "le"):
+		count--
+	case strings.HasSuffix(letters, "ed") && !strings.HasSuffix(letters, "ted") && !strings.HasSuffix(letters, "ded"):
+		count--
+	}
+	if count < 1 {
+		count = 1
+	}
+	return count
+}
+
+// ReadingStats computes the ReadingStats of the Article's ArticleBody
+func (a *Article) ReadingStats() ReadingStats {
+	logging := a.Logger
+	logging.Debug("Article.ReadingStats() was called")
+	return ComputeReadingStats(a.ArticleBody)
+}
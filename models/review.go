@@ -0,0 +1,339 @@
+package models
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/chuxorg/chux-datastore/db"
+	dbl "github.com/chuxorg/chux-datastore/logging"
+	"github.com/chuxorg/chux-models/errors"
+	"github.com/chuxorg/chux-models/logging"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Review is a single customer review of a Product
+type Review struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	ProductID primitive.ObjectID `bson:"productId" json:"productId"`
+	// Identifies the review across scrapes, see Key()
+	ReviewKey     string     `bson:"reviewKey" json:"reviewKey"`
+	Author        string     `bson:"author" json:"author"`
+	Rating        float64    `bson:"rating" json:"rating"`
+	BestRating    float64    `bson:"bestRating,omitempty" json:"bestRating,omitempty"`
+	Title         string     `bson:"title,omitempty" json:"title,omitempty"`
+	Body          string     `bson:"body" json:"body"`
+	DatePublished CustomTime `bson:"datePublished,omitempty" json:"datePublished,omitempty"`
+	Verified      bool       `bson:"verified" json:"verified"`
+	// Rating on a 0 to 5 scale, set on Save()
+	NormalizedRating float64           `bson:"normalizedRating" json:"normalizedRating"`
+	DateCreated      CustomTime        `bson:"dateCreated,omitempty" json:"dateCreated,omitempty"`
+	DateModified     CustomTime        `bson:"dateModified,omitempty" json:"dateModified,omitempty"`
+	isNew            bool              `bson:"-" json:"-"`
+	isDeleted        bool              `bson:"-" json:"-"`
+	isDirty          bool              `bson:"-" json:"-"`
+	originalState    *Review           `bson:"-" json:"-"`
+	timeConfig       *CustomTimeConfig `bson:"-" json:"-"`
+	Logger           *logging.Logger   `bson:"-" json:"-"`
+}
+
+func NewReview(options ...func(*Review)) *Review {
+
+	r := &Review{}
+
+	for _, option := range options {
+		option(r)
+	}
+	dbLogger := dbl.NewLogger(dbl.LogLevelDebug)
+	mongoDB = db.New(
+		db.WithURI(r.GetURI()),
+		db.WithDatabaseName(r.GetDatabaseName()),
+		db.WithCollectionName(r.GetCollectionName()),
+		db.WithTimeout(30),
+		db.WithLogger(*dbLogger),
+	)
+
+	r.isNew = true
+	r.isDeleted = false
+	r.isDirty = false
+	return r
+}
+
+func NewReviewWithLogger(logger logging.Logger) func(*Review) {
+	return func(r *Review) {
+		r.Logger = &logger
+	}
+}
+
+// Sets how the Review's CustomTimes are written to JSON and stored in Mongo,
+// without it they use the DefaultCustomTimeConfig
+func NewReviewWithTimeConfig(config CustomTimeConfig) func(*Review) {
+	return func(r *Review) {
+		r.timeConfig = &config
+	}
+}
+
+func (r *Review) GetCollectionName() string {
+	logging := r.Logger
+	logging.Debug("Review.GetCollectionName() was called")
+	return "reviews"
+}
+
+func (r *Review) GetDatabaseName() string {
+	logging := r.Logger
+	logging.Debug("Review.GetDatabaseName() was called")
+	return os.Getenv("MONGO_DATABASE")
+}
+
+func (r *Review) GetURI() string {
+	logging := r.Logger
+	logging.Debug("Review.GetURI() was called")
+	username := os.Getenv("MONGO_USER_NAME")
+	password := os.Getenv("MONGO_PASSWORD")
+
+	uri := os.Getenv("MONGO_URI")
+	mongoURI := fmt.Sprintf(uri, username, password)
+	masked := fmt.Sprintf(uri, "********", "********")
+	logging.Info("Mongo URI: %s", masked)
+	return mongoURI
+}
+
+func (r *Review) GetID() primitive.ObjectID {
+	logging := r.Logger
+	logging.Debug("Review.GetID() was called")
+	return r.ID
+}
+
+func (r *Review) SetID(id primitive.ObjectID) {
+	logging := r.Logger
+	logging.Debug("Review.SetID() was called")
+	r.ID = id
+}
+
+// If the Model has changes, will return true
+func (r *Review) IsDirty() bool {
+	logging := r.Logger
+	logging.Debug("Review.IsDirty() was called")
+	if r.originalState == nil {
+		return false
+	}
+
+	originalBytes, err := r.originalState.Serialize()
+	if err != nil {
+		return false
+	}
+
+	currentBytes, err := r.Serialize()
+	if err != nil {
+		return false
+	}
+
+	r.isDirty = string(originalBytes) != string(currentBytes)
+	logging.Info("Review.IsDirty() isDirty: %t", r.isDirty)
+	return r.isDirty
+}
+
+// When the Model is first created,
+// the model is considered New. After the model is
+// Saved or Loaded it is no longer New
+func (r *Review) IsNew() bool {
+	logging := r.Logger
+	logging.Debug("Review.IsNew() was called")
+	return r.isNew
+}
+
+// Saves the Model to a Data Store
+func (r *Review) Save() error {
+	logging := r.Logger
+	logging.Debug("Review.Save() was called")
+	applyTimeConfig(r.timeConfig, r)
+	if !r.isDeleted {
+		r.NormalizedRating = NormalizeRating(r.Rating, r.BestRating)
+	}
+	if r.isNew {
+		logging.Debug("Review.Save() Review is new")
+		// -- Set the date created to now
+		r.DateCreated.Now()
+		//-- Upsert document, a Review scraped again is matched by its key
+		if r.ReviewKey == "" {
+			r.ReviewKey = r.Key()
+		}
+		err := mongoDB.Upsert(r, "reviewKey")
+		if err != nil {
+			logging.Error("Review.Save() Error creating Review in MongoDB: %s", err.Error())
+			return errors.NewChuxModelsError("Review.Save() Error creating Review in MongoDB", err)
+		}
+	} else if r.IsDirty() && !r.isDeleted {
+		logging.Debug("Review.Save() Review is dirty")
+		// Ensure the ID is a valid hex string representation of an ObjectID
+		_, err := primitive.ObjectIDFromHex(r.ID.Hex())
+		if err != nil {
+			logging.Error("Review.Save() invalid ObjectID: %s", err.Error())
+			return errors.NewChuxModelsError("Review.Save() invalid ObjectID", err)
+		}
+		// -- Set the date modified to now
+		r.DateModified.Now()
+		//--update this document
+		err = mongoDB.Update(r, r.ID.Hex())
+		if err != nil {
+			logging.Error("Review.Save() Error updating Review in MongoDB: %s", err.Error())
+			return errors.NewChuxModelsError("Review.Save() Error updating Review in MongoDB", err)
+		}
+	} else if r.isDeleted && !r.isNew {
+		logging.Info("Review.Save() Review is deleted")
+		//--delete the document
+		err := mongoDB.Delete(r, r.ID.Hex())
+		if err != nil {
+			logging.Error("Review.Save() Error deleting Review in MongoDB: %s", err.Error())
+			return errors.NewChuxModelsError("Review.Save() Error deleting Review in MongoDB", err)
+		}
+	}
+
+	// If the Review has been deleted, then this is a new Review
+	r.isNew = r.isDeleted
+	r.isDirty = r.IsDirty()
+	r.isDeleted = false
+
+	if r.isNew {
+		r.originalState = nil
+	} else {
+		//--reset state
+		serialized, err := r.Serialize()
+		if err != nil {
+			logging.Error("Review.Save() Error serializing Review: %s", err.Error())
+			return errors.NewChuxModelsError("Review.Save() Error serializing Review.", err)
+		}
+		r.SetState(serialized)
+	}
+
+	logging.Info("Review.Save() Review saved successfully")
+	return nil
+}
+
+// Loads a Model from MongoDB by id
+func (r *Review) Load(id string) (interface{}, error) {
+	logging := r.Logger
+	logging.Debug("Review.Load() was called")
+
+	retVal, err := mongoDB.GetByID(r, id)
+	if err != nil {
+		logging.Error("Review.Load() Error loading Review from MongoDB: %s", err.Error())
+		return nil, errors.NewChuxModelsError("Review.Load() Error loading Review from MongoDB", err)
+	}
+	review, ok := retVal.(*Review)
+	if !ok {
+		logging.Error("Review.Load() unable to cast retVal to *Review")
+		return nil, errors.NewChuxModelsError("Review.Load() unable to cast retVal to *Review", nil)
+	}
+	err = review.markLoaded()
+	if err != nil {
+		logging.Error("Review.Load() Error setting state: %s", err.Error())
+		return nil, errors.NewChuxModelsError("Review.Load() Error setting state", err)
+	}
+	logging.Info("Review.Load() Review loaded successfully")
+	return retVal, nil
+}
+
+func (r *Review) Query(args ...interface{}) ([]db.IMongoDocument, error) {
+	logging := r.Logger
+	logging.Debug("Review.Query() was called")
+
+	results, err := mongoDB.Query(r, args...)
+	if err != nil {
+		logging.Error("Review.Query() Error occurred querying Reviews: %s", err.Error())
+		return nil, errors.NewChuxModelsError("Review.Query() Error occurred querying Reviews", err)
+	}
+	logging.Info("Review.Query() Reviews queried successfully")
+	return results, nil
+}
+
+// Marks a Model for deletion from the Data Store
+// when Save() is called, the Model will be deleted
+func (r *Review) Delete() error {
+	logging := r.Logger
+	logging.Debug("Review.Delete() was called")
+	r.isDeleted = true
+	return nil
+}
+
+// Sets the internal state of the model.
+func (r *Review) SetState(json string) error {
+	logging := r.Logger
+	logging.Debug("Review.SetState() was called")
+	// Store the current state as the original state
+	original := &Review{}
+	*original = *r
+	r.originalState = original
+
+	// Deserialize the new state
+	return r.Deserialize([]byte(json))
+}
+
+// Marks a Review returned by Query() as loaded so that
+// changes made to it are persisted by Save()
+func (r *Review) markLoaded() error {
+	serialized, err := r.Serialize()
+	if err != nil {
+		return errors.NewChuxModelsError("Review.markLoaded() Error serializing Review", err)
+	}
+	r.SetState(serialized)
+	r.isNew = false
+	r.isDirty = false
+	r.isDeleted = false
+	return nil
+}
+
+// Sets the internal state of the model of a new Review
+// from a JSON String.
+func (r *Review) Parse(json string) error {
+	logging := r.Logger
+	logging.Debug("Review.Parse() was called")
+	err := r.SetState(json)
+	if err != nil {
+		logging.Error("Review.Parse() error setting state")
+		return errors.NewChuxModelsError("Review.Parse() Error setting state", err)
+	}
+	r.isNew = true // this is a new model
+	return nil
+}
+
+func (r *Review) Search(args ...interface{}) ([]interface{}, error) {
+	logging := r.Logger
+	logging.Debug("Review.Search() was called")
+	return nil, nil
+}
+
+func (r *Review) Serialize() (string, error) {
+	logging := r.Logger
+	logging.Debug("Review.Serialize() was called")
+	applyTimeConfig(r.timeConfig, r)
+	bytes, err := json.Marshal(r)
+	if err != nil {
+		logging.Error("Review.Serialize() error occurred: %s", err.Error())
+		return "", errors.NewChuxModelsError("Review.Serialize() error occurred", err)
+	}
+	return string(bytes), nil
+}
+
+func (r *Review) Deserialize(jsonData []byte) error {
+	logging := r.Logger
+	logging.Debug("Review.Deserialize() was called")
+	applyTimeConfig(r.timeConfig, r)
+	err := json.Unmarshal(jsonData, r)
+	if err != nil {
+		logging.Error("Review.Deserialize() error occurred: %s", err.Error())
+		return errors.NewChuxModelsError("Review.Deserialize() error occurred", err)
+	}
+	return nil
+}
+
+// Key identifies a Review by its Product, author and text, so the
+// same review scraped twice is stored once.
+func (r *Review) Key() string {
+	parts := []string{r.ProductID.Hex(), NormalizeText(r.Author), NormalizeText(r.Title), NormalizeText(r.Body)}
+	sum := sha1.Sum([]byte(strings.Join(parts, "|")))
+	return hex.EncodeToString(sum[:])
+}
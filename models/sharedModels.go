@@ -4,6 +4,9 @@ type Offer struct {
 	Price        string `bson:"price"`
 	Currency     string `bson:"currency"`
 	Availability string `bson:"availability"`
+	// The normalized Availability, the raw value above is kept as scraped
+	AvailabilityStatus Availability `bson:"availabilityStatus,omitempty" json:"availabilityStatus,omitempty"`
+	UnitPrice          *UnitPrice   `bson:"unitPrice,omitempty" json:"unitPrice,omitempty"`
 }
 
 type Breadcrumb struct {
@@ -20,4 +23,6 @@ type AggregateRating struct {
 	RatingValue float64 `bson:"ratingValue"`
 	BestRating  float64 `bson:"bestRating"`
 	ReviewCount int     `bson:"reviewCount"`
+	// RatingValue on the 0 to 5 scale
+	NormalizedValue float64 `bson:"normalizedValue,omitempty" json:"normalizedValue,omitempty"`
 }
@@ -0,0 +1,209 @@
+package models
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/chuxorg/chux-models/logging"
+)
+
+// Words that are too common to tell sentences apart
+var summaryStopwords = map[string]bool{
+	"a": true, "about": true, "after": true, "all": true, "also": true, "an": true, "and": true,
+	"any": true, "are": true, "as": true, "at": true, "be": true, "been": true, "but": true,
+	"by": true, "can": true, "could": true, "did": true, "do": true, "does": true, "for": true,
+	"from": true, "had": true, "has": true, "have": true, "he": true, "her": true, "his": true,
+	"how": true, "i": true, "if": true, "in": true, "into": true, "is": true, "it": true,
+	"its": true, "just": true, "more": true, "most": true, "no": true, "not": true, "of": true,
+	"on": true, "one": true, "or": true, "other": true, "our": true, "out": true, "said": true,
+	"she": true, "so": true, "some": true, "than": true, "that": true, "the": true, "their": true,
+	"them": true, "then": true, "there": true, "these": true, "they": true, "this": true,
+	"to": true, "up": true, "was": true, "we": true, "were": true, "what": true, "when": true,
+	"which": true, "who": true, "will": true, "with": true, "would": true, "you": true, "your": true,
+}
+
+// Summarizer picks the most central sentences of a text with TextRank.
+// Sentences are ranked like pages in PageRank, linked by the words
+// they share, and the top ones are returned in the order they appear.
+type Summarizer struct {
+	// Number of sentences in a summary
+	Sentences int
+	// Sentences with fewer words are not picked, they are still ranked
+	MinWords int
+	// The PageRank damping factor
+	Damping float64
+	Logger  *logging.Logger
+}
+
+// Creates a NewSummarizer with Options.
+// By default summaries are 3 sentences of at least 5 words
+// and the damping factor is 0.85.
+func NewSummarizer(options ...func(*Summarizer)) *Summarizer {
+	s := &Summarizer{
+		Sentences: 3,
+		MinWords:  5,
+		Damping:   0.85,
+	}
+	for _, option := range options {
+		option(s)
+	}
+	if s.Sentences < 1 {
+		s.Sentences = 1
+	}
+	return s
+}
+
+func NewSummarizerWithLogger(logger logging.Logger) func(*Summarizer) {
+	return func(s *Summarizer) {
+		s.Logger = &logger
+	}
+}
+
+// Sets the number of sentences in a summary
+func NewSummarizerWithSentences(sentences int) func(*Summarizer) {
+	return func(s *Summarizer) {
+		s.Sentences = sentences
+	}
+}
+
+// Sets the number of words a sentence needs to be picked
+func NewSummarizerWithMinWords(words int) func(*Summarizer) {
+	return func(s *Summarizer) {
+		s.MinWords = words
+	}
+}
+
+// Sets the PageRank damping factor, between 0 and 1
+func NewSummarizerWithDamping(damping float64) func(*Summarizer) {
+	return func(s *Summarizer) {
+		s.Damping = damping
+	}
+}
+
+// Summarize returns the top sentences of a plain text by TextRank in
+// the order they appear. Texts with no more sentences than a summary
+// are returned whole.
+func (s *Summarizer) Summarize(text string) []string {
+	logging := s.Logger
+	logging.Debug("Summarizer.Summarize() was called")
+
+	sentences := SplitSentences(text)
+	if len(sentences) <= s.Sentences {
+		return sentences
+	}
+	tokens := make([][]string, len(sentences))
+	var candidates []int
+	for i, sentence := range sentences {
+		tokens[i] = summaryTokens(sentence)
+		if len(sentenceWords(sentence)) >= s.MinWords {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) < s.Sentences {
+		candidates = candidates[:0]
+		for i := range sentences {
+			candidates = append(candidates, i)
+		}
+	}
+
+	scores := s.rank(tokens)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return scores[candidates[i]] > scores[candidates[j]]
+	})
+	picked := candidates[:minInt(s.Sentences, len(candidates))]
+	sort.Ints(picked)
+
+	summary := make([]string, len(picked))
+	for i, index := range picked {
+		summary[i] = sentences[index]
+	}
+	logging.Debug("Summarizer.Summarize() picked %d of %d sentences", len(summary), len(sentences))
+	return summary
+}
+
+// Runs PageRank over the sentence similarity graph until the
+// scores change by less than 0.0001 or 100 iterations have run
+func (s *Summarizer) rank(tokens [][]string) []float64 {
+	n := len(tokens)
+	weights := make([][]float64, n)
+	for i := range weights {
+		weights[i] = make([]float64, n)
+	}
+	totals := make([]float64, n)
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			weight := sentenceSimilarity(tokens[i], tokens[j])
+			weights[i][j] = weight
+			weights[j][i] = weight
+			totals[i] += weight
+			totals[j] += weight
+		}
+	}
+
+	scores := make([]float64, n)
+	for i := range scores {
+		scores[i] = 1
+	}
+	next := make([]float64, n)
+	for iteration := 0; iteration < 100; iteration++ {
+		delta := 0.0
+		for i := 0; i < n; i++ {
+			sum := 0.0
+			for j := 0; j < n; j++ {
+				if weights[j][i] > 0 {
+					sum += weights[j][i] / totals[j] * scores[j]
+				}
+			}
+			next[i] = (1 - s.Damping) + s.Damping*sum
+			delta = math.Max(delta, math.Abs(next[i]-scores[i]))
+		}
+		scores, next = next, scores
+		if delta < 0.0001 {
+			break
+		}
+	}
+	return scores
+}
+
+// The TextRank similarity of two sentences, the words they share
+// over the log of their lengths so long sentences are not favoured
+func sentenceSimilarity(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	inB := make(map[string]bool, len(b))
+	for _, token := range b {
+		inB[token] = true
+	}
+	shared := 0
+	seen := make(map[string]bool, len(a))
+	for _, token := range a {
+		if inB[token] && !seen[token] {
+			shared++
+		}
+		seen[token] = true
+	}
+	if shared == 0 {
+		return 0
+	}
+	return float64(shared) / (math.Log(float64(len(a)+1)) + math.Log(float64(len(b)+1)))
+}
+
+// Returns the stemmed words of a sentence without stopwords
+func summaryTokens(sentence string) []string {
+	var tokens []string
+	for _, token := range Tokenize(sentence) {
+		if !summaryStopwords[token] {
+			tokens = append(tokens, token)
+		}
+	}
+	return stemTokens(tokens)
+}
+
+// Summarize returns an extractive summary of the Article's ArticleBody
+func (a *Article) Summarize(summarizer *Summarizer) string {
+	logging := a.Logger
+	logging.Debug("Article.Summarize() was called")
+	return strings.Join(summarizer.Summarize(a.ArticleBody), " ")
+}
@@ -0,0 +1,754 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/chuxorg/chux-datastore/db"
+	dbl "github.com/chuxorg/chux-datastore/logging"
+	"github.com/chuxorg/chux-models/errors"
+	"github.com/chuxorg/chux-models/logging"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Tag is a topic Articles and Products are browsed by, identified by
+// its slug. Articles and Products link to their Tags with the slugs in
+// their Tags, the Tag counts how many of each link to it.
+type Tag struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	Slug          string             `bson:"slug" json:"slug"`
+	Name          string             `bson:"name" json:"name"`
+	ArticleCount  int                `bson:"articleCount" json:"articleCount"`
+	ProductCount  int                `bson:"productCount" json:"productCount"`
+	Count         int                `bson:"count" json:"count"`
+	DateCreated   CustomTime         `bson:"dateCreated,omitempty" json:"dateCreated,omitempty"`
+	DateModified  CustomTime         `bson:"dateModified,omitempty" json:"dateModified,omitempty"`
+	isNew         bool               `bson:"-" json:"-"`
+	isDeleted     bool               `bson:"-" json:"-"`
+	isDirty       bool               `bson:"-" json:"-"`
+	originalState *Tag               `bson:"-" json:"-"`
+	timeConfig    *CustomTimeConfig  `bson:"-" json:"-"`
+	Logger        *logging.Logger    `bson:"-" json:"-"`
+}
+
+func NewTag(options ...func(*Tag)) *Tag {
+
+	t := &Tag{}
+
+	for _, option := range options {
+		option(t)
+	}
+	dbLogger := dbl.NewLogger(dbl.LogLevelDebug)
+	mongoDB = db.New(
+		db.WithURI(t.GetURI()),
+		db.WithDatabaseName(t.GetDatabaseName()),
+		db.WithCollectionName(t.GetCollectionName()),
+		db.WithTimeout(30),
+		db.WithLogger(*dbLogger),
+	)
+
+	t.isNew = true
+	t.isDeleted = false
+	t.isDirty = false
+	return t
+}
+
+func NewTagWithLogger(logger logging.Logger) func(*Tag) {
+	return func(t *Tag) {
+		t.Logger = &logger
+	}
+}
+
+// Sets how the Tag's CustomTimes are written to JSON and stored in Mongo,
+// without it they use the DefaultCustomTimeConfig
+func NewTagWithTimeConfig(config CustomTimeConfig) func(*Tag) {
+	return func(t *Tag) {
+		t.timeConfig = &config
+	}
+}
+
+func (t *Tag) GetCollectionName() string {
+	logging := t.Logger
+	logging.Debug("Tag.GetCollectionName() was called")
+	return "tags"
+}
+
+func (t *Tag) GetDatabaseName() string {
+	logging := t.Logger
+	logging.Debug("Tag.GetDatabaseName() was called")
+	return os.Getenv("MONGO_DATABASE")
+}
+
+func (t *Tag) GetURI() string {
+	logging := t.Logger
+	logging.Debug("Tag.GetURI() was called")
+	username := os.Getenv("MONGO_USER_NAME")
+	password := os.Getenv("MONGO_PASSWORD")
+
+	uri := os.Getenv("MONGO_URI")
+	mongoURI := fmt.Sprintf(uri, username, password)
+	masked := fmt.Sprintf(uri, "********", "********")
+	logging.Info("Mongo URI: %s", masked)
+	return mongoURI
+}
+
+func (t *Tag) GetID() primitive.ObjectID {
+	logging := t.Logger
+	logging.Debug("Tag.GetID() was called")
+	return t.ID
+}
+
+func (t *Tag) SetID(id primitive.ObjectID) {
+	logging := t.Logger
+	logging.Debug("Tag.SetID() was called")
+	t.ID = id
+}
+
+// If the Model has changes, will return true
+func (t *Tag) IsDirty() bool {
+	logging := t.Logger
+	logging.Debug("Tag.IsDirty() was called")
+	if t.originalState == nil {
+		return false
+	}
+
+	originalBytes, err := t.originalState.Serialize()
+	if err != nil {
+		return false
+	}
+
+	currentBytes, err := t.Serialize()
+	if err != nil {
+		return false
+	}
+
+	t.isDirty = string(originalBytes) != string(currentBytes)
+	logging.Info("Tag.IsDirty() isDirty: %t", t.isDirty)
+	return t.isDirty
+}
+
+// When the Model is first created,
+// the model is considered New. After the model is
+// Saved or Loaded it is no longer New
+func (t *Tag) IsNew() bool {
+	logging := t.Logger
+	logging.Debug("Tag.IsNew() was called")
+	return t.isNew
+}
+
+// Saves the Model to a Data Store
+func (t *Tag) Save() error {
+	logging := t.Logger
+	logging.Debug("Tag.Save() was called")
+	applyTimeConfig(t.timeConfig, t)
+	if t.isNew {
+		logging.Debug("Tag.Save() Tag is new")
+		// -- Set the date created to now
+		t.DateCreated.Now()
+		//-- Upsert document, a Tag is unique by its slug
+		if t.Slug == "" {
+			t.Slug = Slugify(t.Name)
+		}
+		err := mongoDB.Upsert(t, "slug")
+		if err != nil {
+			logging.Error("Tag.Save() Error creating Tag in MongoDB: %s", err.Error())
+			return errors.NewChuxModelsError("Tag.Save() Error creating Tag in MongoDB", err)
+		}
+	} else if t.IsDirty() && !t.isDeleted {
+		logging.Debug("Tag.Save() Tag is dirty")
+		// Ensure the ID is a valid hex string representation of an ObjectID
+		_, err := primitive.ObjectIDFromHex(t.ID.Hex())
+		if err != nil {
+			logging.Error("Tag.Save() invalid ObjectID: %s", err.Error())
+			return errors.NewChuxModelsError("Tag.Save() invalid ObjectID", err)
+		}
+		// -- Set the date modified to now
+		t.DateModified.Now()
+		//--update this document
+		err = mongoDB.Update(t, t.ID.Hex())
+		if err != nil {
+			logging.Error("Tag.Save() Error updating Tag in MongoDB: %s", err.Error())
+			return errors.NewChuxModelsError("Tag.Save() Error updating Tag in MongoDB", err)
+		}
+	} else if t.isDeleted && !t.isNew {
+		logging.Info("Tag.Save() Tag is deleted")
+		//--delete the document
+		err := mongoDB.Delete(t, t.ID.Hex())
+		if err != nil {
+			logging.Error("Tag.Save() Error deleting Tag in MongoDB: %s", err.Error())
+			return errors.NewChuxModelsError("Tag.Save() Error deleting Tag in MongoDB", err)
+		}
+	}
+
+	// If the Tag has been deleted, then this is a new Tag
+	t.isNew = t.isDeleted
+	t.isDirty = t.IsDirty()
+	t.isDeleted = false
+
+	if t.isNew {
+		t.originalState = nil
+	} else {
+		//--reset state
+		serialized, err := t.Serialize()
+		if err != nil {
+			logging.Error("Tag.Save() Error serializing Tag: %s", err.Error())
+			return errors.NewChuxModelsError("Tag.Save() Error serializing Tag.", err)
+		}
+		t.SetState(serialized)
+	}
+
+	logging.Info("Tag.Save() Tag saved successfully")
+	return nil
+}
+
+// Loads a Model from MongoDB by id
+func (t *Tag) Load(id string) (interface{}, error) {
+	logging := t.Logger
+	logging.Debug("Tag.Load() was called")
+
+	retVal, err := mongoDB.GetByID(t, id)
+	if err != nil {
+		logging.Error("Tag.Load() Error loading Tag from MongoDB: %s", err.Error())
+		return nil, errors.NewChuxModelsError("Tag.Load() Error loading Tag from MongoDB", err)
+	}
+	cluster, ok := retVal.(*Tag)
+	if !ok {
+		logging.Error("Tag.Load() unable to cast retVal to *Tag")
+		return nil, errors.NewChuxModelsError("Tag.Load() unable to cast retVal to *Tag", nil)
+	}
+	err = cluster.markLoaded()
+	if err != nil {
+		logging.Error("Tag.Load() Error setting state: %s", err.Error())
+		return nil, errors.NewChuxModelsError("Tag.Load() Error setting state", err)
+	}
+	logging.Info("Tag.Load() Tag loaded successfully")
+	return retVal, nil
+}
+
+func (t *Tag) Query(args ...interface{}) ([]db.IMongoDocument, error) {
+	logging := t.Logger
+	logging.Debug("Tag.Query() was called")
+
+	results, err := mongoDB.Query(t, args...)
+	if err != nil {
+		logging.Error("Tag.Query() Error occurred querying Tags: %s", err.Error())
+		return nil, errors.NewChuxModelsError("Tag.Query() Error occurred querying Tags", err)
+	}
+	logging.Info("Tag.Query() Tags queried successfully")
+	return results, nil
+}
+
+// Marks a Model for deletion from the Data Store
+// when Save() is called, the Model will be deleted
+func (t *Tag) Delete() error {
+	logging := t.Logger
+	logging.Debug("Tag.Delete() was called")
+	t.isDeleted = true
+	return nil
+}
+
+// Sets the internal state of the model.
+func (t *Tag) SetState(json string) error {
+	logging := t.Logger
+	logging.Debug("Tag.SetState() was called")
+	// Store the current state as the original state
+	original := &Tag{}
+	*original = *t
+	t.originalState = original
+
+	// Deserialize the new state
+	return t.Deserialize([]byte(json))
+}
+
+// Marks a Tag returned by Query() as loaded so that
+// changes made to it are persisted by Save()
+func (t *Tag) markLoaded() error {
+	serialized, err := t.Serialize()
+	if err != nil {
+		return errors.NewChuxModelsError("Tag.markLoaded() Error serializing Tag", err)
+	}
+	t.SetState(serialized)
+	t.isNew = false
+	t.isDirty = false
+	t.isDeleted = false
+	return nil
+}
+
+// Sets the internal state of the model of a new Tag
+// from a JSON String.
+func (t *Tag) Parse(json string) error {
+	logging := t.Logger
+	logging.Debug("Tag.Parse() was called")
+	err := t.SetState(json)
+	if err != nil {
+		logging.Error("Tag.Parse() error setting state")
+		return errors.NewChuxModelsError("Tag.Parse() Error setting state", err)
+	}
+	t.isNew = true // this is a new model
+	return nil
+}
+
+func (t *Tag) Search(args ...interface{}) ([]interface{}, error) {
+	logging := t.Logger
+	logging.Debug("Tag.Search() was called")
+	return nil, nil
+}
+
+func (t *Tag) Serialize() (string, error) {
+	logging := t.Logger
+	logging.Debug("Tag.Serialize() was called")
+	applyTimeConfig(t.timeConfig, t)
+	bytes, err := json.Marshal(t)
+	if err != nil {
+		logging.Error("Tag.Serialize() error occurred: %s", err.Error())
+		return "", errors.NewChuxModelsError("Tag.Serialize() error occurred", err)
+	}
+	return string(bytes), nil
+}
+
+func (t *Tag) Deserialize(jsonData []byte) error {
+	logging := t.Logger
+	logging.Debug("Tag.Deserialize() was called")
+	applyTimeConfig(t.timeConfig, t)
+	err := json.Unmarshal(jsonData, t)
+	if err != nil {
+		logging.Error("Tag.Deserialize() error occurred: %s", err.Error())
+		return errors.NewChuxModelsError("Tag.Deserialize() error occurred", err)
+	}
+	return nil
+}
+
+// Kinds of items a Tag counts
+const (
+	tagKindArticle = "article"
+	tagKindProduct = "product"
+)
+
+// Number of sizes a TagCloud sorts Tags into
+const TagCloudLevels = 5
+
+// TagCloudEntry is a Tag in a tag cloud, sized by how many
+// Articles and Products link to it from 1 to TagCloudLevels
+type TagCloudEntry struct {
+	Slug   string `json:"slug"`
+	Name   string `json:"name"`
+	Count  int    `json:"count"`
+	Weight int    `json:"weight"`
+}
+
+// The field of a Tag counting a kind of item, and the one counting the other kind
+func tagCountFields(kind string) (string, string) {
+	if kind == tagKindProduct {
+		return "productCount", "articleCount"
+	}
+	return "articleCount", "productCount"
+}
+
+// ExtractTags sets the Article's Keywords to those of its Headline and
+// ArticleBody and its Tags to their slugs
+func (a *Article) ExtractTags(extractor *KeywordExtractor) {
+	logging := a.Logger
+	logging.Debug("Article.ExtractTags() was called")
+	a.Keywords = a.ExtractKeywords(extractor)
+	a.Tags = keywordSlugs(a.Keywords)
+}
+
+// ExtractTags sets the Product's Keywords to those of its Name and
+// Description and its Tags to their slugs
+func (p *Product) ExtractTags(extractor *KeywordExtractor) {
+	logging := p.Logger
+	logging.Debug("Product.ExtractTags() was called")
+	p.Keywords = p.ExtractKeywords(extractor)
+	p.Tags = keywordSlugs(p.Keywords)
+}
+
+func keywordSlugs(keywords []Keyword) []string {
+	slugs := make([]string, 0, len(keywords))
+	for _, keyword := range keywords {
+		slugs = append(slugs, keyword.Slug)
+	}
+	return slugs
+}
+
+func keywordNames(keywords []Keyword) map[string]string {
+	names := make(map[string]string, len(keywords))
+	for _, keyword := range keywords {
+		names[keyword.Slug] = keyword.Term
+	}
+	return names
+}
+
+// The Tags of the stored Article with the same CanonicalURL, which
+// the Upsert of a new Article replaces
+func (a *Article) storedTags() ([]string, error) {
+	query := &Article{Logger: a.Logger}
+	docs, err := query.Query("canonicalUrl", a.CanonicalURL)
+	if err != nil || len(docs) == 0 {
+		return nil, err
+	}
+	return docs[0].(*Article).Tags, nil
+}
+
+// Moves the Article's counts from the Tags it linked to before
+// to the ones it links to now
+func (a *Article) countTags(previous, current []string) error {
+	return updateTagCounts(a.Logger, tagKindArticle, previous, current, keywordNames(a.Keywords))
+}
+
+// The Tags of the stored Product with the same CanonicalURL, which
+// the Upsert of a new Product replaces
+func (p *Product) storedTags() ([]string, error) {
+	query := &Product{Logger: p.Logger}
+	docs, err := query.Query("canonicalUrl", p.CanonicalURL)
+	if err != nil || len(docs) == 0 {
+		return nil, err
+	}
+	return docs[0].(*Product).Tags, nil
+}
+
+// Moves the Product's counts from the Tags it linked to before
+// to the ones it links to now
+func (p *Product) countTags(previous, current []string) error {
+	return updateTagCounts(p.Logger, tagKindProduct, previous, current, keywordNames(p.Keywords))
+}
+
+// Articles returns the stored Articles that link to the Tag
+func (t *Tag) Articles() ([]*Article, error) {
+	logging := t.Logger
+	logging.Debug("Tag.Articles() was called")
+	return articlesTagged(t.Logger, []string{t.Slug})
+}
+
+// Products returns the stored Products that link to the Tag
+func (t *Tag) Products() ([]*Product, error) {
+	logging := t.Logger
+	logging.Debug("Tag.Products() was called")
+	return productsTagged(t.Logger, []string{t.Slug})
+}
+
+// Returns the stored Tag with a slug, or nil when there is none
+func findTag(logging *logging.Logger, slug string) (*Tag, error) {
+	query := &Tag{Logger: logging}
+	docs, err := query.Query("slug", slug)
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, nil
+	}
+	tag := docs[0].(*Tag)
+	tag.Logger = logging
+	if err := tag.markLoaded(); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// FindTag returns the stored Tag of a term, or nil when there is none
+func FindTag(logging logging.Logger, term string) (*Tag, error) {
+	NewTag(NewTagWithLogger(logging))
+	return findTag(&logging, Slugify(term))
+}
+
+// Slugs of terms, leaving out the ones without words
+func tagSlugs(terms []string) []string {
+	slugs := make([]string, 0, len(terms))
+	for _, term := range terms {
+		if slug := Slugify(term); slug != "" && !containsString(slugs, slug) {
+			slugs = append(slugs, slug)
+		}
+	}
+	return slugs
+}
+
+func articlesTagged(logging *logging.Logger, slugs []string) ([]*Article, error) {
+	query := &Article{Logger: logging}
+	docs, err := query.Query("tags", bson.M{"$all": slugs})
+	if err != nil {
+		return nil, err
+	}
+	articles := make([]*Article, 0, len(docs))
+	for _, doc := range docs {
+		found := doc.(*Article)
+		found.Logger = logging
+		if err := found.markLoaded(); err != nil {
+			return nil, err
+		}
+		articles = append(articles, found)
+	}
+	return articles, nil
+}
+
+func productsTagged(logging *logging.Logger, slugs []string) ([]*Product, error) {
+	query := &Product{Logger: logging}
+	docs, err := query.Query("tags", bson.M{"$all": slugs})
+	if err != nil {
+		return nil, err
+	}
+	products := make([]*Product, 0, len(docs))
+	for _, doc := range docs {
+		found := doc.(*Product)
+		found.Logger = logging
+		if err := found.markLoaded(); err != nil {
+			return nil, err
+		}
+		products = append(products, found)
+	}
+	return products, nil
+}
+
+// ArticlesTagged returns the stored Articles that link to
+// the Tags of all of the terms
+func ArticlesTagged(logging logging.Logger, terms ...string) ([]*Article, error) {
+	NewArticle(NewArticleWithLogger(logging))
+	slugs := tagSlugs(terms)
+	if len(slugs) == 0 {
+		return nil, nil
+	}
+	articles, err := articlesTagged(&logging, slugs)
+	if err != nil {
+		return nil, err
+	}
+	logging.Info("ArticlesTagged() Found %d Articles tagged %s", len(articles), strings.Join(slugs, ", "))
+	return articles, nil
+}
+
+// ProductsTagged returns the stored Products that link to
+// the Tags of all of the terms
+func ProductsTagged(logging logging.Logger, terms ...string) ([]*Product, error) {
+	NewProduct(NewProductWithLogger(logging))
+	slugs := tagSlugs(terms)
+	if len(slugs) == 0 {
+		return nil, nil
+	}
+	products, err := productsTagged(&logging, slugs)
+	if err != nil {
+		return nil, err
+	}
+	logging.Info("ProductsTagged() Found %d Products tagged %s", len(products), strings.Join(slugs, ", "))
+	return products, nil
+}
+
+// Updates the counts of the Tags an item of a kind linked to before
+// and links to now, creating the Tags that are not stored yet. The
+// counts are incremented in the database so that items saved at the
+// same time do not overwrite each other's counts. names has the terms
+// of new Tags by slug, Tags without one are named after their slug.
+func updateTagCounts(logging *logging.Logger, kind string, previous, current []string, names map[string]string) error {
+	deltas := make(map[string]int)
+	for _, slug := range previous {
+		deltas[slug]--
+	}
+	for _, slug := range current {
+		deltas[slug]++
+	}
+	slugs := make([]string, 0, len(deltas))
+	for slug, delta := range deltas {
+		if delta != 0 {
+			slugs = append(slugs, slug)
+		}
+	}
+	if len(slugs) == 0 {
+		return nil
+	}
+	sort.Strings(slugs)
+
+	collection, ctx, cancel, err := collectionOf(&Tag{Logger: logging})
+	if err != nil {
+		logging.Error("updateTagCounts() Error connecting to MongoDB: %s", err.Error())
+		return errors.NewChuxModelsError("updateTagCounts() Error connecting to MongoDB", err)
+	}
+	defer cancel()
+	// -- Tags upserted at the same time would otherwise be stored twice
+	if err := ensureUniqueIndex(ctx, collection, "slug"); err != nil {
+		logging.Error("updateTagCounts() Error creating the slug index of Tags: %s", err.Error())
+		return errors.NewChuxModelsError("updateTagCounts() Error creating the slug index of Tags", err)
+	}
+	countField, otherField := tagCountFields(kind)
+	var now CustomTime
+	now.Now()
+	for _, slug := range slugs {
+		delta := deltas[slug]
+		update := bson.M{
+			"$inc": bson.M{countField: delta, "count": delta},
+			"$set": bson.M{"dateModified": now},
+		}
+		// -- Only a link creates a Tag, unlinking from a Tag that is not stored changes nothing
+		upsert := delta > 0
+		if upsert {
+			name := names[slug]
+			if name == "" {
+				name = strings.ReplaceAll(slug, "-", " ")
+			}
+			update["$setOnInsert"] = bson.M{"name": name, otherField: 0, "dateCreated": now}
+		}
+		_, err := collection.UpdateOne(ctx, bson.M{"slug": slug}, update, options.Update().SetUpsert(upsert))
+		if err != nil {
+			logging.Error("updateTagCounts() Error updating the counts of Tag %s in MongoDB: %s", slug, err.Error())
+			return errors.NewChuxModelsError("updateTagCounts() Error updating Tag counts in MongoDB", err)
+		}
+	}
+	return nil
+}
+
+// Counts the stored items of a Model linking to each Tag, by slug
+func countTagLinks(doc db.IMongoDocument) (map[string]int, error) {
+	collection, ctx, cancel, err := collectionOf(doc)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+	cursor, err := collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$unwind", Value: "$tags"}},
+		{{Key: "$group", Value: bson.M{"_id": "$tags", "count": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var results []struct {
+		Slug  string `bson:"_id"`
+		Count int    `bson:"count"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int, len(results))
+	for _, result := range results {
+		counts[result.Slug] = result.Count
+	}
+	return counts, nil
+}
+
+// RecountTags sets the counts of the stored Tags from the Articles
+// and Products that link to them, for when they have drifted. The
+// links are counted by the database.
+func RecountTags(logging logging.Logger) error {
+	logging.Debug("RecountTags() was called")
+	NewTag(NewTagWithLogger(logging))
+	articleCounts, err := countTagLinks(&Article{Logger: &logging})
+	if err != nil {
+		logging.Error("RecountTags() Error counting the Tags of Articles: %s", err.Error())
+		return errors.NewChuxModelsError("RecountTags() Error counting the Tags of Articles", err)
+	}
+	productCounts, err := countTagLinks(&Product{Logger: &logging})
+	if err != nil {
+		logging.Error("RecountTags() Error counting the Tags of Products: %s", err.Error())
+		return errors.NewChuxModelsError("RecountTags() Error counting the Tags of Products", err)
+	}
+	slugs := make([]string, 0, len(articleCounts)+len(productCounts))
+	for slug := range articleCounts {
+		slugs = append(slugs, slug)
+	}
+	for slug := range productCounts {
+		if _, ok := articleCounts[slug]; !ok {
+			slugs = append(slugs, slug)
+		}
+	}
+	sort.Strings(slugs)
+
+	var now CustomTime
+	now.Now()
+	writes := make([]mongo.WriteModel, 0, len(slugs)+1)
+	for _, slug := range slugs {
+		articles, products := articleCounts[slug], productCounts[slug]
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"slug": slug}).
+			SetUpdate(bson.M{
+				"$set":         bson.M{"articleCount": articles, "productCount": products, "count": articles + products, "dateModified": now},
+				"$setOnInsert": bson.M{"name": strings.ReplaceAll(slug, "-", " "), "dateCreated": now},
+			}).
+			SetUpsert(true))
+	}
+	// -- Tags nothing links to anymore
+	writes = append(writes, mongo.NewUpdateManyModel().
+		SetFilter(bson.M{"slug": bson.M{"$nin": slugs}, "count": bson.M{"$ne": 0}}).
+		SetUpdate(bson.M{"$set": bson.M{"articleCount": 0, "productCount": 0, "count": 0, "dateModified": now}}))
+
+	collection, ctx, cancel, err := collectionOf(&Tag{Logger: &logging})
+	if err != nil {
+		logging.Error("RecountTags() Error connecting to MongoDB: %s", err.Error())
+		return errors.NewChuxModelsError("RecountTags() Error connecting to MongoDB", err)
+	}
+	defer cancel()
+	if _, err := collection.BulkWrite(ctx, writes); err != nil {
+		logging.Error("RecountTags() Error updating Tag counts in MongoDB: %s", err.Error())
+		return errors.NewChuxModelsError("RecountTags() Error updating Tag counts in MongoDB", err)
+	}
+	logging.Info("RecountTags() Recounted %d Tags", len(slugs))
+	return nil
+}
+
+// BuildTagCloud sizes the limit Tags with the highest Count, or all
+// of them when limit is 0, on a logarithmic scale. The entries are
+// sorted by Name.
+func BuildTagCloud(tags []*Tag, limit int) []TagCloudEntry {
+	ranked := make([]*Tag, 0, len(tags))
+	for _, tag := range tags {
+		if tag.Count > 0 {
+			ranked = append(ranked, tag)
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Count == ranked[j].Count {
+			return ranked[i].Slug < ranked[j].Slug
+		}
+		return ranked[i].Count > ranked[j].Count
+	})
+	if limit > 0 && len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	if len(ranked) == 0 {
+		return nil
+	}
+
+	highest := math.Log(float64(ranked[0].Count))
+	lowest := math.Log(float64(ranked[len(ranked)-1].Count))
+	entries := make([]TagCloudEntry, len(ranked))
+	for i, tag := range ranked {
+		weight := TagCloudLevels
+		if highest > lowest {
+			scaled := (math.Log(float64(tag.Count)) - lowest) / (highest - lowest)
+			weight = 1 + int(math.Round(scaled*float64(TagCloudLevels-1)))
+		}
+		entries[i] = TagCloudEntry{Slug: tag.Slug, Name: tag.Name, Count: tag.Count, Weight: weight}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+	return entries
+}
+
+// TagCloud returns the tag cloud of the limit stored Tags
+// that the most Articles and Products link to
+func TagCloud(logging logging.Logger, limit int) ([]TagCloudEntry, error) {
+	query := NewTag(NewTagWithLogger(logging))
+	collection, ctx, cancel, err := collectionOf(query)
+	if err != nil {
+		logging.Error("TagCloud() Error connecting to MongoDB: %s", err.Error())
+		return nil, errors.NewChuxModelsError("TagCloud() Error connecting to MongoDB", err)
+	}
+	defer cancel()
+	// -- Only the limit Tags with the highest counts are read
+	find := options.Find().SetSort(bson.D{{Key: "count", Value: -1}, {Key: "slug", Value: 1}})
+	if limit > 0 {
+		find.SetLimit(int64(limit))
+	}
+	cursor, err := collection.Find(ctx, bson.M{"count": bson.M{"$gt": 0}}, find)
+	if err != nil {
+		logging.Error("TagCloud() Error querying Tags: %s", err.Error())
+		return nil, errors.NewChuxModelsError("TagCloud() Error querying Tags", err)
+	}
+	var tags []*Tag
+	if err := cursor.All(ctx, &tags); err != nil {
+		logging.Error("TagCloud() Error decoding Tags: %s", err.Error())
+		return nil, errors.NewChuxModelsError("TagCloud() Error decoding Tags", err)
+	}
+	return BuildTagCloud(tags, limit), nil
+}
@@ -0,0 +1,243 @@
+package models
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/chuxorg/chux-models/errors"
+	"github.com/chuxorg/chux-models/logging"
+)
+
+// The separator used between levels of a Google Product Taxonomy path
+const taxonomyPathSeparator = " > "
+
+// TaxonomyNode is a single entry of the Google Product Taxonomy,
+// e.g. 3237 - Animals & Pet Supplies > Live Animals
+type TaxonomyNode struct {
+	ID       int      `bson:"id" json:"id"`
+	Name     string   `bson:"name" json:"name"`
+	Path     []string `bson:"path" json:"path"`
+	ParentID int      `bson:"parentId" json:"parentId"`
+	Children []int    `bson:"children" json:"children"`
+}
+
+// FullPath returns the node's path joined the same way
+// as the taxonomy file, e.g. "Animals & Pet Supplies > Live Animals"
+func (n *TaxonomyNode) FullPath() string {
+	return strings.Join(n.Path, taxonomyPathSeparator)
+}
+
+// Taxonomy is an in memory reference tree of the
+// Google Product Taxonomy.
+type Taxonomy struct {
+	Version string
+	nodes   map[int]*TaxonomyNode
+	byPath  map[string]int
+	roots   []int
+}
+
+// TaxonomyMatch is a suggested mapping from a Category
+// name to a Taxonomy node.
+type TaxonomyMatch struct {
+	Node  *TaxonomyNode
+	Score float64
+}
+
+// LoadTaxonomyFile reads the offline taxonomy-with-ids text file
+// published by Google, e.g. taxonomy-with-ids.en-US.txt
+func LoadTaxonomyFile(path string) (*Taxonomy, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.NewChuxModelsError("LoadTaxonomyFile() Unable to open taxonomy file", err)
+	}
+	defer file.Close()
+	return ParseTaxonomy(file)
+}
+
+// ParseTaxonomy reads the Google Product Taxonomy from r.
+// Each line has the form "<id> - <level 1> > <level 2> > ...".
+// Lines starting with '#' are comments, the version is taken
+// from the "# Google_Product_Taxonomy_Version:" header.
+func ParseTaxonomy(r io.Reader) (*Taxonomy, error) {
+	t := &Taxonomy{
+		nodes:  make(map[int]*TaxonomyNode),
+		byPath: make(map[string]int),
+	}
+
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			if idx := strings.Index(line, "Version:"); idx >= 0 {
+				t.Version = strings.TrimSpace(line[idx+len("Version:"):])
+			}
+			continue
+		}
+
+		parts := strings.SplitN(line, " - ", 2)
+		if len(parts) != 2 {
+			msg := fmt.Sprintf("ParseTaxonomy() Malformed taxonomy line %d: %s", lineNumber, line)
+			return nil, errors.NewChuxModelsError(msg, nil)
+		}
+		id, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+		if err != nil {
+			msg := fmt.Sprintf("ParseTaxonomy() Invalid taxonomy id on line %d: %s", lineNumber, parts[0])
+			return nil, errors.NewChuxModelsError(msg, err)
+		}
+
+		path := strings.Split(parts[1], taxonomyPathSeparator)
+		for i := range path {
+			path[i] = strings.TrimSpace(path[i])
+		}
+		node := &TaxonomyNode{
+			ID:   id,
+			Name: path[len(path)-1],
+			Path: path,
+		}
+		t.nodes[id] = node
+		t.byPath[strings.ToLower(node.FullPath())] = id
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.NewChuxModelsError("ParseTaxonomy() Error reading taxonomy", err)
+	}
+
+	// -- Link children to their parents now that every node is known
+	for _, node := range t.nodes {
+		if len(node.Path) == 1 {
+			t.roots = append(t.roots, node.ID)
+			continue
+		}
+		parentPath := strings.ToLower(strings.Join(node.Path[:len(node.Path)-1], taxonomyPathSeparator))
+		parentID, ok := t.byPath[parentPath]
+		if !ok {
+			msg := fmt.Sprintf("ParseTaxonomy() Missing parent for taxonomy node %d: %s", node.ID, node.FullPath())
+			return nil, errors.NewChuxModelsError(msg, nil)
+		}
+		node.ParentID = parentID
+		parent := t.nodes[parentID]
+		parent.Children = append(parent.Children, node.ID)
+	}
+	sort.Ints(t.roots)
+	for _, node := range t.nodes {
+		sort.Ints(node.Children)
+	}
+
+	return t, nil
+}
+
+// Len returns the number of nodes in the Taxonomy
+func (t *Taxonomy) Len() int {
+	return len(t.nodes)
+}
+
+// Node returns the Taxonomy node with the given id
+func (t *Taxonomy) Node(id int) (*TaxonomyNode, bool) {
+	node, ok := t.nodes[id]
+	return node, ok
+}
+
+// Roots returns the top level nodes of the Taxonomy
+func (t *Taxonomy) Roots() []*TaxonomyNode {
+	roots := make([]*TaxonomyNode, 0, len(t.roots))
+	for _, id := range t.roots {
+		roots = append(roots, t.nodes[id])
+	}
+	return roots
+}
+
+// Children returns the direct children of the node with the given id
+func (t *Taxonomy) Children(id int) []*TaxonomyNode {
+	node, ok := t.nodes[id]
+	if !ok {
+		return nil
+	}
+	children := make([]*TaxonomyNode, 0, len(node.Children))
+	for _, childID := range node.Children {
+		children = append(children, t.nodes[childID])
+	}
+	return children
+}
+
+// FindByPath returns the node with the given full path,
+// e.g. "Apparel & Accessories > Shoes". Matching is case insensitive.
+func (t *Taxonomy) FindByPath(path string) (*TaxonomyNode, bool) {
+	id, ok := t.byPath[strings.ToLower(strings.TrimSpace(path))]
+	if !ok {
+		return nil, false
+	}
+	return t.nodes[id], true
+}
+
+// Suggest returns up to limit Taxonomy nodes whose names are most similar
+// to name, best match first. The leaf name is weighted above the
+// rest of the path so "Shoes" prefers "... > Shoes" over "... > Shoe Accessories".
+func (t *Taxonomy) Suggest(name string, limit int) []TaxonomyMatch {
+	if limit <= 0 || NormalizeText(name) == "" {
+		return nil
+	}
+	matches := make([]TaxonomyMatch, 0, len(t.nodes))
+	for _, node := range t.nodes {
+		score := 0.8*NameSimilarity(name, node.Name) + 0.2*NameSimilarity(name, node.FullPath())
+		if score > 0 {
+			matches = append(matches, TaxonomyMatch{Node: node, Score: score})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		// -- Prefer the more general node on a tie
+		if len(matches[i].Node.Path) != len(matches[j].Node.Path) {
+			return len(matches[i].Node.Path) < len(matches[j].Node.Path)
+		}
+		return matches[i].Node.ID < matches[j].Node.ID
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// MapCategoriesToTaxonomy suggests a taxonomy mapping for every stored
+// Category and saves the ones that changed. Categories with a manual
+// override keep their mapping.
+func MapCategoriesToTaxonomy(logging logging.Logger, t *Taxonomy, minScore float64) error {
+	ctg := NewCategory()
+	ctg.Logger = &logging
+	categories, err := ctg.Query()
+	if err != nil {
+		logging.Error("MapCategoriesToTaxonomy() Error querying categories: %s", err.Error())
+		return errors.NewChuxModelsError("MapCategoriesToTaxonomy() Error querying categories", err)
+	}
+
+	mapped := 0
+	for _, doc := range categories {
+		category := doc.(*Category)
+		category.Logger = &logging
+		err := category.markLoaded()
+		if err != nil {
+			return errors.NewChuxModelsError("MapCategoriesToTaxonomy() Error loading category", err)
+		}
+		if !category.MapToTaxonomy(t, minScore) {
+			continue
+		}
+		err = category.Save()
+		if err != nil {
+			logging.Error("MapCategoriesToTaxonomy() Error saving category: %s", err.Error())
+			return errors.NewChuxModelsError("MapCategoriesToTaxonomy() Error saving category", err)
+		}
+		mapped++
+	}
+	logging.Info("MapCategoriesToTaxonomy() Mapped %d of %d categories", mapped, len(categories))
+	return nil
+}
@@ -0,0 +1,184 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Words and the whitespace between them, so a diff can be rendered
+// back into the text it was computed from
+var diffTokenRegex = regexp.MustCompile(`\s+|[^\s]+`)
+
+// DiffOperation is what a DiffOp does to the old text
+type DiffOperation string
+
+const (
+	DiffEqual  DiffOperation = "equal"
+	DiffInsert DiffOperation = "insert"
+	DiffDelete DiffOperation = "delete"
+)
+
+// DiffOp is a run of text that is kept, inserted or deleted
+type DiffOp struct {
+	Op   DiffOperation `bson:"op" json:"op"`
+	Text string        `bson:"text" json:"text"`
+}
+
+// DiffWords computes the word level difference between two texts with
+// Myers' algorithm. The ops applied in order turn from into to, the
+// equal and delete ops spell out from and the equal and insert ops to.
+func DiffWords(from, to string) []DiffOp {
+	a := diffTokenRegex.FindAllString(from, -1)
+	b := diffTokenRegex.FindAllString(to, -1)
+
+	// -- Leave the common prefix and suffix out of the search
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []DiffOp
+	ops = appendDiffOp(ops, DiffEqual, a[:prefix]...)
+	for _, op := range myersDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]) {
+		ops = appendDiffOp(ops, op.Op, op.Text)
+	}
+	ops = appendDiffOp(ops, DiffEqual, a[len(a)-suffix:]...)
+	return ops
+}
+
+// Texts that differ by more tokens than this are diffed as a whole,
+// the search takes memory in the square of the number of edits
+const maxDiffEdits = 2000
+
+// Finds the shortest edit script from a to b, one op per token
+func myersDiff(a, b []string) []DiffOp {
+	n, m := len(a), len(b)
+	max := n + m
+	if max == 0 {
+		return nil
+	}
+	if max > maxDiffEdits {
+		max = maxDiffEdits
+	}
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	// -- Each round only reads the diagonals next to the ones it
+	// writes, so the trace keeps diagonals -d-1 to d+1 of round d
+	var trace [][]int
+	for d := 0; d <= max; d++ {
+		snapshot := make([]int, 2*d+3)
+		copy(snapshot, v[offset-d-1:offset+d+2])
+		trace = append(trace, snapshot)
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrackDiff(a, b, trace)
+			}
+		}
+	}
+	ops := make([]DiffOp, 0, n+m)
+	for _, token := range a {
+		ops = append(ops, DiffOp{Op: DiffDelete, Text: token})
+	}
+	for _, token := range b {
+		ops = append(ops, DiffOp{Op: DiffInsert, Text: token})
+	}
+	return ops
+}
+
+// Walks the trace of the search back from the end to recover the ops
+func backtrackDiff(a, b []string, trace [][]int) []DiffOp {
+	x, y := len(a), len(b)
+	var reversed []DiffOp
+	for d := len(trace) - 1; d > 0; d-- {
+		// -- Diagonal k of round d is at k+d+1 in its snapshot
+		v := trace[d]
+		k := x - y
+		var previousK int
+		if k == -d || (k != d && v[k-1+d+1] < v[k+1+d+1]) {
+			previousK = k + 1
+		} else {
+			previousK = k - 1
+		}
+		previousX := v[previousK+d+1]
+		previousY := previousX - previousK
+		for x > previousX && y > previousY {
+			x--
+			y--
+			reversed = append(reversed, DiffOp{Op: DiffEqual, Text: a[x]})
+		}
+		if x == previousX {
+			y--
+			reversed = append(reversed, DiffOp{Op: DiffInsert, Text: b[y]})
+		} else {
+			x--
+			reversed = append(reversed, DiffOp{Op: DiffDelete, Text: a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		reversed = append(reversed, DiffOp{Op: DiffEqual, Text: a[x]})
+	}
+
+	ops := make([]DiffOp, len(reversed))
+	for i, op := range reversed {
+		ops[len(reversed)-1-i] = op
+	}
+	return ops
+}
+
+// Appends tokens to the last op when it has the same operation
+func appendDiffOp(ops []DiffOp, op DiffOperation, tokens ...string) []DiffOp {
+	if len(tokens) == 0 {
+		return ops
+	}
+	text := strings.Join(tokens, "")
+	if len(ops) > 0 && ops[len(ops)-1].Op == op {
+		ops[len(ops)-1].Text += text
+		return ops
+	}
+	return append(ops, DiffOp{Op: op, Text: text})
+}
+
+// DiffChanged returns true when the ops insert or delete any text
+func DiffChanged(ops []DiffOp) bool {
+	for _, op := range ops {
+		if op.Op != DiffEqual {
+			return true
+		}
+	}
+	return false
+}
+
+// RenderDiff writes ops as text in the style of git's word diff,
+// deleted text as [-text-] and inserted text as {+text+}
+func RenderDiff(ops []DiffOp) string {
+	var b strings.Builder
+	for _, op := range ops {
+		switch op.Op {
+		case DiffInsert:
+			b.WriteString("{+" + op.Text + "+}")
+		case DiffDelete:
+			b.WriteString("[-" + op.Text + "-]")
+		default:
+			b.WriteString(op.Text)
+		}
+	}
+	return b.String()
+}
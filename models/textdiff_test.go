@@ -0,0 +1,68 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffWords(t *testing.T) {
+	tests := []struct {
+		from   string
+		to     string
+		want   []DiffOp
+		render string
+	}{
+		{"", "", nil, ""},
+		{"same text", "same text", []DiffOp{{DiffEqual, "same text"}}, "same text"},
+		{"", "new text", []DiffOp{{DiffInsert, "new text"}}, "{+new text+}"},
+		{"old text", "", []DiffOp{{DiffDelete, "old text"}}, "[-old text-]"},
+		{
+			"the quick brown fox", "the slow brown fox",
+			[]DiffOp{{DiffEqual, "the "}, {DiffDelete, "quick"}, {DiffInsert, "slow"}, {DiffEqual, " brown fox"}},
+			"the [-quick-]{+slow+} brown fox",
+		},
+		{
+			"Mayor resigns", "Mayor resigns after vote",
+			[]DiffOp{{DiffEqual, "Mayor resigns"}, {DiffInsert, " after vote"}},
+			"Mayor resigns{+ after vote+}",
+		},
+		{
+			"a b c d", "a c d",
+			[]DiffOp{{DiffEqual, "a "}, {DiffDelete, "b "}, {DiffEqual, "c d"}},
+			"a [-b -]c d",
+		},
+	}
+	for _, tt := range tests {
+		got := DiffWords(tt.from, tt.to)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("DiffWords(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+		if rendered := RenderDiff(got); rendered != tt.render {
+			t.Errorf("RenderDiff(DiffWords(%q, %q)) = %q, want %q", tt.from, tt.to, rendered, tt.render)
+		}
+	}
+}
+
+// The equal and delete ops spell out the old text and the equal and insert ops the new one
+func TestDiffWordsSpellsOutBothTexts(t *testing.T) {
+	tests := []struct{ from, to string }{
+		{"one two three four five", "one three four six five seven"},
+		{"The Council voted on Tuesday.\n\nIt passed.", "The council voted on Wednesday.\n\nIt passed narrowly."},
+		{"a a a b b b", "b b b a a a"},
+	}
+	for _, tt := range tests {
+		var from, to strings.Builder
+		for _, op := range DiffWords(tt.from, tt.to) {
+			if op.Op != DiffInsert {
+				from.WriteString(op.Text)
+			}
+			if op.Op != DiffDelete {
+				to.WriteString(op.Text)
+			}
+		}
+		if from.String() != tt.from || to.String() != tt.to {
+			t.Errorf("DiffWords(%q, %q) spells out %q and %q", tt.from, tt.to, from.String(), to.String())
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package models
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Folds common accented latin characters into their ascii
+// equivalents so "Café" and "Cafe" compare as equal.
+var foldReplacer = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ä", "a", "ã", "a", "å", "a",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i",
+	"ó", "o", "ò", "o", "ô", "o", "ö", "o", "õ", "o", "ø", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u",
+	"ç", "c", "ñ", "n", "ß", "ss", "ÿ", "y",
+)
+
+// NormalizeText lower cases a string, folds accents, replaces
+// punctuation with spaces and collapses repeated whitespace.
+// "&" is rewritten to "and" so "Toys & Games" matches "Toys and Games".
+func NormalizeText(s string) string {
+	s = foldReplacer.Replace(strings.ToLower(s))
+	s = strings.ReplaceAll(s, "&", " and ")
+	var b strings.Builder
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// Tokenize returns the normalized words of a string
+func Tokenize(s string) []string {
+	normalized := NormalizeText(s)
+	if normalized == "" {
+		return nil
+	}
+	return strings.Split(normalized, " ")
+}
+
+// JaccardSimilarity returns the size of the intersection divided by
+// the size of the union of the tokens in a and b.
+func JaccardSimilarity(a, b []string) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 0
+	}
+	setA := make(map[string]bool, len(a))
+	for _, t := range a {
+		setA[t] = true
+	}
+	setB := make(map[string]bool, len(b))
+	for _, t := range b {
+		setB[t] = true
+	}
+	intersection := 0
+	for t := range setA {
+		if setB[t] {
+			intersection++
+		}
+	}
+	union := len(setA) + len(setB) - intersection
+	if union == 0 {
+		return 0
+	}
+	return float64(intersection) / float64(union)
+}
+
+// LevenshteinDistance returns the number of single rune edits
+// needed to turn a into b.
+func LevenshteinDistance(a, b string) int {
+	ra := []rune(a)
+	rb := []rune(b)
+	if len(ra) == 0 {
+		return len(rb)
+	}
+	if len(rb) == 0 {
+		return len(ra)
+	}
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(minInt(prev[j]+1, curr[j-1]+1), prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// NameSimilarity scores how alike two names are between 0 and 1.
+// It blends token overlap, which is robust to word order, with
+// edit distance, which is robust to typos and plurals.
+func NameSimilarity(a, b string) float64 {
+	na := NormalizeText(a)
+	nb := NormalizeText(b)
+	if na == "" || nb == "" {
+		return 0
+	}
+	if na == nb {
+		return 1
+	}
+	tokenScore := JaccardSimilarity(stemTokens(strings.Split(na, " ")), stemTokens(strings.Split(nb, " ")))
+	longest := len([]rune(na))
+	if l := len([]rune(nb)); l > longest {
+		longest = l
+	}
+	editScore := 1 - float64(LevenshteinDistance(na, nb))/float64(longest)
+	return 0.6*tokenScore + 0.4*editScore
+}
+
+// Strips a plural "s" from words so "Headphones" matches "Headphone"
+func stemTokens(tokens []string) []string {
+	stemmed := make([]string, len(tokens))
+	for i, token := range tokens {
+		if len(token) > 3 && strings.HasSuffix(token, "s") && !strings.HasSuffix(token, "ss") {
+			token = token[:len(token)-1]
+		}
+		stemmed[i] = token
+	}
+	return stemmed
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
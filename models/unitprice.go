@@ -0,0 +1,270 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/chuxorg/chux-models/errors"
+)
+
+// UnitPrice is the price of an Offer per unit of measure,
+// e.g. 0.25 USD per fl oz.
+type UnitPrice struct {
+	Value     float64   `bson:"value" json:"value"`
+	Unit      string    `bson:"unit" json:"unit"`
+	Currency  string    `bson:"currency,omitempty" json:"currency,omitempty"`
+	Dimension Dimension `bson:"dimension" json:"dimension"`
+	// The price per SI unit, used to compare unit prices given in different units
+	SIValue float64 `bson:"siValue" json:"siValue"`
+}
+
+// String formats the UnitPrice, e.g. "0.25 USD/fl oz"
+func (u UnitPrice) String() string {
+	return fmt.Sprintf("%.4g %s/%s", u.Value, u.Currency, u.Unit)
+}
+
+// PackageSize is how much of a product an Offer buys,
+// e.g. 12 items of 355 ml each.
+type PackageSize struct {
+	Count int      `bson:"count" json:"count"`
+	Each  Quantity `bson:"each" json:"each"`
+}
+
+// Total returns the total quantity of the package. A package
+// of items without a measure is counted in items.
+func (s PackageSize) Total() Quantity {
+	if s.Each.Unit == "" {
+		q, _ := NewQuantity(float64(s.Count), "ct")
+		return q
+	}
+	q, _ := NewQuantity(s.Each.Value*float64(s.Count), s.Each.Unit)
+	return q
+}
+
+// The units unit prices are shown in. US shoppers compare by
+// ounce, everyone else by kilogram and liter.
+var (
+	usUnitPriceUnits = map[Dimension]string{
+		DimensionMass:   "oz",
+		DimensionVolume: "fl oz",
+		DimensionLength: "ft",
+		DimensionCount:  "ct",
+	}
+	metricUnitPriceUnits = map[Dimension]string{
+		DimensionMass:   "kg",
+		DimensionVolume: "l",
+		DimensionLength: "m",
+		DimensionCount:  "ct",
+	}
+)
+
+var (
+	// "12 x 355 ml", "6 x 1.5L"
+	multipackRegex = regexp.MustCompile(`(?i)\b(\d+)\s*[x×]\s*(\d+(?:[.,]\d+)?\s*(?:fl\.?\s*oz\.?|[a-z]+\.?))`)
+	// "Pack of 3", "Case of 24", "Set of 4", "Box of 100"
+	packOfRegex = regexp.MustCompile(`(?i)\b(?:pack|case|set|box|bag|bundle|lot)\s+of\s+(\d+)\b`)
+	// "3-Pack", "3 pk", "24 count", "100 ct", "4 pcs", "2 pieces"
+	countRegex = regexp.MustCompile(`(?i)\b(\d+)[\s-]*(?:pack|pk|count|ct|pcs|pieces|piece|rolls|pods|capsules|tablets)\b`)
+	// Everything but digits and separators in a price
+	priceCleanRegex = regexp.MustCompile(`[^\d.,]`)
+)
+
+// ParsePrice reads a price string such as "$1,299.99", "1.299,99 €"
+// or "USD 19.99" into a number.
+func ParsePrice(price string) (float64, error) {
+	cleaned := priceCleanRegex.ReplaceAllString(price, "")
+	cleaned = strings.Trim(cleaned, ".,")
+	if cleaned == "" {
+		msg := fmt.Sprintf("ParsePrice() No price found in: %s", price)
+		return 0, errors.NewChuxModelsError(msg, nil)
+	}
+
+	value, err := strconv.ParseFloat(normalizeDecimal(cleaned, true), 64)
+	if err != nil {
+		msg := fmt.Sprintf("ParsePrice() Unable to parse price: %s", price)
+		return 0, errors.NewChuxModelsError(msg, err)
+	}
+	return value, nil
+}
+
+// ParsePackageSize finds the package size in text such as a product name.
+// It understands multipacks ("12 x 355 ml"), pack counts ("Pack of 3",
+// "6 ct") and single measures ("16 oz"), and combines a pack count with
+// a measure found elsewhere in the text ("Pack of 2, 16 oz each").
+func ParsePackageSize(text string) (PackageSize, bool) {
+	if match := multipackRegex.FindStringSubmatch(text); match != nil {
+		count, _ := strconv.Atoi(match[1])
+		quantities := ParseQuantities(match[2])
+		if count > 0 && len(quantities) > 0 && quantities[0].Dimension != DimensionLength {
+			return PackageSize{Count: count, Each: quantities[0]}, true
+		}
+	}
+
+	count := 0
+	if match := packOfRegex.FindStringSubmatch(text); match != nil {
+		count, _ = strconv.Atoi(match[1])
+	} else if match := countRegex.FindStringSubmatch(text); match != nil {
+		count, _ = strconv.Atoi(match[1])
+	}
+
+	for _, q := range ParseQuantities(text) {
+		switch q.Dimension {
+		case DimensionMass, DimensionVolume:
+			if count < 1 {
+				count = 1
+			}
+			return PackageSize{Count: count, Each: q}, true
+		}
+	}
+	if count > 0 {
+		return PackageSize{Count: count}, true
+	}
+	return PackageSize{}, false
+}
+
+// PackageSize finds the package size of the Product in its Name,
+// then in its AdditionalProperties.
+func (p *Product) PackageSize() (PackageSize, bool) {
+	nameSize, nameOK := ParsePackageSize(p.Name)
+	if nameOK && nameSize.Each.Unit != "" {
+		return nameSize, true
+	}
+
+	count := 0
+	var each Quantity
+	for _, attribute := range NormalizeAttributes(p.AdditionalProperties) {
+		switch attribute.Key {
+		case "count":
+			if q, ok := attribute.Quantity(); ok && count == 0 {
+				count = int(q.Value)
+			}
+		case "weight", "volume", "capacity":
+			if q, ok := attribute.Quantity(); ok && each.Unit == "" &&
+				(q.Dimension == DimensionMass || q.Dimension == DimensionVolume) {
+				each = q
+			}
+		}
+	}
+	if nameOK && count == 0 {
+		count = nameSize.Count
+	}
+	if each.Unit != "" {
+		if count < 1 {
+			count = 1
+		}
+		return PackageSize{Count: count, Each: each}, true
+	}
+	if count > 0 {
+		return PackageSize{Count: count}, true
+	}
+	return PackageSize{}, false
+}
+
+// ComputeUnitPrice returns the price per unit of an Offer for a package size.
+// Unit prices are given per ounce for USD and per kilogram or liter otherwise.
+func (o *Offer) ComputeUnitPrice(size PackageSize) (*UnitPrice, error) {
+	price, err := ParsePrice(o.Price)
+	if err != nil {
+		return nil, err
+	}
+	total := size.Total()
+	if total.SIValue <= 0 {
+		return nil, errors.NewChuxModelsError("Offer.ComputeUnitPrice() Package size is empty", nil)
+	}
+
+	displayUnits := metricUnitPriceUnits
+	if strings.EqualFold(o.Currency, "USD") || strings.HasPrefix(strings.TrimSpace(o.Price), "$") {
+		displayUnits = usUnitPriceUnits
+	}
+	display, err := total.ConvertTo(displayUnits[total.Dimension])
+	if err != nil {
+		return nil, err
+	}
+	return &UnitPrice{
+		Value:     roundTo(price/display.Value, 4),
+		Unit:      display.Unit,
+		Currency:  o.Currency,
+		Dimension: total.Dimension,
+		SIValue:   price / total.SIValue,
+	}, nil
+}
+
+// ComputeUnitPrices sets the UnitPrice of every Offer of the Product.
+// Offers are left without a UnitPrice when the package size or price is unknown.
+func (p *Product) ComputeUnitPrices() {
+	logging := p.Logger
+	logging.Debug("Product.ComputeUnitPrices() was called")
+	size, ok := p.PackageSize()
+	for i := range p.Offers {
+		p.Offers[i].UnitPrice = nil
+		if !ok {
+			continue
+		}
+		unitPrice, err := p.Offers[i].ComputeUnitPrice(size)
+		if err != nil {
+			logging.Debug("Product.ComputeUnitPrices() No unit price for offer %d: %s", i, err.Error())
+			continue
+		}
+		p.Offers[i].UnitPrice = unitPrice
+	}
+}
+
+// LowestUnitPrice returns the lowest UnitPrice of the Product's Offers
+func (p *Product) LowestUnitPrice() (*UnitPrice, bool) {
+	var lowest *UnitPrice
+	for _, offer := range p.Offers {
+		if offer.UnitPrice == nil {
+			continue
+		}
+		if lowest == nil || offer.UnitPrice.SIValue < lowest.SIValue {
+			lowest = offer.UnitPrice
+		}
+	}
+	return lowest, lowest != nil
+}
+
+// SortByUnitPrice sorts Products by their lowest unit price. Products are
+// grouped by the dimension they are measured in, so price per weight and
+// price per volume are never compared, and Products without a unit price come last.
+func SortByUnitPrice(products []*Product, ascending bool) {
+	sort.SliceStable(products, func(i, j int) bool {
+		a, okA := products[i].LowestUnitPrice()
+		b, okB := products[j].LowestUnitPrice()
+		if !okA || !okB {
+			return okA && !okB
+		}
+		if a.Dimension != b.Dimension {
+			return a.Dimension < b.Dimension
+		}
+		if ascending {
+			return a.SIValue < b.SIValue
+		}
+		return a.SIValue > b.SIValue
+	})
+}
+
+// QuerySortedByUnitPrice queries Products like Query and
+// returns them sorted by their lowest unit price.
+func (p *Product) QuerySortedByUnitPrice(ascending bool, args ...interface{}) ([]*Product, error) {
+	logging := p.Logger
+	logging.Debug("Product.QuerySortedByUnitPrice() was called")
+	results, err := p.Query(args...)
+	if err != nil {
+		return nil, err
+	}
+	products := make([]*Product, 0, len(results))
+	for _, result := range results {
+		products = append(products, result.(*Product))
+	}
+	SortByUnitPrice(products, ascending)
+	return products, nil
+}
+
+func roundTo(value float64, places int) float64 {
+	shift := math.Pow(10, float64(places))
+	return math.Round(value*shift) / shift
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParsePrice(t *testing.T) {
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{"$1,299.99", 1299.99},
+		{"1.299,99 €", 1299.99},
+		{"USD 19.99", 19.99},
+		{"19,99 €", 19.99},
+		{"1.299 €", 1299},
+		{"$1,200", 1200},
+	}
+	for _, test := range tests {
+		got, err := ParsePrice(test.input)
+		if err != nil || math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("ParsePrice(%q) = %v, %v, want %v", test.input, got, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/chuxorg/chux-models/errors"
+)
+
+// Dimension is the physical quantity a Unit measures
+type Dimension string
+
+const (
+	DimensionLength   Dimension = "length"
+	DimensionMass     Dimension = "mass"
+	DimensionVolume   Dimension = "volume"
+	DimensionCapacity Dimension = "capacity"
+	DimensionPower    Dimension = "power"
+	DimensionDataSize Dimension = "dataSize"
+	DimensionCount    Dimension = "count"
+)
+
+// Unit is a unit of measure and its factor to the SI unit of its Dimension.
+// Volume is measured in cubic meters, battery capacity in coulombs
+// and data size in bytes.
+type Unit struct {
+	Symbol    string
+	Dimension Dimension
+	Factor    float64
+}
+
+// The SI unit of each Dimension
+var siUnits = map[Dimension]string{
+	DimensionLength:   "m",
+	DimensionMass:     "kg",
+	DimensionVolume:   "m3",
+	DimensionCapacity: "C",
+	DimensionPower:    "W",
+	DimensionDataSize: "B",
+	DimensionCount:    "ct",
+}
+
+// Units keyed by their symbol
+var units = map[string]Unit{
+	// -- length
+	"mm": {"mm", DimensionLength, 0.001},
+	"cm": {"cm", DimensionLength, 0.01},
+	"m":  {"m", DimensionLength, 1},
+	"km": {"km", DimensionLength, 1000},
+	"in": {"in", DimensionLength, 0.0254},
+	"ft": {"ft", DimensionLength, 0.3048},
+	"yd": {"yd", DimensionLength, 0.9144},
+	"mi": {"mi", DimensionLength, 1609.344},
+	// -- mass
+	"mg": {"mg", DimensionMass, 0.000001},
+	"g":  {"g", DimensionMass, 0.001},
+	"kg": {"kg", DimensionMass, 1},
+	"oz": {"oz", DimensionMass, 0.028349523125},
+	"lb": {"lb", DimensionMass, 0.45359237},
+	"t":  {"t", DimensionMass, 1000},
+	// -- volume
+	"ml":    {"ml", DimensionVolume, 0.000001},
+	"cl":    {"cl", DimensionVolume, 0.00001},
+	"l":     {"l", DimensionVolume, 0.001},
+	"fl oz": {"fl oz", DimensionVolume, 0.0000295735295625},
+	"cup":   {"cup", DimensionVolume, 0.0002365882365},
+	"pt":    {"pt", DimensionVolume, 0.000473176473},
+	"qt":    {"qt", DimensionVolume, 0.000946352946},
+	"gal":   {"gal", DimensionVolume, 0.003785411784},
+	"cu ft": {"cu ft", DimensionVolume, 0.028316846592},
+	"m3":    {"m3", DimensionVolume, 1},
+	// -- battery capacity
+	"mAh": {"mAh", DimensionCapacity, 3.6},
+	"Ah":  {"Ah", DimensionCapacity, 3600},
+	"C":   {"C", DimensionCapacity, 1},
+	// -- power
+	"mW": {"mW", DimensionPower, 0.001},
+	"W":  {"W", DimensionPower, 1},
+	"kW": {"kW", DimensionPower, 1000},
+	"hp": {"hp", DimensionPower, 745.69987158227022},
+	// -- data size, decimal and binary prefixes
+	"bit": {"bit", DimensionDataSize, 0.125},
+	"B":   {"B", DimensionDataSize, 1},
+	"KB":  {"KB", DimensionDataSize, 1e3},
+	"MB":  {"MB", DimensionDataSize, 1e6},
+	"GB":  {"GB", DimensionDataSize, 1e9},
+	"TB":  {"TB", DimensionDataSize, 1e12},
+	"PB":  {"PB", DimensionDataSize, 1e15},
+	"KiB": {"KiB", DimensionDataSize, 1024},
+	"MiB": {"MiB", DimensionDataSize, 1048576},
+	"GiB": {"GiB", DimensionDataSize, 1073741824},
+	"TiB": {"TiB", DimensionDataSize, 1099511627776},
+	// -- count
+	"ct": {"ct", DimensionCount, 1},
+}
+
+// Spellings of units, lower cased, mapped to the unit symbol
+var unitAliases = map[string]string{
+	"mm": "mm", "millimeter": "mm", "millimeters": "mm", "millimetre": "mm", "millimetres": "mm",
+	"cm": "cm", "centimeter": "cm", "centimeters": "cm", "centimetre": "cm", "centimetres": "cm",
+	"m": "m", "meter": "m", "meters": "m", "metre": "m", "metres": "m",
+	"km": "km", "kilometer": "km", "kilometers": "km", "kilometre": "km", "kilometres": "km",
+	"in": "in", "in.": "in", "inch": "in", "inches": "in", "\"": "in", "”": "in",
+	"ft": "ft", "ft.": "ft", "foot": "ft", "feet": "ft", "'": "ft", "’": "ft",
+	"yd": "yd", "yard": "yd", "yards": "yd",
+	"mi": "mi", "mile": "mi", "miles": "mi",
+	"mg": "mg", "milligram": "mg", "milligrams": "mg",
+	"g": "g", "gr": "g", "gram": "g", "grams": "g", "gramme": "g", "grammes": "g",
+	"kg": "kg", "kgs": "kg", "kilo": "kg", "kilos": "kg", "kilogram": "kg", "kilograms": "kg",
+	"oz": "oz", "oz.": "oz", "ounce": "oz", "ounces": "oz",
+	"lb": "lb", "lb.": "lb", "lbs": "lb", "lbs.": "lb", "pound": "lb", "pounds": "lb",
+	"t": "t", "tonne": "t", "tonnes": "t",
+	"ml": "ml", "milliliter": "ml", "milliliters": "ml", "millilitre": "ml", "millilitres": "ml",
+	"cl": "cl", "centiliter": "cl", "centiliters": "cl", "centilitre": "cl", "centilitres": "cl",
+	"l": "l", "ltr": "l", "liter": "l", "liters": "l", "litre": "l", "litres": "l",
+	"fl oz": "fl oz", "fl. oz.": "fl oz", "fl.oz.": "fl oz", "fl. oz": "fl oz", "floz": "fl oz", "fluid ounce": "fl oz", "fluid ounces": "fl oz",
+	"cup": "cup", "cups": "cup",
+	"pt": "pt", "pint": "pt", "pints": "pt",
+	"qt": "qt", "quart": "qt", "quarts": "qt",
+	"gal": "gal", "gallon": "gal", "gallons": "gal",
+	"cu ft": "cu ft", "cu. ft.": "cu ft", "cubic foot": "cu ft", "cubic feet": "cu ft",
+	"m3": "m3", "m³": "m3", "cubic meter": "m3", "cubic meters": "m3",
+	"mah": "mAh", "ah": "Ah",
+	"mw": "mW", "w": "W", "watt": "W", "watts": "W", "kw": "kW", "kilowatt": "kW", "kilowatts": "kW",
+	"hp": "hp", "horsepower": "hp",
+	"bit": "bit", "bits": "bit",
+	"byte": "B", "bytes": "B",
+	"kb": "KB", "kilobyte": "KB", "kilobytes": "KB",
+	"mb": "MB", "megabyte": "MB", "megabytes": "MB",
+	"gb": "GB", "gigabyte": "GB", "gigabytes": "GB",
+	"tb": "TB", "terabyte": "TB", "terabytes": "TB",
+	"pb": "PB", "petabyte": "PB", "petabytes": "PB",
+	"kib": "KiB", "mib": "MiB", "gib": "GiB", "tib": "TiB",
+	"ct": "ct", "count": "ct", "pc": "ct", "pcs": "ct", "piece": "ct", "pieces": "ct", "pk": "ct", "pack": "ct",
+}
+
+// Quantity is a number with a unit of measure and its value in SI units
+type Quantity struct {
+	Value     float64   `bson:"value" json:"value"`
+	Unit      string    `bson:"unit" json:"unit"`
+	Dimension Dimension `bson:"dimension" json:"dimension"`
+	SIValue   float64   `bson:"siValue" json:"siValue"`
+	SIUnit    string    `bson:"siUnit" json:"siUnit"`
+}
+
+// String formats the Quantity, e.g. "2.5 lb"
+func (q Quantity) String() string {
+	return strconv.FormatFloat(q.Value, 'f', -1, 64) + " " + q.Unit
+}
+
+// ConvertTo returns the Quantity converted to another unit of the same Dimension
+func (q Quantity) ConvertTo(symbol string) (Quantity, error) {
+	unit, ok := LookupUnit(symbol)
+	if !ok {
+		return Quantity{}, errors.NewChuxModelsError(fmt.Sprintf("Quantity.ConvertTo() Unknown unit: %s", symbol), nil)
+	}
+	if unit.Dimension != q.Dimension {
+		msg := fmt.Sprintf("Quantity.ConvertTo() Cannot convert %s to %s", q.Dimension, unit.Dimension)
+		return Quantity{}, errors.NewChuxModelsError(msg, nil)
+	}
+	return NewQuantity(q.SIValue/unit.Factor, unit.Symbol)
+}
+
+// NewQuantity creates a Quantity from a value and a unit symbol or alias
+func NewQuantity(value float64, unitName string) (Quantity, error) {
+	unit, ok := LookupUnit(unitName)
+	if !ok {
+		return Quantity{}, errors.NewChuxModelsError(fmt.Sprintf("NewQuantity() Unknown unit: %s", unitName), nil)
+	}
+	return Quantity{
+		Value:     value,
+		Unit:      unit.Symbol,
+		Dimension: unit.Dimension,
+		SIValue:   value * unit.Factor,
+		SIUnit:    siUnits[unit.Dimension],
+	}, nil
+}
+
+// LookupUnit finds a Unit by its symbol or any of its spellings
+func LookupUnit(name string) (Unit, bool) {
+	if unit, ok := units[name]; ok {
+		return unit, true
+	}
+	symbol, ok := lookupUnitAlias(name)
+	if !ok {
+		return Unit{}, false
+	}
+	return units[symbol], true
+}
+
+// Returns the unit symbol of a spelling of a unit
+func lookupUnitAlias(name string) (string, bool) {
+	alias := strings.ToLower(strings.Join(strings.Fields(name), " "))
+	symbol, ok := unitAliases[alias]
+	if !ok {
+		symbol, ok = unitAliases[strings.TrimSuffix(alias, ".")]
+	}
+	return symbol, ok
+}
+
+const (
+	// Thousands separated by "," or "." and a decimal part, e.g. "1,200" or "1.234,5"
+	numberPattern = `(\d+(?:[.,]\d{3})*(?:[.,]\d+)?)`
+	unitPattern   = `(fl\.?\s*oz\.?|cu\.?\s*ft\.?|cubic\s+(?:feet|foot|meters?)|fluid\s+ounces?|[a-zA-Zµ³]+\.?|["'”’])`
+	// The same as unitPattern but never a lone "x", which separates dimensions
+	lengthUnitPattern = `([a-wyzA-WYZ][a-zA-Z]*\.?|["'”’])`
+)
+
+var (
+	// A number optionally followed by a unit, e.g. "2.5 lbs" or "64GB"
+	quantityRegex = regexp.MustCompile(`(?i)` + numberPattern + `\s*` + unitPattern + `?`)
+	// Two or three numbers separated by "x", e.g. "10 x 5 x 3 in"
+	dimensionsRegex = regexp.MustCompile(`(?i)` + numberPattern + `\s*` + lengthUnitPattern + `?\s*[x×*]\s*` +
+		numberPattern + `\s*` + lengthUnitPattern + `?(?:\s*[x×*]\s*` + numberPattern + `\s*` + lengthUnitPattern + `?)?`)
+)
+
+// ParseQuantities finds the quantities with a known unit in a string.
+// "10 x 5 x 3 in" yields three lengths, the unit after the last number
+// applies to numbers without a unit of their own.
+func ParseQuantities(s string) []Quantity {
+	quantities := make([]Quantity, 0)
+	consumed := make([]bool, len(s))
+
+	for _, match := range dimensionsRegex.FindAllStringSubmatchIndex(s, -1) {
+		// -- Groups alternate number, unit for up to three numbers
+		values := make([]string, 0, 3)
+		unitNames := make([]string, 0, 3)
+		for group := 1; group <= 5; group += 2 {
+			if match[2*group] < 0 {
+				continue
+			}
+			values = append(values, s[match[2*group]:match[2*group+1]])
+			unitName := ""
+			if match[2*group+2] >= 0 {
+				unitName = s[match[2*group+2]:match[2*group+3]]
+			}
+			unitNames = append(unitNames, unitName)
+		}
+		// -- Only lengths are written this way, "12 x 355 ml" is a multipack
+		last, ok := LookupUnit(unitNames[len(unitNames)-1])
+		if !ok || last.Dimension != DimensionLength {
+			continue
+		}
+		for i, value := range values {
+			unitName := unitNames[i]
+			if unit, ok := LookupUnit(unitName); !ok || unit.Dimension != DimensionLength {
+				unitName = last.Symbol
+			}
+			if q, ok := makeQuantity(value, unitName); ok {
+				quantities = append(quantities, q)
+			}
+		}
+		for i := match[0]; i < match[1]; i++ {
+			consumed[i] = true
+		}
+	}
+
+	for _, match := range quantityRegex.FindAllStringSubmatchIndex(s, -1) {
+		if consumed[match[0]] || match[4] < 0 {
+			continue
+		}
+		// -- Skip numbers that are part of a word, e.g. the "3" in "MP3"
+		if match[0] > 0 && isWordByte(s[match[0]-1]) {
+			continue
+		}
+		unitEnd := match[5]
+		if unitEnd < len(s) && isWordByte(s[unitEnd]) {
+			continue
+		}
+		if q, ok := makeQuantity(s[match[2]:match[3]], s[match[4]:match[5]]); ok {
+			quantities = append(quantities, q)
+		}
+	}
+	return quantities
+}
+
+// ParseQuantity returns the first quantity of a Dimension found in s
+func ParseQuantity(s string, dimension Dimension) (Quantity, bool) {
+	for _, q := range ParseQuantities(s) {
+		if q.Dimension == dimension {
+			return q, true
+		}
+	}
+	return Quantity{}, false
+}
+
+func makeQuantity(value, unitName string) (Quantity, bool) {
+	// -- "0.125 kg" is an eighth of a kilogram, a single dot is always a decimal point
+	number, err := strconv.ParseFloat(normalizeDecimal(value, false), 64)
+	if err != nil {
+		return Quantity{}, false
+	}
+	// -- Text is only matched against spellings, so a bare "C" is
+	// -- never read as coulombs
+	symbol, ok := lookupUnitAlias(unitName)
+	if !ok {
+		return Quantity{}, false
+	}
+	q, err := NewQuantity(number, symbol)
+	if err != nil {
+		return Quantity{}, false
+	}
+	return q, true
+}
+
+// Rewrites a number that uses "." or "," as its decimal separator to
+// use ".", without thousands separators. When both are used the later
+// one is the decimal separator. A single comma followed by one or two
+// digits is a decimal separator, other commas separate thousands.
+// Several dots separate thousands, and so does a single dot followed by
+// three digits when dotThousands is set, as in the price "1.299".
+func normalizeDecimal(number string, dotThousands bool) string {
+	lastDot := strings.LastIndex(number, ".")
+	lastComma := strings.LastIndex(number, ",")
+	switch {
+	case lastDot >= 0 && lastComma >= 0:
+		// -- The later separator is the decimal separator
+		if lastComma > lastDot {
+			number = strings.ReplaceAll(number, ".", "")
+			number = strings.Replace(number, ",", ".", 1)
+		} else {
+			number = strings.ReplaceAll(number, ",", "")
+		}
+	case lastComma >= 0:
+		if strings.Count(number, ",") == 1 && len(number)-lastComma-1 <= 2 {
+			number = strings.Replace(number, ",", ".", 1)
+		} else {
+			number = strings.ReplaceAll(number, ",", "")
+		}
+	case lastDot >= 0:
+		if strings.Count(number, ".") > 1 || (dotThousands && len(number)-lastDot-1 == 3) {
+			number = strings.ReplaceAll(number, ".", "")
+		}
+	}
+	return number
+}
+
+func isWordByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9'
+}
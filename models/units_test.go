@@ -0,0 +1,36 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseQuantities(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []Quantity
+	}{
+		{"Power bank 1,200 mAh", []Quantity{{Value: 1200, Unit: "mAh"}}},
+		{"Power bank 10,000mAh", []Quantity{{Value: 10000, Unit: "mAh"}}},
+		{"Weight 2,5 kg", []Quantity{{Value: 2.5, Unit: "kg"}}},
+		{"Weight 2.5 lbs", []Quantity{{Value: 2.5, Unit: "lb"}}},
+		{"Sugar 0.125 kg", []Quantity{{Value: 0.125, Unit: "kg"}}},
+		{"Tank 1.234,5 l", []Quantity{{Value: 1234.5, Unit: "l"}}},
+		{"Drive 1,234.5 GB", []Quantity{{Value: 1234.5, Unit: "GB"}}},
+		{"Box 10 x 5 x 3 in", []Quantity{{Value: 10, Unit: "in"}, {Value: 5, Unit: "in"}, {Value: 3, Unit: "in"}}},
+		{"MP3 player", []Quantity{}},
+		{"no numbers here", []Quantity{}},
+	}
+	for _, test := range tests {
+		got := ParseQuantities(test.input)
+		if len(got) != len(test.want) {
+			t.Errorf("ParseQuantities(%q) = %v, want %v", test.input, got, test.want)
+			continue
+		}
+		for i, want := range test.want {
+			if math.Abs(got[i].Value-want.Value) > 1e-9 || got[i].Unit != want.Unit {
+				t.Errorf("ParseQuantities(%q)[%d] = %v %s, want %v %s", test.input, i, got[i].Value, got[i].Unit, want.Value, want.Unit)
+			}
+		}
+	}
+}
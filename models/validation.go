@@ -0,0 +1,49 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Validate returns the problems that keep the Product from being usable.
+// A Product without problems returns none.
+func (p *Product) Validate() []string {
+	var problems []string
+	if strings.TrimSpace(p.Name) == "" {
+		problems = append(problems, "name is missing")
+	}
+	if strings.TrimSpace(p.CanonicalURL) == "" {
+		problems = append(problems, "canonicalUrl is missing")
+	} else if _, err := ExtractCompanyName(p.CanonicalURL); err != nil {
+		problems = append(problems, fmt.Sprintf("canonicalUrl %s has no company name", p.CanonicalURL))
+	}
+	for i, offer := range p.Offers {
+		if _, err := ParsePrice(offer.Price); err != nil {
+			problems = append(problems, fmt.Sprintf("offer %d has an invalid price %q", i, offer.Price))
+		}
+	}
+	for _, gtin := range p.GTINs {
+		if !ValidGTIN(gtin.Value) {
+			problems = append(problems, fmt.Sprintf("gtin %s is invalid", gtin.Value))
+		}
+	}
+	return problems
+}
+
+// Validate returns the problems that keep the Article from being usable.
+// An Article without problems returns none.
+func (a *Article) Validate() []string {
+	var problems []string
+	if strings.TrimSpace(a.Headline) == "" {
+		problems = append(problems, "headline is missing")
+	}
+	if strings.TrimSpace(a.CanonicalURL) == "" {
+		problems = append(problems, "canonicalUrl is missing")
+	} else if _, err := ExtractCompanyName(a.CanonicalURL); err != nil {
+		problems = append(problems, fmt.Sprintf("canonicalUrl %s has no company name", a.CanonicalURL))
+	}
+	if strings.TrimSpace(a.ArticleBody) == "" && strings.TrimSpace(a.ArticleBodyHTML) == "" {
+		problems = append(problems, "articleBody is missing")
+	}
+	return problems
+}